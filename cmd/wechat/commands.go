@@ -0,0 +1,281 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/mp/template"
+	"github.com/issue9/wechat/pay/order"
+)
+
+// 用于输出 access_token
+type tokenOutput struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int64     `json:"expires_in"` // 秒
+	Created     time.Time `json:"created"`
+}
+
+func runToken(conf *config, args []string, _ io.Reader, stdout io.Writer) error {
+	if err := parseFlags("token", args); err != nil {
+		return err
+	}
+
+	srv, err := conf.tokenServer()
+	if err != nil {
+		return err
+	}
+
+	t := srv.Token()
+	return printJSON(stdout, &tokenOutput{
+		AccessToken: t.AccessToken,
+		ExpiresIn:   int64(t.ExpiresIn), // 直接从 JSON 解析，其值即为秒数。
+		Created:     t.Created,
+	})
+}
+
+func runTemplates(conf *config, args []string, _ io.Reader, stdout io.Writer) error {
+	if err := parseFlags("templates", args); err != nil {
+		return err
+	}
+
+	srv, err := conf.tokenServer()
+	if err != nil {
+		return err
+	}
+
+	l, err := template.Templates(srv)
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, l.List)
+}
+
+func runMenu(conf *config, args []string, stdin io.Reader, stdout io.Writer) error {
+	var file string
+	if err := parseFlags("menu", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&file, "file", "-", "菜单的 JSON 文件，- 表示从 stdin 读取")
+	}); err != nil {
+		return err
+	}
+
+	data, err := readFile(file, stdin)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		return errors.New("菜单内容不是有效的 JSON")
+	}
+
+	srv, err := conf.tokenServer()
+	if err != nil {
+		return err
+	}
+
+	if err = internal.PostJSON(token.URL(srv, "cgi-bin/menu/create", nil), json.RawMessage(data), nil); err != nil {
+		return err
+	}
+	return printJSON(stdout, common.NewResult(0))
+}
+
+func runWXACode(conf *config, args []string, _ io.Reader, stdout io.Writer) error {
+	req := &struct {
+		Scene string `json:"scene"`
+		Page  string `json:"page,omitempty"`
+		Width int    `json:"width,omitempty"`
+	}{}
+	var out string
+	if err := parseFlags("wxacode", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&req.Scene, "scene", "", "场景值，最大 32 个字符")
+		fs.StringVar(&req.Page, "page", "", "页面路径，为空表示主页")
+		fs.IntVar(&req.Width, "width", 0, "二维码的宽度，单位 px")
+		fs.StringVar(&out, "out", "wxacode.png", "保存小程序码的文件")
+	}); err != nil {
+		return err
+	}
+
+	if req.Scene == "" {
+		return errors.New("未指定 -scene")
+	}
+
+	srv, err := conf.tokenServer()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(token.URL(srv, "wxa/getwxacodeunlimit", nil), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// 出错时返回的是 JSON，否则为图片内容。
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return common.From(data)
+	}
+
+	if err = os.WriteFile(out, data, 0o644); err != nil {
+		return err
+	}
+	return printJSON(stdout, map[string]interface{}{
+		"file": out,
+		"size": len(data),
+	})
+}
+
+func runOrder(conf *config, args []string, _ io.Reader, stdout io.Writer) error {
+	var outTradeNO, transactionID string
+	if err := parseFlags("order", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&outTradeNO, "out-trade-no", "", "商户订单号")
+		fs.StringVar(&transactionID, "transaction-id", "", "微信订单号")
+	}); err != nil {
+		return err
+	}
+
+	p, err := conf.pay()
+	if err != nil {
+		return err
+	}
+	o := &order.Order{Pay: p}
+
+	var ret *order.Return
+	switch {
+	case transactionID != "":
+		ret, err = o.TransactionID(transactionID)
+	case outTradeNO != "":
+		ret, err = o.OutTradeNO(outTradeNO)
+	default:
+		return errors.New("需要指定 -out-trade-no 或是 -transaction-id")
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, ret)
+}
+
+func runNotify(conf *config, args []string, stdin io.Reader, stdout io.Writer) error {
+	var file string
+	if err := parseFlags("notify", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&file, "file", "-", "支付通知的 XML 内容，- 表示从 stdin 读取")
+	}); err != nil {
+		return err
+	}
+
+	data, err := readFile(file, stdin)
+	if err != nil {
+		return err
+	}
+
+	params, err := xxml.MapFromXMLReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	p, err := conf.pay()
+	if err != nil {
+		return err
+	}
+	if err = p.ValidateAll(params["sign_type"], params); err != nil {
+		return err
+	}
+
+	return printJSON(stdout, map[string]interface{}{
+		"valid":  true,
+		"params": params,
+	})
+}
+
+func runDecrypt(conf *config, args []string, stdin io.Reader, stdout io.Writer) error {
+	var file, signature, timestamp, nonce string
+	if err := parseFlags("decrypt", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&file, "file", "-", "推送的内容，- 表示从 stdin 读取")
+		fs.StringVar(&signature, "signature", "", "请求参数中的 signature")
+		fs.StringVar(&timestamp, "timestamp", "", "请求参数中的 timestamp")
+		fs.StringVar(&nonce, "nonce", "", "请求参数中的 nonce")
+	}); err != nil {
+		return err
+	}
+
+	data, err := readFile(file, stdin)
+	if err != nil {
+		return err
+	}
+
+	c, err := conf.crypto()
+	if err != nil {
+		return err
+	}
+
+	if internal.IsXML(data) {
+		if data, err = c.Decrypt(data, signature, timestamp, nonce); err != nil {
+			return err
+		}
+
+		// 无法转换成 map 的 XML，则原样输出。
+		params, err := xxml.MapFromXMLReader(bytes.NewReader(data))
+		if err != nil {
+			return printJSON(stdout, map[string]string{"xml": string(data)})
+		}
+		return printJSON(stdout, params)
+	}
+
+	if data, err = c.DecryptJSON(data, signature, timestamp, nonce); err != nil {
+		return err
+	}
+	return printJSON(stdout, json.RawMessage(data))
+}
+
+// 解析子命令的参数，init 用于注册参数。
+func parseFlags(name string, args []string, init ...func(*flag.FlagSet)) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, f := range init {
+		f(fs)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("无法识别的参数 " + strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+// 读取文件内容，file 为 - 表示从 stdin 读取。
+func readFile(file string, stdin io.Reader) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(file)
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
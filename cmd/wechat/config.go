@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/pay"
+)
+
+// 环境变量名称
+const (
+	envConfig    = "WECHAT_CONFIG"
+	envAppID     = "WECHAT_APPID"
+	envAppSecret = "WECHAT_APPSECRET"
+	envHost      = "WECHAT_HOST"
+	envToken     = "WECHAT_TOKEN"
+	envAESKey    = "WECHAT_AESKEY"
+	envMchID     = "WECHAT_MCHID"
+	envAPIKey    = "WECHAT_APIKEY"
+	envPayURL    = "WECHAT_PAY_URL"
+)
+
+// 配置文件的内容
+//
+// 配置文件中未指定的字段，会从对应的环境变量中获取。
+type config struct {
+	AppID     string `json:"appid"`
+	AppSecret string `json:"appsecret"`
+	Host      string `json:"host,omitempty"` // 可以带协议，比如 http://localhost:8080
+
+	Token  string `json:"token,omitempty"`  // 消息推送的令牌
+	AESKey string `json:"aeskey,omitempty"` // 消息推送的 EncodingAESKey
+
+	MchID   string `json:"mchid,omitempty"`
+	APIKey  string `json:"apikey,omitempty"`
+	PayURL  string `json:"payURL,omitempty"` // 支付接口地址的前缀
+	Sandbox bool   `json:"sandbox,omitempty"`
+}
+
+// 从 path 加载配置，path 为空表示仅从环境变量中获取。
+func loadConfig(path string) (*config, error) {
+	if path == "" {
+		path = os.Getenv(envConfig)
+	}
+
+	conf := &config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	env(&conf.AppID, envAppID)
+	env(&conf.AppSecret, envAppSecret)
+	env(&conf.Host, envHost)
+	env(&conf.Token, envToken)
+	env(&conf.AESKey, envAESKey)
+	env(&conf.MchID, envMchID)
+	env(&conf.APIKey, envAPIKey)
+	env(&conf.PayURL, envPayURL)
+
+	return conf, nil
+}
+
+func env(v *string, key string) {
+	if *v == "" {
+		*v = os.Getenv(key)
+	}
+}
+
+func (conf *config) common() (*common.Config, error) {
+	if conf.AppID == "" || conf.AppSecret == "" {
+		return nil, errors.New("未指定 appid 或是 appsecret")
+	}
+
+	c := common.NewConfig(conf.AppID, conf.AppSecret, "")
+	if conf.Host != "" {
+		c.Scheme, c.Host = splitHost(conf.Host)
+	}
+	return c, nil
+}
+
+// 获取 access_token 并包装成 token.Server
+func (conf *config) tokenServer() (token.Server, error) {
+	c, err := conf.common()
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &tokenServer{conf: c}
+	if _, err = srv.Refresh(); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+func (conf *config) pay() (*pay.Pay, error) {
+	if conf.MchID == "" || conf.APIKey == "" {
+		return nil, errors.New("未指定 mchid 或是 apikey")
+	}
+
+	p := pay.New(conf.MchID, conf.AppID, conf.APIKey, nil)
+	if conf.PayURL != "" {
+		p = p.SetBaseURL(conf.PayURL)
+	}
+
+	if conf.Sandbox {
+		return p.Sandbox()
+	}
+	return p, nil
+}
+
+func (conf *config) crypto() (*crypto.Crypto, error) {
+	if conf.Token == "" || conf.AESKey == "" {
+		return nil, errors.New("未指定 token 或是 aeskey")
+	}
+	return crypto.New(conf.AppID, conf.Token, conf.AESKey)
+}
+
+// 将 http://localhost:8080 拆分成协议和主机两部分
+func splitHost(host string) (scheme, h string) {
+	for _, s := range []string{"http", "https"} {
+		if strings.HasPrefix(host, s+"://") {
+			return s, strings.TrimPrefix(host, s+"://")
+		}
+	}
+	return "", host
+}
+
+// 命令行只需要执行一次，不需要 token.DefaultServer 的定时刷新功能。
+type tokenServer struct {
+	conf  *common.Config
+	token *token.AccessToken
+}
+
+func (s *tokenServer) Token() *token.AccessToken { return s.token }
+
+func (s *tokenServer) Config() *common.Config { return s.conf }
+
+func (s *tokenServer) Refresh() (*token.AccessToken, error) {
+	t, err := token.Refresh(s.conf)
+	if err != nil {
+		return nil, err
+	}
+	s.token = t
+	return t, nil
+}
+
+// 保证 tokenServer 实现了 token.Server
+var _ token.Server = &tokenServer{}
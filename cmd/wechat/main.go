@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// wechat 微信接口的命令行工具
+//
+// 用于执行一些一次性的操作，比如获取 access_token、推送菜单等，
+// 所有的结果都以 JSON 格式输出到 stdout。
+//
+// 配置项从 -config 指定的 JSON 文件中读取，未指定的项则从环境变量中读取，
+// 具体可参考 wechat help 的输出。
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const usage = `用法：wechat [-config path] <command> [arguments]
+
+command:
+%s
+配置文件为 JSON 格式，包含以下字段：
+  appid, appsecret, host, token, aeskey, mchid, apikey, payURL, sandbox
+未在配置文件中指定的值，会从以下环境变量中读取：
+  WECHAT_APPID, WECHAT_APPSECRET, WECHAT_HOST, WECHAT_TOKEN, WECHAT_AESKEY,
+  WECHAT_MCHID, WECHAT_APIKEY, WECHAT_PAY_URL
+未指定 -config 时，会从环境变量 WECHAT_CONFIG 中获取配置文件的路径。
+`
+
+// 子命令
+type command struct {
+	desc string
+	run  func(conf *config, args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]*command{
+	"token":     {desc: "获取 access_token", run: runToken},
+	"templates": {desc: "获取公众号的模板列表", run: runTemplates},
+	"menu":      {desc: "从 JSON 文件中读取并创建公众号的自定义菜单", run: runMenu},
+	"wxacode":   {desc: "生成小程序码", run: runWXACode},
+	"order":     {desc: "查询支付订单", run: runOrder},
+	"notify":    {desc: "验证支付通知的签名", run: runNotify},
+	"decrypt":   {desc: "解密安全模式下的消息推送内容", run: runDecrypt},
+}
+
+var errUsage = errors.New("参数错误")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("wechat", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { printUsage(stderr) }
+	path := fs.String("config", "", "配置文件的路径")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if fs.NArg() == 0 || fs.Arg(0) == "help" {
+		printUsage(stderr)
+		return nil
+	}
+
+	cmd, found := commands[fs.Arg(0)]
+	if !found {
+		fmt.Fprintf(stderr, "不存在的子命令 %s\n", fs.Arg(0))
+		printUsage(stderr)
+		return errUsage
+	}
+
+	conf, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(conf, fs.Args()[1:], stdin, stdout)
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var cmds string
+	for _, name := range names {
+		cmds += fmt.Sprintf("  %-10s %s\n", name, commands[name].desc)
+	}
+	fmt.Fprintf(w, usage, cmds)
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/pay"
+	"github.com/issue9/wechat/pay/unifiedorder"
+	"github.com/issue9/wechat/wechattest"
+)
+
+const testAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+// 生成指向 srv 的配置文件
+func writeConfig(a *assert.Assertion, srv *wechattest.Server) string {
+	conf := &config{
+		AppID:     wechattest.AppID,
+		AppSecret: wechattest.AppSecret,
+		Host:      srv.URL(),
+		Token:     "token",
+		AESKey:    testAESKey,
+		MchID:     wechattest.MchID,
+		APIKey:    wechattest.APIKey,
+		PayURL:    srv.URL(),
+	}
+	data, err := json.Marshal(conf)
+	a.NotError(err)
+
+	path := filepath.Join(a.TB().TempDir(), "wechat.json")
+	a.NotError(os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func execute(a *assert.Assertion, stdin io.Reader, args ...string) (string, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err := run(args, stdin, stdout, stderr)
+	return stdout.String(), err
+}
+
+func TestRun(t *testing.T) {
+	a := assert.New(t, false)
+
+	stderr := &bytes.Buffer{}
+	a.NotError(run([]string{"help"}, nil, io.Discard, stderr))
+	a.Contains(stderr.String(), "decrypt")
+
+	a.ErrorIs(run([]string{"not-exists"}, nil, io.Discard, io.Discard), errUsage)
+}
+
+func TestLoadConfig(t *testing.T) {
+	a := assert.New(t, false)
+
+	t.Setenv(envConfig, "")
+	t.Setenv(envAppID, "env-appid")
+	t.Setenv(envMchID, "env-mchid")
+	conf, err := loadConfig("")
+	a.NotError(err).
+		Equal(conf.AppID, "env-appid").
+		Equal(conf.MchID, "env-mchid")
+
+	c, err := (&config{AppID: "appid", AppSecret: "secret", Host: "http://localhost:8080"}).common()
+	a.NotError(err).
+		Equal(c.Scheme, "http").
+		Equal(c.Host, "localhost:8080")
+
+	_, err = (&config{}).common()
+	a.Error(err)
+}
+
+func TestCommands(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	path := writeConfig(a, srv)
+
+	// token
+	out, err := execute(a, nil, "-config", path, "token")
+	a.NotError(err).Contains(out, "access-token-")
+
+	// menu
+	out, err = execute(a, strings.NewReader(`{"button":[]}`), "-config", path, "menu")
+	a.NotError(err).Contains(out, `"errmsg"`)
+	msgs := srv.Messages()
+	a.Length(msgs, 1).Equal(msgs[0].Path, "/cgi-bin/menu/create")
+
+	_, err = execute(a, strings.NewReader(`{"button":`), "-config", path, "menu")
+	a.Error(err)
+
+	// order
+	_, err = execute(a, nil, "-config", path, "order", "-out-trade-no", "no1")
+	a.Error(err)
+
+	_, err = execute(a, nil, "-config", path, "order", "-out-trade-no")
+	a.Error(err)
+
+	o := unifiedorder.New(srv.Pay()).NewOrder()
+	o.TradeType = pay.TradeTypeNative
+	o.OutTradeNO = "no1"
+	o.TotalFee = 100
+	_, err = o.Pay()
+	a.NotError(err)
+	out, err = execute(a, nil, "-config", path, "order", "-out-trade-no", "no1")
+	a.NotError(err).Contains(out, pay.TradeStateNotPay)
+
+	// notify
+	r, err := wechattest.NewPayNotify(pay.New(wechattest.MchID, wechattest.AppID, wechattest.APIKey, nil), map[string]string{
+		"out_trade_no": "no1",
+	})
+	a.NotError(err)
+	out, err = execute(a, r.Body, "-config", path, "notify")
+	a.NotError(err).Contains(out, `"valid": true`)
+
+	r, err = wechattest.NewPayNotify(pay.New(wechattest.MchID, wechattest.AppID, "other", nil), nil)
+	a.NotError(err)
+	_, err = execute(a, r.Body, "-config", path, "notify")
+	a.Equal(err, pay.ErrInvalidSign)
+
+	// decrypt
+	c, err := crypto.New(wechattest.AppID, "token", testAESKey)
+	a.NotError(err)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body, sign, err := c.Encrypt([]byte(`<xml><MsgType>text</MsgType><Content>hello</Content></xml>`), timestamp, "nonce")
+	a.NotError(err)
+	out, err = execute(a, bytes.NewReader(body), "-config", path, "decrypt", "-signature", sign, "-timestamp", timestamp, "-nonce", "nonce")
+	a.NotError(err).Contains(out, `"Content": "hello"`)
+}
@@ -13,7 +13,8 @@ import (
 type Config struct {
 	AppID     string
 	AppSecret string
-	Host      string // 主机，不包含协议和端口
+	Host      string // 主机，不包含协议，可以带端口
+	Scheme    string // 协议，默认为 https，一般仅在测试时指定为 http
 }
 
 // NewConfig 声明一个 [Config] 实例
@@ -43,12 +44,17 @@ func NewConfig(appid, appsecret, host string) *Config {
 
 // URL 生成调用 api 的地址
 //
-// 根据 c.Host 不同，生成不同的地址。
+// 根据 c.Scheme 和 c.Host 不同，生成不同的地址。
 func (c *Config) URL(urlpath string, queries map[string]string) string {
 	us := make(url.Values, len(queries))
 	for k, v := range queries {
 		us.Add(k, v)
 	}
 
-	return "https://" + path.Join(c.Host, urlpath) + "?" + us.Encode()
+	scheme := c.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + path.Join(c.Host, urlpath) + "?" + us.Encode()
 }
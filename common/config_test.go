@@ -31,4 +31,9 @@ func TestConfig_URL(t *testing.T) {
 
 	url = conf.URL("test", nil)
 	a.Equal(url, "https://api.domain/test?")
+
+	conf.Scheme = "http"
+	conf.Host = "127.0.0.1:8080"
+	url = conf.URL("/test", nil)
+	a.Equal(url, "http://127.0.0.1:8080/test?")
 }
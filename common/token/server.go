@@ -6,11 +6,15 @@ package token
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/issue9/wechat/common"
 )
 
+// 刷新出错时，重试的间隔时间
+var retryDelay = time.Minute
+
 // Server 表示中控服务器接口
 type Server interface {
 	// 获取中控服务器缓存的 access_token
@@ -30,7 +34,10 @@ type Server interface {
 type DefaultServer struct {
 	conf   *common.Config
 	errlog *log.Logger
-	token  *AccessToken
+	retry  time.Duration
+
+	mux   sync.RWMutex
+	token *AccessToken
 }
 
 // NewDefaultServer 声明一个默认的 access_token 中控服务器
@@ -44,6 +51,7 @@ func NewDefaultServer(conf *common.Config, errlog *log.Logger) Server {
 	srv := &DefaultServer{
 		conf:   conf,
 		errlog: errlog,
+		retry:  retryDelay,
 	}
 	srv.refresh()
 
@@ -52,6 +60,8 @@ func NewDefaultServer(conf *common.Config, errlog *log.Logger) Server {
 
 // Token 获取当前的 *AccessToken
 func (s *DefaultServer) Token() *AccessToken {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
 	return s.token
 }
 
@@ -61,7 +71,9 @@ func (s *DefaultServer) Refresh() (*AccessToken, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.mux.Lock()
 	s.token = token
+	s.mux.Unlock()
 
 	return token, nil
 }
@@ -73,16 +85,29 @@ func (s *DefaultServer) Config() *common.Config {
 
 // 定时刷新
 func (s *DefaultServer) refresh() {
-	if _, err := s.Refresh(); err != nil {
+	dur := s.retry
+	if token, err := s.Refresh(); err != nil {
 		s.errlog.Println(err)
+	} else {
+		dur = refreshDelay(token, s.retry)
 	}
 
-	// 提前10分钟刷新
-	time.AfterFunc(time.Duration(s.token.ExpiresIn-600)*time.Second, func() {
+	time.AfterFunc(dur, func() {
 		s.refresh()
 	})
 }
 
+// 计算下一次刷新的间隔时间
+//
+// 提前 10 分钟刷新，但不小于 min，防止 expires_in 过小时频繁请求。
+func refreshDelay(t *AccessToken, min time.Duration) time.Duration {
+	dur := time.Duration(t.ExpiresIn-600) * time.Second
+	if dur < min {
+		dur = min
+	}
+	return dur
+}
+
 // URL 生成指定地址的 URL，会在查询参数中添中 access_token 的相关设置
 func URL(s Server, path string, queries map[string]string) string {
 	if queries == nil {
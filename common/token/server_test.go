@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestDefaultServer_refresh(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+
+	old := retryDelay
+	retryDelay = 10 * time.Millisecond
+	defer func() { retryDelay = old }()
+
+	// 第一次获取失败，不会崩溃，且会在 retryDelay 之后重试。
+	srv.InjectError(-1)
+	s := NewDefaultServer(srv.Config(), nil)
+	a.Nil(s.Token())
+
+	time.Sleep(100 * time.Millisecond)
+	a.NotNil(s.Token()).NotEmpty(s.Token().AccessToken)
+}
+
+func TestRefreshDelay(t *testing.T) {
+	a := assert.New(t, false)
+
+	a.Equal(refreshDelay(&AccessToken{ExpiresIn: 7200}, time.Minute), 6600*time.Second).
+		Equal(refreshDelay(&AccessToken{ExpiresIn: 600}, time.Minute), time.Minute).
+		Equal(refreshDelay(&AccessToken{ExpiresIn: 0}, time.Minute), time.Minute)
+}
@@ -0,0 +1,273 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package config 多账号的配置管理
+//
+// 以 YAML 或是 JSON 描述公众号、小程序、商户以及第三方平台的配置，
+// 配置内容中的 ${NAME} 会被替换成对应的环境变量：
+//
+//	mp:
+//	  main:
+//	    appid: wx123
+//	    appsecret: ${MAIN_SECRET}
+//	    token: token
+//	merchants:
+//	  shop:
+//	    mchid: "1230000109"
+//	    appid: wx123
+//	    apikey: ${SHOP_APIKEY}
+//
+// 之后通过 [NewRegistry] 按名称获取对应的 token.Server、pay.Pay 等实例。
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/open/crypto"
+)
+
+// Config 所有账号的配置
+type Config struct {
+	MP         map[string]*Account   `yaml:"mp,omitempty" json:"mp,omitempty"`                 // 公众号
+	Weapp      map[string]*Account   `yaml:"weapp,omitempty" json:"weapp,omitempty"`           // 小程序
+	Merchants  map[string]*Merchant  `yaml:"merchants,omitempty" json:"merchants,omitempty"`   // 支付商户
+	Components map[string]*Component `yaml:"components,omitempty" json:"components,omitempty"` // 第三方平台
+}
+
+// Account 公众号或是小程序的配置
+type Account struct {
+	AppID     string `yaml:"appid" json:"appid"`
+	AppSecret string `yaml:"appsecret" json:"appsecret"`
+	Host      string `yaml:"host,omitempty" json:"host,omitempty"`     // 接口域名，默认为 api.weixin.qq.com
+	Scheme    string `yaml:"scheme,omitempty" json:"scheme,omitempty"` // 接口的协议，默认为 https
+
+	// 消息推送的配置
+	Token  string `yaml:"token,omitempty" json:"token,omitempty"`
+	AESKey string `yaml:"aeskey,omitempty" json:"aeskey,omitempty"` // EncodingAESKey，为空表示明文模式
+}
+
+// Merchant 支付商户的配置
+type Merchant struct {
+	MchID  string `yaml:"mchid" json:"mchid"`
+	AppID  string `yaml:"appid" json:"appid"`
+	APIKey string `yaml:"apikey" json:"apikey"`
+
+	// 证书的路径，指定了 Cert 和 Key 时会采用 pay.NewTLSPay 创建实例。
+	Cert   string `yaml:"cert,omitempty" json:"cert,omitempty"`
+	Key    string `yaml:"key,omitempty" json:"key,omitempty"`
+	RootCA string `yaml:"rootca,omitempty" json:"rootca,omitempty"`
+
+	BaseURL string `yaml:"baseURL,omitempty" json:"baseURL,omitempty"` // 接口地址的前缀
+	Sandbox bool   `yaml:"sandbox,omitempty" json:"sandbox,omitempty"` // 是否为仿真测试环境
+
+	// 服务商模式下的子商户
+	//
+	// 指定了 Partner 之后，MchID、AppID 和 APIKey 等都从 Partner 指定的商户继承。
+	Partner  string `yaml:"partner,omitempty" json:"partner,omitempty"`
+	SubMchID string `yaml:"subMchID,omitempty" json:"subMchID,omitempty"`
+	SubAppID string `yaml:"subAppID,omitempty" json:"subAppID,omitempty"`
+}
+
+// Component 第三方平台的配置
+type Component struct {
+	AppID     string `yaml:"appid" json:"appid"`
+	AppSecret string `yaml:"appsecret" json:"appsecret"`
+	Token     string `yaml:"token" json:"token"`
+	AESKey    string `yaml:"aeskey" json:"aeskey"`
+}
+
+// Error 配置项的错误信息
+type Error struct {
+	Field   string // 出错的字段，比如 mp.main.appid
+	Message string
+}
+
+func (err *Error) Error() string { return err.Field + ": " + err.Message }
+
+// Load 从文件中加载配置
+//
+// 根据扩展名决定采用 YAML 还是 JSON 解码，会对返回的配置进行 [Config.Validate] 验证。
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return Parse(data, yaml.Unmarshal)
+	case ".json":
+		return Parse(data, json.Unmarshal)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件类型 %s", path)
+	}
+}
+
+// 配置内容中的环境变量，仅支持 ${NAME} 的形式，防止误替换密钥中的 $ 字符。
+var envVar = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// Parse 采用 unmarshal 解码 data
+//
+// data 中的 ${NAME} 会被替换成环境变量的值，其它形式的 $ 字符保持不变，
+// unmarshal 可以是 json.Unmarshal 或是 yaml.Unmarshal 等。
+func Parse(data []byte, unmarshal func([]byte, interface{}) error) (*Config, error) {
+	data = envVar.ReplaceAllFunc(data, func(v []byte) []byte {
+		return []byte(os.Getenv(string(v[2 : len(v)-1])))
+	})
+
+	conf := &Config{}
+	if err := unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// Validate 验证配置项
+//
+// 返回的错误为 [*Error] 类型。
+func (conf *Config) Validate() error {
+	for name, a := range conf.MP {
+		if err := a.validate("mp." + name); err != nil {
+			return err
+		}
+	}
+
+	for name, a := range conf.Weapp {
+		if _, found := conf.MP[name]; found {
+			return &Error{Field: "weapp." + name, Message: "与 mp 中的账号重名"}
+		}
+
+		if err := a.validate("weapp." + name); err != nil {
+			return err
+		}
+	}
+
+	for name, m := range conf.Merchants {
+		if err := m.validate(conf, "merchants."+name); err != nil {
+			return err
+		}
+	}
+
+	for name, c := range conf.Components {
+		if err := c.validate("components." + name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *Account) validate(field string) error {
+	if a == nil {
+		return &Error{Field: field, Message: "不能为空"}
+	}
+
+	if a.AppID == "" {
+		return &Error{Field: field + ".appid", Message: "不能为空"}
+	}
+
+	if a.AppSecret == "" {
+		return &Error{Field: field + ".appsecret", Message: "不能为空"}
+	}
+
+	switch a.Scheme {
+	case "", "http", "https":
+	default:
+		return &Error{Field: field + ".scheme", Message: "只能是 http 或是 https"}
+	}
+
+	if a.AESKey != "" {
+		if a.Token == "" {
+			return &Error{Field: field + ".token", Message: "指定了 aeskey 时不能为空"}
+		}
+		if _, err := crypto.New(a.AppID, a.Token, a.AESKey); err != nil {
+			return &Error{Field: field + ".aeskey", Message: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+func (a *Account) common() *common.Config {
+	host := a.Host
+	if host == "" {
+		host = "api.weixin.qq.com"
+	}
+
+	return &common.Config{
+		AppID:     a.AppID,
+		AppSecret: a.AppSecret,
+		Host:      host,
+		Scheme:    a.Scheme,
+	}
+}
+
+func (m *Merchant) validate(conf *Config, field string) error {
+	if m == nil {
+		return &Error{Field: field, Message: "不能为空"}
+	}
+
+	if m.Partner != "" {
+		p, found := conf.Merchants[m.Partner]
+		switch {
+		case !found || p == nil:
+			return &Error{Field: field + ".partner", Message: "不存在该商户"}
+		case p.Partner != "":
+			return &Error{Field: field + ".partner", Message: "不能指向另一个子商户"}
+		case m.SubMchID == "":
+			return &Error{Field: field + ".subMchID", Message: "不能为空"}
+		}
+		return nil
+	}
+
+	if m.SubMchID != "" || m.SubAppID != "" {
+		return &Error{Field: field + ".partner", Message: "指定了 subMchID 时不能为空"}
+	}
+
+	if m.MchID == "" {
+		return &Error{Field: field + ".mchid", Message: "不能为空"}
+	}
+
+	if m.APIKey == "" {
+		return &Error{Field: field + ".apikey", Message: "不能为空"}
+	}
+
+	if (m.Cert == "") != (m.Key == "") {
+		return &Error{Field: field + ".cert", Message: "cert 和 key 必须同时指定"}
+	}
+
+	return nil
+}
+
+func (c *Component) validate(field string) error {
+	if c == nil {
+		return &Error{Field: field, Message: "不能为空"}
+	}
+
+	if c.AppID == "" {
+		return &Error{Field: field + ".appid", Message: "不能为空"}
+	}
+
+	if c.AppSecret == "" {
+		return &Error{Field: field + ".appsecret", Message: "不能为空"}
+	}
+
+	if _, err := crypto.New(c.AppID, c.Token, c.AESKey); err != nil {
+		return &Error{Field: field + ".aeskey", Message: err.Error()}
+	}
+
+	return nil
+}
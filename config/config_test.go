@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const testAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+const testYAML = `
+mp:
+  main:
+    appid: wx-mp
+    appsecret: ${WECHAT_TEST_SECRET}
+    token: to$ken$1
+weapp:
+  app:
+    appid: wx-weapp
+    appsecret: secret
+    token: token
+    aeskey: ` + testAESKey + `
+merchants:
+  shop:
+    mchid: "1230000109"
+    appid: wx-mp
+    apikey: apikey
+  sub:
+    partner: shop
+    subMchID: "1900000109"
+    subAppID: wx-sub
+components:
+  open:
+    appid: wx-component
+    appsecret: secret
+    token: token
+    aeskey: ` + testAESKey + `
+`
+
+func TestLoad(t *testing.T) {
+	a := assert.New(t, false)
+	t.Setenv("WECHAT_TEST_SECRET", "env-secret")
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "wechat.yaml")
+	a.NotError(os.WriteFile(path, []byte(testYAML), 0o644))
+	conf, err := Load(path)
+	a.NotError(err).NotNil(conf)
+	a.Equal(conf.MP["main"].AppSecret, "env-secret").
+		Equal(conf.MP["main"].Token, "to$ken$1").
+		Equal(conf.Weapp["app"].AESKey, testAESKey).
+		Equal(conf.Merchants["shop"].MchID, "1230000109").
+		Equal(conf.Merchants["sub"].Partner, "shop").
+		Equal(conf.Components["open"].AppID, "wx-component")
+
+	// JSON
+	data, err := json.Marshal(conf)
+	a.NotError(err)
+	path = filepath.Join(dir, "wechat.json")
+	a.NotError(os.WriteFile(path, data, 0o644))
+	conf2, err := Load(path)
+	a.NotError(err).Equal(conf2, conf)
+
+	path = filepath.Join(dir, "wechat.toml")
+	a.NotError(os.WriteFile(path, data, 0o644))
+	_, err = Load(path)
+	a.Error(err)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	a := assert.New(t, false)
+
+	validate := func(data, field string) {
+		t.Helper()
+		_, err := Parse([]byte(data), yaml.Unmarshal)
+		e, ok := err.(*Error)
+		a.True(ok, "%v", err).Equal(e.Field, field)
+	}
+
+	validate(`mp: {main: {appid: wx}}`, "mp.main.appsecret")
+	validate(`mp: {main: {appsecret: secret}}`, "mp.main.appid")
+	validate(`mp: {main: {appid: wx, appsecret: s, scheme: ftp}}`, "mp.main.scheme")
+	validate(`mp: {main: {appid: wx, appsecret: s, aeskey: `+testAESKey+`}}`, "mp.main.token")
+	validate(`mp: {main: {appid: wx, appsecret: s, token: t, aeskey: short}}`, "mp.main.aeskey")
+	validate(`mp: {main: {appid: wx, appsecret: s}}
+weapp: {main: {appid: wx, appsecret: s}}`, "weapp.main")
+
+	validate(`merchants: {shop: {appid: wx, apikey: key}}`, "merchants.shop.mchid")
+	validate(`merchants: {shop: {mchid: "1", appid: wx}}`, "merchants.shop.apikey")
+	validate(`merchants: {shop: {mchid: "1", apikey: key, cert: cert.pem}}`, "merchants.shop.cert")
+	validate(`merchants: {shop: {mchid: "1", apikey: key, subMchID: "2"}}`, "merchants.shop.partner")
+	validate(`merchants: {sub: {partner: shop, subMchID: "2"}}`, "merchants.sub.partner")
+	validate(`merchants: {shop: {mchid: "1", apikey: key}, sub: {partner: shop}}`, "merchants.sub.subMchID")
+
+	validate(`components: {open: {appid: wx, appsecret: s, token: t}}`, "components.open.aeskey")
+}
@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	mpmessage "github.com/issue9/wechat/mp/message"
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/pay"
+	weappmessage "github.com/issue9/wechat/weapp/message"
+)
+
+// Registry 根据账号名称获取各类实例
+//
+// token.Server 和 pay.Pay 实例在第一次获取时创建，之后一直复用。
+type Registry struct {
+	conf   *Config
+	errlog *log.Logger
+
+	mu     sync.Mutex
+	tokens map[string]token.Server
+	pays   map[string]*pay.Pay
+}
+
+// NewRegistry 声明 [Registry] 实例
+//
+// 会先对 conf 进行验证；errlog 会传递给 token.Server 等需要输出错误信息的对象。
+func NewRegistry(conf *Config, errlog *log.Logger) (*Registry, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		conf:   conf,
+		errlog: errlog,
+		tokens: make(map[string]token.Server, len(conf.MP)+len(conf.Weapp)),
+		pays:   make(map[string]*pay.Pay, len(conf.Merchants)),
+	}, nil
+}
+
+// Config 返回关联的配置对象
+func (r *Registry) Config() *Config { return r.conf }
+
+// 查找公众号或是小程序的配置
+func (r *Registry) account(name string) (*Account, error) {
+	if a, found := r.conf.MP[name]; found {
+		return a, nil
+	}
+	if a, found := r.conf.Weapp[name]; found {
+		return a, nil
+	}
+	return nil, fmt.Errorf("不存在的账号 %s", name)
+}
+
+// Common 获取公众号或是小程序的 [common.Config]
+func (r *Registry) Common(name string) (*common.Config, error) {
+	a, err := r.account(name)
+	if err != nil {
+		return nil, err
+	}
+	return a.common(), nil
+}
+
+// TokenServer 获取公众号或是小程序的 access_token 中控服务器
+func (r *Registry) TokenServer(name string) (token.Server, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if srv, found := r.tokens[name]; found {
+		return srv, nil
+	}
+
+	a, err := r.account(name)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := token.NewDefaultServer(a.common(), r.errlog)
+	r.tokens[name] = srv
+	return srv, nil
+}
+
+// Crypto 获取公众号、小程序或是第三方平台的消息加解密对象
+//
+// 未配置 aeskey 的公众号和小程序返回 nil。
+func (r *Registry) Crypto(name string) (*crypto.Crypto, error) {
+	if c, found := r.conf.Components[name]; found {
+		return crypto.New(c.AppID, c.Token, c.AESKey)
+	}
+
+	a, err := r.account(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.AESKey == "" {
+		return nil, nil
+	}
+	return crypto.New(a.AppID, a.Token, a.AESKey)
+}
+
+// MessageServer 获取公众号的消息管理服务器
+func (r *Registry) MessageServer(name string, h mpmessage.Handler) (*mpmessage.Server, error) {
+	a, found := r.conf.MP[name]
+	if !found {
+		return nil, fmt.Errorf("不存在的公众号 %s", name)
+	}
+
+	c, err := r.Crypto(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return mpmessage.NewCryptoServer(a.Token, c, h, r.errlog), nil
+}
+
+// WeappMessageServer 获取小程序的消息推送服务
+func (r *Registry) WeappMessageServer(name string, h weappmessage.Handler) (*weappmessage.Server, error) {
+	a, found := r.conf.Weapp[name]
+	if !found {
+		return nil, fmt.Errorf("不存在的小程序 %s", name)
+	}
+
+	c, err := r.Crypto(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return weappmessage.NewServer(a.Token, c, h, r.errlog), nil
+}
+
+// Pay 获取商户的 [pay.Pay] 实例
+//
+// 子商户返回的是其服务商 [pay.Pay.Sub] 的返回值；
+// 仿真测试环境会在第一次调用时获取签名密钥。
+func (r *Registry) Pay(name string) (*pay.Pay, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pay(name)
+}
+
+func (r *Registry) pay(name string) (*pay.Pay, error) {
+	if p, found := r.pays[name]; found {
+		return p, nil
+	}
+
+	m, found := r.conf.Merchants[name]
+	if !found {
+		return nil, fmt.Errorf("不存在的商户 %s", name)
+	}
+
+	var p *pay.Pay
+	if m.Partner != "" {
+		sp, err := r.pay(m.Partner)
+		if err != nil {
+			return nil, err
+		}
+		p = sp.Sub(m.SubMchID, m.SubAppID)
+	} else {
+		if m.Cert != "" {
+			var err error
+			if p, err = pay.NewTLSPay(m.MchID, m.AppID, m.APIKey, m.Cert, m.Key, m.RootCA); err != nil {
+				return nil, err
+			}
+		} else {
+			p = pay.New(m.MchID, m.AppID, m.APIKey, nil)
+		}
+
+		if m.BaseURL != "" {
+			p = p.SetBaseURL(m.BaseURL)
+		}
+
+		if m.Sandbox {
+			var err error
+			if p, err = p.Sandbox(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r.pays[name] = p
+	return p, nil
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestRegistry(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	u, err := url.Parse(srv.URL())
+	a.NotError(err)
+
+	conf := &Config{
+		MP: map[string]*Account{
+			"main": {AppID: wechattest.AppID, AppSecret: wechattest.AppSecret, Host: u.Host, Scheme: u.Scheme, Token: "token"},
+		},
+		Weapp: map[string]*Account{
+			"app": {AppID: "wx-weapp", AppSecret: "secret", Token: "token", AESKey: testAESKey},
+		},
+		Merchants: map[string]*Merchant{
+			"shop":    {MchID: wechattest.MchID, AppID: wechattest.AppID, APIKey: wechattest.APIKey, BaseURL: srv.URL()},
+			"sub":     {Partner: "shop", SubMchID: "sub-mchid"},
+			"sandbox": {MchID: wechattest.MchID, AppID: wechattest.AppID, APIKey: wechattest.APIKey, BaseURL: srv.URL(), Sandbox: true},
+		},
+		Components: map[string]*Component{
+			"open": {AppID: "wx-component", AppSecret: "secret", Token: "token", AESKey: testAESKey},
+		},
+	}
+	r, err := NewRegistry(conf, nil)
+	a.NotError(err).NotNil(r)
+
+	c, err := r.Common("app")
+	a.NotError(err).Equal(c.Host, "api.weixin.qq.com").Equal(c.AppID, "wx-weapp")
+	_, err = r.Common("not-exists")
+	a.Error(err)
+
+	tksrv, err := r.TokenServer("main")
+	a.NotError(err).NotNil(tksrv)
+	a.Contains(tksrv.Token().AccessToken, "access-token-")
+	tksrv2, err := r.TokenServer("main")
+	a.NotError(err).Equal(tksrv2, tksrv)
+
+	cr, err := r.Crypto("main")
+	a.NotError(err).Nil(cr)
+	cr, err = r.Crypto("app")
+	a.NotError(err).NotNil(cr)
+	cr, err = r.Crypto("open")
+	a.NotError(err).NotNil(cr)
+
+	msrv, err := r.MessageServer("main", nil)
+	a.NotError(err).NotNil(msrv)
+	_, err = r.MessageServer("app", nil)
+	a.Error(err)
+
+	wsrv, err := r.WeappMessageServer("app", nil)
+	a.NotError(err).NotNil(wsrv)
+
+	p, err := r.Pay("shop")
+	a.NotError(err).Equal(p.MchID(), wechattest.MchID).Equal(p.BaseURL(), srv.URL())
+	p2, err := r.Pay("shop")
+	a.NotError(err).Equal(p2, p)
+
+	sub, err := r.Pay("sub")
+	a.NotError(err).Equal(sub.SubMchID(), "sub-mchid").Equal(sub.MchID(), wechattest.MchID)
+
+	sp, err := r.Pay("sandbox")
+	a.NotError(err).True(sp.IsSandbox()).Equal(sp.APIKey(), wechattest.SandboxSignKey)
+
+	_, err = r.Pay("not-exists")
+	a.Error(err)
+
+	// 无效的配置
+	conf.MP["main"].AppID = ""
+	_, err = NewRegistry(conf, nil)
+	a.Error(err)
+}
@@ -4,6 +4,7 @@ require (
 	github.com/issue9/assert/v4 v4.1.1
 	github.com/issue9/errwrap v0.3.2
 	github.com/issue9/rands/v2 v2.0.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 go 1.17
@@ -4,16 +4,29 @@
 
 package internal
 
-import "bytes"
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrInvalidPadding 无效的 PKCS7 填充内容
+var ErrInvalidPadding = errors.New("无效的 PKCS7 填充")
 
 // PKCS7UnPadding 解码
-func PKCS7UnPadding(plantText []byte) []byte {
+//
+// 填充长度最大为 32，以兼容微信消息加解密中采用的 32 字节块。
+// 如果 plantText 为空或是填充内容不正确，返回 [ErrInvalidPadding]。
+func PKCS7UnPadding(plantText []byte) ([]byte, error) {
 	length := len(plantText)
+	if length == 0 {
+		return nil, ErrInvalidPadding
+	}
+
 	unPadding := int(plantText[length-1])
-	if unPadding < 1 || unPadding > 32 {
-		unPadding = 0
+	if unPadding < 1 || unPadding > 32 || unPadding > length {
+		return nil, ErrInvalidPadding
 	}
-	return plantText[:(length - unPadding)]
+	return plantText[:(length - unPadding)], nil
 }
 
 // PKCS7Padding 编码
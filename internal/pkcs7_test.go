@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestPKCS7UnPadding(t *testing.T) {
+	a := assert.New(t, false)
+
+	data := PKCS7Padding([]byte("123"), 16)
+	a.Length(data, 16)
+	data, err := PKCS7UnPadding(data)
+	a.NotError(err).Equal(string(data), "123")
+
+	data = PKCS7Padding([]byte("1234567890123456"), 32)
+	a.Length(data, 32)
+	data, err = PKCS7UnPadding(data)
+	a.NotError(err).Equal(string(data), "1234567890123456")
+
+	data, err = PKCS7UnPadding(nil)
+	a.ErrorIs(err, ErrInvalidPadding).Nil(data)
+
+	data, err = PKCS7UnPadding([]byte{1, 2, 0})
+	a.ErrorIs(err, ErrInvalidPadding).Nil(data)
+
+	data, err = PKCS7UnPadding([]byte{1, 2, 33})
+	a.ErrorIs(err, ErrInvalidPadding).Nil(data)
+
+	data, err = PKCS7UnPadding([]byte{1, 2, 4})
+	a.ErrorIs(err, ErrInvalidPadding).Nil(data)
+}
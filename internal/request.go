@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/issue9/wechat/common"
+)
+
+// PostJSON 以 JSON 格式提交 req 至 url，并将返回内容解码至 resp
+//
+// 若返回内容的 errcode 表示一个错误，则返回 [common.Result] 实例。
+// resp 可以为空，表示仅关心是否出错。
+func PostJSON(url string, req, resp interface{}) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	return parseJSON(r.Body, resp)
+}
+
+// GetJSON 以 GET 请求 url，并将返回内容解码至 resp
+func GetJSON(url string, resp interface{}) error {
+	r, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	return parseJSON(r.Body, resp)
+}
+
+func parseJSON(r io.Reader, resp interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	rslt := &common.Result{}
+	if err = json.Unmarshal(data, rslt); err != nil {
+		return err
+	}
+	if !rslt.IsOK() {
+		return rslt
+	}
+
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, resp)
+}
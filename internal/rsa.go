@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPEMBlock 无效的 PEM 内容
+var ErrInvalidPEMBlock = errors.New("无效的 PEM 内容")
+
+// ParsePublicKey 解析 PEM 格式的 RSA 公钥
+//
+// 支持 PKIX 和 PKCS#1 格式。
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEMBlock
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("不支持的公钥类型 %T", key)
+	}
+	return rsaKey, nil
+}
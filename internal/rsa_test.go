@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	a := assert.New(t, false)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NotError(err)
+
+	// PKIX
+	bs, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	a.NotError(err)
+	pk, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: bs}))
+	a.NotError(err).True(pk.Equal(&key.PublicKey))
+
+	// PKCS#1
+	bs = x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	pk, err = ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: bs}))
+	a.NotError(err).True(pk.Equal(&key.PublicKey))
+
+	// 非 RSA 公钥
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NotError(err)
+	bs, err = x509.MarshalPKIXPublicKey(&ec.PublicKey)
+	a.NotError(err)
+	pk, err = ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: bs}))
+	a.Error(err).Nil(pk)
+
+	pk, err = ParsePublicKey([]byte("not pem"))
+	a.ErrorIs(err, ErrInvalidPEMBlock).Nil(pk)
+}
@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+)
+
+// IsXML 判断 data 是否为 XML 格式的内容
+//
+// 小程序的消息推送可以是 XML 也可以是 JSON，仅通过第一个非空字符判断。
+func IsXML(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '<'
+}
+
+// Unmarshal 根据 data 的格式采用 XML 或是 JSON 进行解码
+func Unmarshal(data []byte, v interface{}) error {
+	if IsXML(data) {
+		return xml.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestUnmarshal(t *testing.T) {
+	a := assert.New(t, false)
+
+	type object struct {
+		Name string `xml:"Name" json:"Name"`
+	}
+
+	a.True(IsXML([]byte("  <xml></xml>"))).
+		False(IsXML([]byte(` {"Name":"n"}`))).
+		False(IsXML(nil))
+
+	obj := &object{}
+	a.NotError(Unmarshal([]byte(`<xml><Name>xml</Name></xml>`), obj)).Equal(obj.Name, "xml")
+
+	obj = &object{}
+	a.NotError(Unmarshal([]byte(`{"Name":"json"}`), obj)).Equal(obj.Name, "json")
+}
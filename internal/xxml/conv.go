@@ -13,9 +13,13 @@ import (
 )
 
 // MapFromXMLReader 从 io.Reader 读取内容，并填充到 map 中
+//
+// 根元素会被忽略，不限定其名称，比如 <xml> 和 <root> 均可。
+// 空元素的值为空字符串。
 func MapFromXMLReader(r io.Reader) (map[string]string, error) {
 	ret := make(map[string]string, 10)
 	d := xml.NewDecoder(r)
+	root := true
 	for token, err := d.Token(); true; token, err = d.Token() {
 		if err == io.EOF {
 			break
@@ -28,26 +32,33 @@ func MapFromXMLReader(r io.Reader) (map[string]string, error) {
 			continue
 		}
 
-		name := elem.Name.Local
-		if name == "xml" {
+		if root {
+			root = false
 			continue
 		}
 
+		name := elem.Name.Local
 		token, err = d.Token()
 		if err != nil { // 此处若是 io.EOF，也是属于非正常结束
 			return nil, err
 		}
-		bs, ok := token.(xml.CharData)
-		if !ok {
+
+		switch bs := token.(type) {
+		case xml.CharData:
+			ret[name] = string(bs)
+		case xml.EndElement:
+			ret[name] = ""
+		default:
 			return nil, errors.New("无法转换成 xml.CharData")
 		}
-		ret[name] = string(bs)
 	}
 
 	return ret, nil
 }
 
 // Map2XMLObj 将 map 转换到 v
+//
+// 值为空的数值类型字段会被忽略。
 func Map2XMLObj(maps map[string]string, v interface{}) error {
 	values := values(v)
 
@@ -61,6 +72,9 @@ func Map2XMLObj(maps map[string]string, v interface{}) error {
 		case reflect.String:
 			val.SetString(v)
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v == "" { // 空元素，保留字段原有的值
+				continue
+			}
 			x, err := strconv.ParseInt(v, 10, 64)
 			if err != nil {
 				return err
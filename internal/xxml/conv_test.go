@@ -23,6 +23,20 @@ func TestMapFromReader(t *testing.T) {
 	a.NotError(err).NotNil(m)
 	a.Equal(m["appid"], "1234567")
 	a.Equal(m["mch_id"], "mch_id123")
+
+	// 非 xml 的根元素以及空元素
+	buf = bytes.NewBufferString(`<root><appid>1234567</appid><empty></empty><self/></root>`)
+	m, err = MapFromXMLReader(buf)
+	a.NotError(err).NotNil(m)
+	a.Equal(m["appid"], "1234567").
+		Equal(m["empty"], "").
+		Equal(m["self"], "").
+		Length(m, 3)
+
+	// 嵌套元素
+	buf = bytes.NewBufferString(`<xml><item><id>1</id></item></xml>`)
+	m, err = MapFromXMLReader(buf)
+	a.Error(err).Nil(m)
 }
 
 func TestMap2XMLObj(t *testing.T) {
@@ -47,3 +61,24 @@ func TestMap2XMLObj(t *testing.T) {
 		Equal(obj.MchID, "mch_id123").
 		Equal(obj.Count, 55)
 }
+
+func TestMap2XMLObj_empty(t *testing.T) {
+	a := assert.New(t, false)
+
+	m, err := MapFromXMLReader(bytes.NewBufferString(`<xml>
+	<return_code>SUCCESS</return_code>
+	<coupon_fee></coupon_fee>
+	<total_fee>5</total_fee>
+	</xml>`))
+	a.NotError(err)
+
+	obj := &struct {
+		ReturnCode string `xml:"return_code"`
+		CouponFee  int    `xml:"coupon_fee"`
+		TotalFee   int    `xml:"total_fee"`
+	}{CouponFee: 1}
+	a.NotError(Map2XMLObj(m, obj))
+	a.Equal(obj.ReturnCode, "SUCCESS").
+		Equal(obj.CouponFee, 1).
+		Equal(obj.TotalFee, 5)
+}
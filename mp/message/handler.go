@@ -64,11 +64,11 @@ func (b *HandlerBus) Handler(m Messager) ([]byte, error) {
 			return nil, fmt.Errorf("事件[%v]的处理函数不存在", event)
 		}
 		return h(m)
-	} else {
-		h, found = b.eventHandlers[typ]
-		if !found { // 消息处理函数不存在的情况下，实行转发
-			h = TransferCustomerService
-		}
+	}
+
+	h, found = b.messageHandlers[typ]
+	if !found { // 消息处理函数不存在的情况下，实行转发
+		h = TransferCustomerService
 	}
 
 	return h(m)
@@ -4,4 +4,43 @@
 
 package message
 
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
 var _ Handler = TransferCustomerService
+
+func TestHandlerBus_Handler(t *testing.T) {
+	a := assert.New(t, false)
+
+	reply := func(s string) Handler {
+		return func(Messager) ([]byte, error) { return []byte(s), nil }
+	}
+	b := NewHandlerBus()
+	b.RegisterMessage(TypeText, reply("text"))
+	b.RegisterEvent(EventTypeSubscribe, reply("subscribe"))
+
+	msg, err := getMessageObj([]byte(`<xml><MsgType>text</MsgType></xml>`))
+	a.NotError(err)
+	bs, err := b.Handler(msg)
+	a.NotError(err).Equal(string(bs), "text")
+
+	msg, err = getMessageObj([]byte(`<xml><MsgType>event</MsgType><Event>subscribe</Event></xml>`))
+	a.NotError(err)
+	bs, err = b.Handler(msg)
+	a.NotError(err).Equal(string(bs), "subscribe")
+
+	// 未注册的消息转发至客服
+	msg, err = getMessageObj([]byte(`<xml><MsgType>image</MsgType></xml>`))
+	a.NotError(err)
+	bs, err = b.Handler(msg)
+	a.NotError(err).NotEqual(string(bs), "text")
+
+	// 未注册的事件
+	msg, err = getMessageObj([]byte(`<xml><MsgType>event</MsgType><Event>CLICK</Event></xml>`))
+	a.NotError(err)
+	_, err = b.Handler(msg)
+	a.Error(err)
+}
@@ -46,8 +46,8 @@ func TestGetMessageObj(t *testing.T) {
 
 	msg, err := getMessageObj(data)
 	a.NotError(err)
-	_, ok := msg.(*EventScan)
-	a.True(ok)
+	obj1, ok := msg.(*EventScan)
+	a.True(ok).False(obj1.IsScan())
 
 	// 消息
 	data = []byte(`<xml>
@@ -55,10 +55,11 @@ func TestGetMessageObj(t *testing.T) {
 	<FromUserName><![CDATA[dddadfaee]]></FromUserName>
 	<CreateTime>12345555</CreateTime>
 	<MsgType><![CDATA[text]]></MsgType>
+	<Content><![CDATA[cc]]></Content>
 	</xml>`)
 
 	msg, err = getMessageObj(data)
 	a.NotError(err)
-	_, ok = msg.(*Text)
-	a.True(ok)
+	obj2, ok := msg.(*Text)
+	a.True(ok).Equal(obj2.Content, "cc")
 }
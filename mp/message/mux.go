@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Mux 多个公众号共用同一个地址的消息管理服务器
+//
+// 根据请求地址的最后一段路径或是消息中的 ToUserName 将请求分发给对应的 [Server]，
+// 每个 [Server] 都可以有自己的令牌、加解密对象和处理函数。
+// 可以在运行时通过 [Mux.Add] 和 [Mux.Remove] 添加和删除公众号。
+type Mux struct {
+	mu        sync.RWMutex
+	servers   map[string]*muxEntry // 以名称为键名
+	usernames map[string]*muxEntry // 以原始 ID 为键名
+}
+
+type muxEntry struct {
+	name     string
+	username string
+	srv      *Server
+}
+
+// 用于从消息中获取 ToUserName，安全模式下该字段也是明文的。
+type toUser struct {
+	ToUserName string `xml:"ToUserName"`
+}
+
+// NewMux 声明 [Mux] 实例
+func NewMux() *Mux {
+	return &Mux{
+		servers:   make(map[string]*muxEntry, 10),
+		usernames: make(map[string]*muxEntry, 10),
+	}
+}
+
+// Add 添加公众号
+//
+// name 为公众号的名称，对应请求地址的最后一段路径，比如 /wechat/{name}；
+// username 为公众号的原始 ID，即消息中的 ToUserName，可以为空；
+// 两者都不能与已有的公众号相同。
+func (m *Mux) Add(name, username string, srv *Server) error {
+	if name == "" {
+		return errors.New("参数 name 不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.servers[name]; found {
+		return fmt.Errorf("已经存在同名的公众号 %s", name)
+	}
+	if _, found := m.usernames[username]; found && username != "" {
+		return fmt.Errorf("已经存在原始 ID 为 %s 的公众号", username)
+	}
+
+	e := &muxEntry{name: name, username: username, srv: srv}
+	m.servers[name] = e
+	if username != "" {
+		m.usernames[username] = e
+	}
+	return nil
+}
+
+// Remove 删除公众号
+func (m *Mux) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, found := m.servers[name]; found {
+		delete(m.servers, name)
+		delete(m.usernames, e.username)
+	}
+}
+
+// Server 获取指定名称的 [Server]，不存在时返回 nil。
+func (m *Mux) Server(name string) *Server {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if e, found := m.servers[name]; found {
+		return e.srv
+	}
+	return nil
+}
+
+// ServeHTTP 分发请求
+//
+// 优先根据请求地址的最后一段路径查找，找不到且为 POST 请求时，
+// 再根据消息中的 ToUserName 查找，都不存在则返回 404。
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimRight(r.URL.Path, "/")
+	name := path[strings.LastIndexByte(path, '/')+1:]
+	if srv := m.Server(name); srv != nil {
+		srv.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	u := &toUser{}
+	if err := xml.Unmarshal(data, u); err != nil || u.ToUserName == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	m.mu.RLock()
+	e, found := m.usernames[u.ToUserName]
+	m.mu.RUnlock()
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	e.srv.ServeHTTP(w, r)
+}
@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestMux(t *testing.T) {
+	a := assert.New(t, false)
+	m := NewMux()
+
+	reply := func(s string) Handler {
+		return func(Messager) ([]byte, error) { return []byte(s), nil }
+	}
+	s1 := NewServer(testToken, reply("s1"), nil)
+	s2 := NewServer(testToken, reply("s2"), nil)
+
+	a.NotError(m.Add("s1", "gh_s1", s1))
+	a.NotError(m.Add("s2", "", s2))
+	a.Error(m.Add("s1", "", s2)).
+		Error(m.Add("s3", "gh_s1", s2)).
+		Error(m.Add("", "gh_s3", s2))
+	a.Equal(m.Server("s1"), s1).Nil(m.Server("s3"))
+
+	body := []byte(`<xml><ToUserName>gh_s1</ToUserName><MsgType>text</MsgType></xml>`)
+
+	// 根据路径
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, newRequest(http.MethodPost, "/wechat/s2", "", body))
+	a.Equal(w.Code, http.StatusOK).Equal(w.Body.String(), "s2")
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, newRequest(http.MethodGet, "/wechat/s2/", "", nil))
+	a.Equal(w.Code, http.StatusOK).Equal(w.Body.String(), "echo")
+
+	// 根据 ToUserName
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, newRequest(http.MethodPost, "/wechat", "", body))
+	a.Equal(w.Code, http.StatusOK).Equal(w.Body.String(), "s1")
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, newRequest(http.MethodGet, "/wechat", "", nil))
+	a.Equal(w.Code, http.StatusNotFound)
+
+	// 删除
+	m.Remove("s1")
+	a.Nil(m.Server("s1"))
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, newRequest(http.MethodPost, "/wechat", "", body))
+	a.Equal(w.Code, http.StatusNotFound)
+	a.NotError(m.Add("s3", "gh_s1", s1))
+}
@@ -7,16 +7,29 @@ package message
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/xml"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"sort"
+	"strings"
+
+	"github.com/issue9/wechat/open/crypto"
+)
+
+const encryptTypeAES = "aes"
+
+var (
+	errCryptoNotSet        = errors.New("未指定 Crypto，无法处理安全模式的消息")
+	errInvalidMsgSignature = errors.New("无效的 msg_signature")
 )
 
 // Server 消息管理服务器。
 type Server struct {
 	token   string
+	crypto  *crypto.Crypto
 	handler Handler
 	errlog  *log.Logger
 }
@@ -26,6 +39,14 @@ type Server struct {
 // 若将 h 参数指定为 nil，则会被自动赋予 TransferCustomerService 函数。
 // 若将 errlog 指定为 nil，则会将错误信息输出到 stderr 中。
 func NewServer(token string, h Handler, errlog *log.Logger) *Server {
+	return NewCryptoServer(token, nil, h, errlog)
+}
+
+// NewCryptoServer 声明一个支持安全模式的消息管理服务器
+//
+// c 用于安全模式下的加解密，若为空，则只能处理明文模式的消息，
+// 其它参数与 [NewServer] 相同。
+func NewCryptoServer(token string, c *crypto.Crypto, h Handler, errlog *log.Logger) *Server {
 	if errlog == nil {
 		errlog = log.New(os.Stderr, "", log.Lshortfile|log.Ltime)
 	}
@@ -36,28 +57,55 @@ func NewServer(token string, h Handler, errlog *log.Logger) *Server {
 
 	return &Server{
 		token:   token,
+		crypto:  c,
 		handler: h,
 		errlog:  errlog,
 	}
 }
 
+// ServeHTTP 根据请求方法调用 [Server.Signature] 或是 [Server.Message]
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.Signature(w, r)
+	case http.MethodPost:
+		s.Message(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 // Signature 验证签名，GET 方法
 func (s *Server) Signature(w http.ResponseWriter, r *http.Request) {
-	signature := r.FormValue("signature")
-	timestamp := r.FormValue("timestamp")
-	nonce := r.FormValue("nonce")
-
-	if signature == sign(s.token, timestamp, nonce) {
-		w.Write([]byte(r.FormValue("echostr")))
+	if !s.verify(r) {
+		w.WriteHeader(http.StatusForbidden)
 		return
 	}
+
+	w.Write([]byte(r.FormValue("echostr")))
 }
 
 // Message 消息处理，POST 方法
+//
+// 安全模式下，回复的内容也会被加密。
 func (s *Server) Message(w http.ResponseWriter, r *http.Request) {
-	obj, err := getObj(r.Body)
+	if !s.verify(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	encrypted := r.FormValue("encrypt_type") == encryptTypeAES
+	data, err := s.read(r, encrypted)
+	if err != nil {
+		s.errlog.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	obj, err := getMessageObj(data)
 	if err != nil {
 		s.errlog.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
@@ -67,28 +115,57 @@ func (s *Server) Message(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if encrypted && len(bs) > 0 && string(bs) != string(ReplySuccess) {
+		if bs, _, err = s.crypto.Encrypt(bs, r.FormValue("timestamp"), r.FormValue("nonce")); err != nil {
+			s.errlog.Println(err)
+			return
+		}
+	}
+
 	w.Write(bs)
 }
 
-// sign 微信接口地址验证方法
-func sign(token, timestamp, nonce string) string {
-	strs := sort.StringSlice{token, timestamp, nonce}
-	strs.Sort()
-
-	buf := make([]byte, 0, len(token)+len(timestamp)+len(nonce))
-	buf = append(buf, strs[0]...)
-	buf = append(buf, strs[1]...)
-	buf = append(buf, strs[2]...)
-
-	hash := sha1.Sum(buf)
-	return hex.EncodeToString(hash[:])
+func (s *Server) verify(r *http.Request) bool {
+	return r.FormValue("signature") == sign(s.token, r.FormValue("timestamp"), r.FormValue("nonce"))
 }
 
-func getObj(r io.Reader) (Messager, error) {
-	data, err := io.ReadAll(r)
+// 读取内容，如果是安全模式，返回的是解密之后的内容。
+func (s *Server) read(r *http.Request, encrypted bool) ([]byte, error) {
+	data, err := io.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	return getMessageObj(data)
+	if !encrypted {
+		return data, nil
+	}
+
+	if s.crypto == nil {
+		return nil, errCryptoNotSet
+	}
+
+	// msg_signature 包含了 Encrypt 的内容，用于验证消息体的完整性。
+	env := &struct {
+		Encrypt string `xml:"Encrypt"`
+	}{}
+	if err = xml.Unmarshal(data, env); err != nil {
+		return nil, err
+	}
+	timestamp := r.FormValue("timestamp")
+	nonce := r.FormValue("nonce")
+	if r.FormValue("msg_signature") != sign(s.token, timestamp, nonce, env.Encrypt) {
+		return nil, errInvalidMsgSignature
+	}
+
+	return s.crypto.Decrypt(data, r.FormValue("signature"), timestamp, nonce)
+}
+
+// sign 微信接口地址验证方法
+//
+// 安全模式下的 msg_signature 还需要加上 Encrypt 的内容。
+func sign(strs ...string) string {
+	sort.Strings(strs)
+
+	hash := sha1.Sum([]byte(strings.Join(strs, "")))
+	return hex.EncodeToString(hash[:])
 }
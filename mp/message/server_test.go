@@ -6,34 +6,98 @@ package message
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/issue9/assert/v4"
+	"github.com/issue9/rands/v2"
+
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/wechattest"
+)
+
+const (
+	testToken = "token"
+	testAppID = "wx123458de9ae3rdew"
 )
 
-func TestGetObj(t *testing.T) {
+func newRequest(method, path, encryptType string, body []byte) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "nonce"
+
+	q := url.Values{}
+	q.Set("signature", sign(testToken, timestamp, nonce))
+	q.Set("timestamp", timestamp)
+	q.Set("nonce", nonce)
+	q.Set("echostr", "echo")
+	if encryptType != "" {
+		q.Set("encrypt_type", encryptType)
+	}
+
+	return httptest.NewRequest(method, path+"?"+q.Encode(), bytes.NewReader(body))
+}
+
+func TestServer_Signature(t *testing.T) {
 	a := assert.New(t, false)
+	srv := NewServer(testToken, nil, nil)
 
-	data := []byte(`<xml>
-	<MsgType>event</MsgType>
-	<Event>subscribe</Event>
-	</xml>`)
-	buf := bytes.NewReader(data)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodGet, "/message", "", nil))
+	a.Equal(w.Code, http.StatusOK).Equal(w.Body.String(), "echo")
 
-	msg, err := getObj(buf)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/message?signature=1&echostr=echo", nil))
+	a.Equal(w.Code, http.StatusForbidden).Empty(w.Body.String())
+}
+
+func TestServer_Message(t *testing.T) {
+	a := assert.New(t, false)
+
+	var content string
+	b := NewHandlerBus()
+	b.RegisterMessage(TypeText, func(m Messager) ([]byte, error) {
+		content = m.(*Text).Content
+		return []byte("<xml><Content>reply</Content></xml>"), nil
+	})
+
+	c, err := crypto.New(testAppID, testToken, rands.String(43, 44, rands.AlphaNumber()))
 	a.NotError(err)
-	obj1, ok := msg.(*EventScan)
-	a.True(ok).False(obj1.IsScan())
+	srv := NewCryptoServer(testToken, c, b.Handler, nil)
 
-	// 消息
-	data = []byte(`<xml>
-	<MsgType>text</MsgType>
-	<Content>cc</Content>
-	</xml>`)
-	buf = bytes.NewReader(data)
+	// 明文模式
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodPost, "/message", "", []byte(`<xml><MsgType>text</MsgType><Content>plain</Content></xml>`)))
+	a.Equal(w.Code, http.StatusOK).
+		Equal(w.Body.String(), "<xml><Content>reply</Content></xml>").
+		Equal(content, "plain")
 
-	_, err = getObj(buf)
+	// 安全模式，回复的内容也是加密的。
+	body := []byte(`<xml><MsgType>text</MsgType><Content>aes</Content></xml>`)
+	reply, err := wechattest.NewCallback(srv, testToken, c).Post(body)
+	a.NotError(err).
+		Equal(reply.Status, http.StatusOK).
+		True(reply.Encrypted).
+		Equal(string(reply.Body), "<xml><Content>reply</Content></xml>").
+		Equal(content, "aes")
+
+	// 缺少 msg_signature
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	enc, _, err := c.Encrypt(body, timestamp, "nonce")
 	a.NotError(err)
-	//obj2, ok := msg.(*Text)
-	//a.True(ok).Equal(obj2.Content, "cc")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodPost, "/message", "aes", enc))
+	a.Equal(w.Code, http.StatusBadRequest)
+
+	// 无法解析的内容
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodPost, "/message", "", []byte("<xml>")))
+	a.Equal(w.Code, http.StatusBadRequest)
+
+	// 未指定 Crypto
+	reply, err = wechattest.NewCallback(NewServer(testToken, b.Handler, nil), testToken, c).Post(body)
+	a.NotError(err).Equal(reply.Status, http.StatusBadRequest)
 }
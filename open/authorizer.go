@@ -0,0 +1,360 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package open
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+)
+
+// RefreshTokenStore 保存授权方 authorizer_refresh_token 的接口
+//
+// authorizer_refresh_token 仅在授权时返回一次，丢失之后只能让授权方重新授权，
+// 所以需要持久化保存。
+type RefreshTokenStore interface {
+	// 加载所有的 authorizer_refresh_token，键名为授权方的 appid。
+	LoadRefreshTokens() (map[string]string, error)
+
+	// 保存授权方 appid 的 authorizer_refresh_token
+	SaveRefreshToken(appid, refreshToken string) error
+
+	// 删除授权方 appid 的 authorizer_refresh_token
+	DeleteRefreshToken(appid string) error
+}
+
+type fileRefreshTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// AuthorizerManager 管理所有授权方的 authorizer_access_token
+//
+// 每个授权方都对应一个 token.Server 实例，
+// 可以直接传递给 mp 和 weapp 下的接口，以授权方的身份调用这些接口。
+type AuthorizerManager struct {
+	component *ComponentServer
+	store     RefreshTokenStore
+	errlog    *log.Logger
+
+	mu      sync.RWMutex
+	servers map[string]*authorizerServer // 以授权方的 appid 为键名
+}
+
+// 授权方的 token.Server 实现
+type authorizerServer struct {
+	m       *AuthorizerManager
+	conf    *common.Config
+	mu      sync.Mutex
+	refresh string // authorizer_refresh_token
+	token   *token.AccessToken
+	failed  time.Time // 最后一次刷新失败的时间
+}
+
+// NewFileRefreshTokenStore 将 authorizer_refresh_token 以 JSON 格式保存在文件 path 中的 [RefreshTokenStore]
+func NewFileRefreshTokenStore(path string) RefreshTokenStore {
+	return &fileRefreshTokenStore{path: path}
+}
+
+func (s *fileRefreshTokenStore) LoadRefreshTokens() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *fileRefreshTokenStore) load() (map[string]string, error) {
+	tokens := make(map[string]string, 10)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *fileRefreshTokenStore) SaveRefreshToken(appid, refreshToken string) error {
+	return s.update(func(tokens map[string]string) { tokens[appid] = refreshToken })
+}
+
+func (s *fileRefreshTokenStore) DeleteRefreshToken(appid string) error {
+	return s.update(func(tokens map[string]string) { delete(tokens, appid) })
+}
+
+func (s *fileRefreshTokenStore) update(f func(map[string]string)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	f(tokens)
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件再重命名，防止写入中断导致所有的 authorizer_refresh_token 丢失。
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".refresh-tokens-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// NewAuthorizerManager 声明 [AuthorizerManager] 实例
+//
+// store 用于保存 authorizer_refresh_token，为空表示仅保存在内存中；
+// 若将 errlog 指定为 nil，则会将错误信息输出到 stderr 中。
+//
+// store 中已有的授权方，会在第一次调用 token.Server.Token 时获取 authorizer_access_token，
+// 也可以通过 [AuthorizerManager.Schedule] 定时刷新。
+func NewAuthorizerManager(component *ComponentServer, store RefreshTokenStore, errlog *log.Logger) (*AuthorizerManager, error) {
+	if errlog == nil {
+		errlog = log.Default()
+	}
+
+	m := &AuthorizerManager{
+		component: component,
+		store:     store,
+		errlog:    errlog,
+		servers:   make(map[string]*authorizerServer, 10),
+	}
+
+	if store != nil {
+		tokens, err := store.LoadRefreshTokens()
+		if err != nil {
+			return nil, err
+		}
+		for appid, refresh := range tokens {
+			m.servers[appid] = m.newServer(appid, refresh, nil)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *AuthorizerManager) newServer(appid, refresh string, t *token.AccessToken) *authorizerServer {
+	conf := m.component.Config()
+	return &authorizerServer{
+		m: m,
+		conf: &common.Config{
+			AppID:  appid,
+			Host:   conf.Host,
+			Scheme: conf.Scheme,
+		},
+		refresh: refresh,
+		token:   t,
+	}
+}
+
+// Authorize 使用授权码完成授权
+//
+// authorizationCode 为授权之后回调地址中的授权码，可以通过 [ParseAuthorizationCode] 获取。
+// 返回授权方的 token.Server，同一授权方多次授权，会覆盖之前的内容。
+func (m *AuthorizerManager) Authorize(authorizationCode string) (token.Server, error) {
+	q, err := m.component.QueryAuth(authorizationCode)
+	if err != nil {
+		return nil, err
+	}
+	info := q.AuthorizationInfo
+	if info == nil || info.AppID == "" {
+		return nil, errors.New("授权信息中缺少 authorizer_appid")
+	}
+
+	if m.store != nil {
+		if err := m.store.SaveRefreshToken(info.AppID, info.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	srv := m.newServer(info.AppID, info.RefreshToken, &token.AccessToken{
+		AccessToken: info.AccessToken,
+		ExpiresIn:   time.Duration(info.ExpiresIn),
+		Created:     time.Now(),
+	})
+
+	m.mu.Lock()
+	m.servers[info.AppID] = srv
+	m.mu.Unlock()
+	return srv, nil
+}
+
+// Server 获取授权方 appid 的 token.Server
+func (m *AuthorizerManager) Server(appid string) (token.Server, error) {
+	m.mu.RLock()
+	srv, found := m.servers[appid]
+	m.mu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("授权方 %s 不存在", appid)
+	}
+	return srv, nil
+}
+
+// AppIDs 所有授权方的 appid
+func (m *AuthorizerManager) AppIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.servers))
+	for appid := range m.servers {
+		ids = append(ids, appid)
+	}
+	return ids
+}
+
+// Remove 删除授权方
+//
+// 一般在收到取消授权的推送之后调用。
+func (m *AuthorizerManager) Remove(appid string) error {
+	m.mu.Lock()
+	delete(m.servers, appid)
+	m.mu.Unlock()
+
+	if m.store != nil {
+		return m.store.DeleteRefreshToken(appid)
+	}
+	return nil
+}
+
+// RefreshAll 刷新所有即将过期的 authorizer_access_token
+//
+// 刷新失败的授权方会被记录到 errlog，并不会中断对其它授权方的刷新。
+func (m *AuthorizerManager) RefreshAll() {
+	m.mu.RLock()
+	servers := make([]*authorizerServer, 0, len(m.servers))
+	for _, srv := range m.servers {
+		servers = append(servers, srv)
+	}
+	m.mu.RUnlock()
+
+	for _, srv := range servers {
+		srv.mu.Lock()
+		if srv.expired() {
+			if _, err := srv.refreshLocked(); err != nil {
+				m.errlog.Println(srv.conf.AppID, err)
+			}
+		}
+		srv.mu.Unlock()
+	}
+}
+
+// Schedule 每隔 dur 调用一次 [AuthorizerManager.RefreshAll]
+//
+// 返回的函数用于停止定时刷新。
+func (m *AuthorizerManager) Schedule(dur time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(dur)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.RefreshAll()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Token 获取 authorizer_access_token
+//
+// 如果尚未获取或是即将过期，会先进行刷新；
+// 刷新失败时返回旧值，此时调用接口会返回相应的错误信息。
+// 刷新失败之后的 minRefreshDelay 时间内不会再次刷新，以免每次调用都请求微信的接口。
+func (s *authorizerServer) Token() *token.AccessToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expired() && time.Since(s.failed) >= minRefreshDelay {
+		if _, err := s.refreshLocked(); err != nil {
+			s.m.errlog.Println(s.conf.AppID, err)
+		}
+	}
+
+	if s.token == nil { // 保证 token.URL 等函数不会因为空值而崩溃
+		return &token.AccessToken{}
+	}
+	return s.token
+}
+
+func (s *authorizerServer) Refresh() (*token.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshLocked()
+}
+
+func (s *authorizerServer) Config() *common.Config { return s.conf }
+
+// 是否需要刷新，提前 10 分钟刷新。
+func (s *authorizerServer) expired() bool {
+	if s.token == nil {
+		return true
+	}
+	return time.Since(s.token.Created) >= time.Duration(s.token.ExpiresIn-600)*time.Second
+}
+
+// 刷新 authorizer_access_token，调用者需要负责加锁。
+func (s *authorizerServer) refreshLocked() (*token.AccessToken, error) {
+	t, err := s.m.component.AuthorizerToken(s.conf.AppID, s.refresh)
+	if err != nil {
+		s.failed = time.Now()
+		return nil, err
+	}
+	s.failed = time.Time{}
+
+	// 旧的 authorizer_refresh_token 可能已经失效，所以先更新内存中的值，
+	// 即使保存失败，也不影响当前进程的后续刷新。
+	s.token = &token.AccessToken{
+		AccessToken: t.AccessToken,
+		ExpiresIn:   time.Duration(t.ExpiresIn),
+		Created:     time.Now(),
+	}
+
+	if t.RefreshToken != "" && t.RefreshToken != s.refresh {
+		s.refresh = t.RefreshToken
+		if s.m.store != nil {
+			if err := s.m.store.SaveRefreshToken(s.conf.AppID, t.RefreshToken); err != nil {
+				s.m.errlog.Println(s.conf.AppID, err)
+			}
+		}
+	}
+
+	return s.token, nil
+}
+
+// 保证 authorizerServer 实现了 token.Server
+var _ token.Server = &authorizerServer{}
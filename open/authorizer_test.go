@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package open
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+	"github.com/issue9/rands/v2"
+
+	"github.com/issue9/wechat/mp/template"
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/wechattest"
+)
+
+// 声明一个已经收到 component_verify_ticket 的 ComponentServer
+func newTestComponentServer(a *assert.Assertion, srv *wechattest.Server) *ComponentServer {
+	c, err := crypto.New(wechattest.ComponentAppID, testToken, rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err)
+	cs, err := NewComponentServer(newComponentConfig(srv), c, nil, nil)
+	a.NotError(err)
+
+	_, err = wechattest.NewCallback(cs, testToken, c).VerifyTicket(wechattest.ComponentAppID, wechattest.ComponentVerifyTicket)
+	a.NotError(err)
+	waitToken(a, cs)
+	return cs
+}
+
+func TestFileRefreshTokenStore(t *testing.T) {
+	a := assert.New(t, false)
+	dir := t.TempDir()
+	store := NewFileRefreshTokenStore(filepath.Join(dir, "tokens.json"))
+
+	tokens, err := store.LoadRefreshTokens()
+	a.NotError(err).Empty(tokens)
+
+	a.NotError(store.SaveRefreshToken("app1", "t1"))
+	a.NotError(store.SaveRefreshToken("app2", "t2"))
+	a.NotError(store.SaveRefreshToken("app1", "t3"))
+	tokens, err = store.LoadRefreshTokens()
+	a.NotError(err).Equal(tokens, map[string]string{"app1": "t3", "app2": "t2"})
+
+	a.NotError(store.DeleteRefreshToken("app1"))
+	tokens, err = store.LoadRefreshTokens()
+	a.NotError(err).Equal(tokens, map[string]string{"app2": "t2"})
+
+	// 不会残留临时文件
+	entries, err := os.ReadDir(dir)
+	a.NotError(err).Length(entries, 1).Equal(entries[0].Name(), "tokens.json")
+	info, err := entries[0].Info()
+	a.NotError(err).Equal(info.Mode().Perm(), os.FileMode(0o600))
+
+	// 目录不存在
+	store = NewFileRefreshTokenStore(filepath.Join(dir, "not-exists", "tokens.json"))
+	a.Error(store.SaveRefreshToken("app1", "t1"))
+}
+
+func TestAuthorizerManager(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+
+	cs := newTestComponentServer(a, srv)
+	defer cs.Close()
+	store := NewFileRefreshTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	m, err := NewAuthorizerManager(cs, store, nil)
+	a.NotError(err).Empty(m.AppIDs())
+
+	_, err = m.Server("wx-authorizer")
+	a.Error(err)
+
+	tksrv, err := m.Authorize(srv.Authorize("wx-authorizer"))
+	a.NotError(err).
+		Equal(tksrv.Config().AppID, "wx-authorizer").
+		NotEmpty(tksrv.Token().AccessToken)
+	a.Equal(m.AppIDs(), []string{"wx-authorizer"})
+	tokens, err := store.LoadRefreshTokens()
+	a.NotError(err).Length(tokens, 1)
+
+	// 以授权方的身份调用公众号的接口
+	data := template.Data{"first": template.KV{Value: "v"}}
+	a.NotError(template.Send(tksrv, "openid", "tplid", "", data))
+
+	// 从 store 中恢复，第一次调用时获取 authorizer_access_token。
+	m, err = NewAuthorizerManager(cs, store, nil)
+	a.NotError(err)
+	tksrv, err = m.Server("wx-authorizer")
+	a.NotError(err)
+	a.NotError(template.Send(tksrv, "openid", "tplid", "", data))
+
+	// 过期之后刷新
+	srv.ExpireTokens()
+	_, err = cs.Refresh()
+	a.NotError(err)
+	a.Error(template.Send(tksrv, "openid", "tplid", "", data))
+	_, err = tksrv.Refresh()
+	a.NotError(err)
+	a.NotError(template.Send(tksrv, "openid", "tplid", "", data))
+
+	// 定时刷新
+	srv2 := m.servers["wx-authorizer"]
+	srv2.mu.Lock()
+	old := srv2.token.AccessToken
+	srv2.token.Created = time.Now().Add(-2 * time.Hour)
+	srv2.mu.Unlock()
+	stop := m.Schedule(10 * time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	stop()
+	srv2.mu.Lock()
+	a.NotEqual(srv2.token.AccessToken, old)
+	srv2.mu.Unlock()
+
+	// 取消授权
+	srv.Unauthorize("wx-authorizer")
+	_, err = tksrv.Refresh()
+	a.Error(err)
+	a.NotError(m.Remove("wx-authorizer"))
+	a.Empty(m.AppIDs())
+	tokens, err = store.LoadRefreshTokens()
+	a.NotError(err).Empty(tokens)
+}
+
+// fail 为 true 时，保存会出错的 RefreshTokenStore
+type failedStore struct {
+	RefreshTokenStore
+	fail bool
+}
+
+func (s *failedStore) SaveRefreshToken(appid, refreshToken string) error {
+	if s.fail {
+		return errors.New("failed")
+	}
+	return s.RefreshTokenStore.SaveRefreshToken(appid, refreshToken)
+}
+
+func TestAuthorizerServer_refreshLocked(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+
+	cs := newTestComponentServer(a, srv)
+	defer cs.Close()
+	store := &failedStore{RefreshTokenStore: NewFileRefreshTokenStore(filepath.Join(t.TempDir(), "tokens.json"))}
+	m, err := NewAuthorizerManager(cs, store, log.New(io.Discard, "", 0))
+	a.NotError(err)
+
+	tksrv, err := m.Authorize(srv.Authorize("wx-authorizer"))
+	a.NotError(err)
+
+	// 保存失败，依然会更新内存中的令牌。
+	store.fail = true
+	t1, err := tksrv.Refresh()
+	a.NotError(err).NotNil(t1)
+	a.Equal(tksrv.Token(), t1)
+
+	t2, err := tksrv.Refresh()
+	a.NotError(err).NotEqual(t2.AccessToken, t1.AccessToken)
+	a.NotError(template.Send(tksrv, "openid", "tplid", "", template.Data{}))
+}
+
+func TestAuthorizerServer_Token(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+
+	cs := newTestComponentServer(a, srv)
+	defer cs.Close()
+	m, err := NewAuthorizerManager(cs, nil, log.New(io.Discard, "", 0))
+	a.NotError(err)
+
+	tksrv, err := m.Authorize(srv.Authorize("wx-authorizer"))
+	a.NotError(err)
+	as := tksrv.(*authorizerServer)
+
+	// 刷新失败
+	as.token = nil
+	srv.InjectError(61003)
+	a.Empty(tksrv.Token().AccessToken)
+	a.False(as.failed.IsZero())
+
+	// 刷新失败之后的 minRefreshDelay 内不再刷新
+	a.Empty(tksrv.Token().AccessToken)
+
+	// 超过 minRefreshDelay 之后再次刷新
+	as.failed = time.Now().Add(-minRefreshDelay)
+	a.NotEmpty(tksrv.Token().AccessToken)
+	a.True(as.failed.IsZero())
+
+	// 主动调用 Refresh 不受影响
+	srv.InjectError(61003)
+	_, err = tksrv.Refresh()
+	a.Error(err)
+	t1, err := tksrv.Refresh()
+	a.NotError(err).NotNil(t1)
+	a.Equal(tksrv.Token(), t1)
+}
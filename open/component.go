@@ -7,7 +7,6 @@ package open
 import (
 	"bytes"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
@@ -95,7 +94,7 @@ func GetComponentAccessToken(appid, appsecret, verityTicket string) (token strin
 		Expired int    `json:"expires_in"`
 	}
 
-	data, err := xml.Marshal(&request{
+	data, err := json.Marshal(&request{
 		AppID:  appid,
 		Secret: appsecret,
 		Ticket: verityTicket,
@@ -116,7 +115,7 @@ func GetComponentAccessToken(appid, appsecret, verityTicket string) (token strin
 	defer resp.Body.Close()
 
 	obj := &response{}
-	if err = xml.Unmarshal(data, obj); err != nil {
+	if err = json.Unmarshal(data, obj); err != nil {
 		return "", 0, err
 	}
 	return obj.Token, obj.Expired, nil
@@ -149,7 +148,7 @@ func GetQueryAuth(appid, authorizationCode, componentAccessToken string) (*Query
 		Code  string `json:"authorization_code"`
 	}
 
-	data, err := xml.Marshal(&request{
+	data, err := json.Marshal(&request{
 		AppID: appid,
 		Code:  authorizationCode,
 	})
@@ -170,7 +169,7 @@ func GetQueryAuth(appid, authorizationCode, componentAccessToken string) (*Query
 	defer resp.Body.Close()
 
 	obj := &QueryAuth{}
-	if err = xml.Unmarshal(data, obj); err != nil {
+	if err = json.Unmarshal(data, obj); err != nil {
 		return nil, err
 	}
 	return obj, nil
@@ -190,7 +189,7 @@ func GetAuthorizerToken(appid, authorizerAppid, authorizerRefreshToken, componen
 		AuthRefreshToken string `json:"authorizer_refresh_token"`
 	}
 
-	data, err := xml.Marshal(&request{
+	data, err := json.Marshal(&request{
 		AppID:            appid,
 		AuthAppid:        authorizerAppid,
 		AuthRefreshToken: authorizerRefreshToken,
@@ -212,7 +211,7 @@ func GetAuthorizerToken(appid, authorizerAppid, authorizerRefreshToken, componen
 	defer resp.Body.Close()
 
 	obj := &AuthorizerToken{}
-	if err = xml.Unmarshal(data, obj); err != nil {
+	if err = json.Unmarshal(data, obj); err != nil {
 		return nil, err
 	}
 	return obj, nil
@@ -238,7 +237,7 @@ func GetAuthorizerInfo(appid, authorizerAppid, componentAccessToken string) (*Au
 		AuthAppid string `json:"authorizer_appid"`
 	}
 
-	data, err := xml.Marshal(&request{
+	data, err := json.Marshal(&request{
 		AppID:     appid,
 		AuthAppid: authorizerAppid,
 	})
@@ -259,7 +258,7 @@ func GetAuthorizerInfo(appid, authorizerAppid, componentAccessToken string) (*Au
 	defer resp.Body.Close()
 
 	obj := &AuthorizerObj{}
-	if err = xml.Unmarshal(data, obj); err != nil {
+	if err = json.Unmarshal(data, obj); err != nil {
 		return nil, err
 	}
 	return obj, nil
@@ -11,6 +11,7 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -28,10 +29,12 @@ const messageFormat = `<xml>
 <Nonce><![CDATA[%s]]></Nonce>
 </xml>`
 
+var errInvalidCiphertext = errors.New("无效的加密内容")
+
 type receiver struct {
 	Root       xml.Name `xml:"xml"`
-	ToUserName string   `xml:"ToUserName"`
-	Encrypt    string   `xml:"Encrypt"`
+	ToUserName string   `xml:"ToUserName" json:"ToUserName"`
+	Encrypt    string   `xml:"Encrypt" json:"Encrypt"`
 }
 
 // Crypto 加解密功能
@@ -106,22 +109,54 @@ func (c *Crypto) DecryptObject(body []byte, sign, timestamp, nonce string, objec
 
 // Decrypt 解密 XML 内容
 func (c *Crypto) Decrypt(body []byte, sign, timestamp, nonce string) ([]byte, error) {
-	if timestamp == "" {
-		timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	if err := c.verify(sign, timestamp, nonce); err != nil {
+		return nil, err
 	}
 
-	if sha1Sign(c.token, timestamp, nonce) != sign {
-		return nil, errors.New("签名不同")
+	r := &receiver{}
+	if err := xml.Unmarshal(body, r); err != nil {
+		return nil, err
+	}
+
+	return c.decrypt([]byte(r.Encrypt))
+}
+
+// DecryptJSON 解密 JSON 内容
+//
+// 小程序的消息推送可以采用 JSON 格式，其加密后的格式为
+// {"ToUserName":"...","Encrypt":"..."}，返回解密后的 JSON 内容。
+func (c *Crypto) DecryptJSON(body []byte, sign, timestamp, nonce string) ([]byte, error) {
+	if err := c.verify(sign, timestamp, nonce); err != nil {
+		return nil, err
 	}
 
 	r := &receiver{}
-	if err := xml.Unmarshal(body, r); err != nil {
+	if err := json.Unmarshal(body, r); err != nil {
 		return nil, err
 	}
 
 	return c.decrypt([]byte(r.Encrypt))
 }
 
+// MsgSignature 计算安全模式下的 msg_signature
+//
+// encrypt 为推送内容中 Encrypt 字段的值，
+// msg_signature 包含了 Encrypt 的内容，可用于验证消息体的完整性。
+func (c *Crypto) MsgSignature(timestamp, nonce, encrypt string) string {
+	return sha1Sign(c.token, timestamp, nonce, encrypt)
+}
+
+func (c *Crypto) verify(sign, timestamp, nonce string) error {
+	if timestamp == "" {
+		timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+
+	if sha1Sign(c.token, timestamp, nonce) != sign {
+		return errors.New("签名不同")
+	}
+	return nil
+}
+
 // base64Encoding(AES_Encrypt[random(16B) + msg_len(4B) + rawXMLMsg + appId])
 func (c *Crypto) encrypt(xmltext []byte) ([]byte, error) {
 	text := make([]byte, 0, c.plainlen+len(xmltext))
@@ -151,6 +186,9 @@ func (c *Crypto) decrypt(text []byte) ([]byte, error) {
 		return nil, err
 	}
 	dst = dst[:n]
+	if len(dst) == 0 || len(dst)%aes.BlockSize != 0 {
+		return nil, errInvalidCiphertext
+	}
 
 	block, err := aes.NewCipher(c.key)
 	if err != nil {
@@ -161,10 +199,18 @@ func (c *Crypto) decrypt(text []byte) ([]byte, error) {
 	plaintext := make([]byte, len(dst))
 	mode.CryptBlocks(plaintext, dst)
 
-	plaintext = internal.PKCS7UnPadding(plaintext)
+	if plaintext, err = internal.PKCS7UnPadding(plaintext); err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 20 {
+		return nil, errInvalidCiphertext
+	}
 
-	size := decodeNetworkByteOrder(plaintext[16:20])
-	return plaintext[20 : 20+int(size)], nil
+	size := int(decodeNetworkByteOrder(plaintext[16:20]))
+	if size > len(plaintext)-20 {
+		return nil, errInvalidCiphertext
+	}
+	return plaintext[20 : 20+size], nil
 }
 
 // 编码成网络字节（大端）
@@ -187,14 +233,17 @@ func decodeNetworkByteOrder(b []byte) (n uint32) {
 		uint32(b[3])
 }
 
-func sha1Sign(token, timestamp, nonce string) (signature string) {
-	strs := sort.StringSlice{token, timestamp, nonce}
-	strs.Sort()
+func sha1Sign(strs ...string) (signature string) {
+	sort.Strings(strs)
 
-	buf := make([]byte, 0, len(token)+len(timestamp)+len(nonce))
-	buf = append(buf, strs[0]...)
-	buf = append(buf, strs[1]...)
-	buf = append(buf, strs[2]...)
+	size := 0
+	for _, s := range strs {
+		size += len(s)
+	}
+	buf := make([]byte, 0, size)
+	for _, s := range strs {
+		buf = append(buf, s...)
+	}
 
 	hashsum := sha1.Sum(buf)
 	return hex.EncodeToString(hashsum[:])
@@ -45,6 +45,20 @@ func TestCrypto_encrypt_decrypt(t *testing.T) {
 	a.NotError(err).NotNil(detext)
 
 	a.Equal(string(detext), msg)
+
+	// 长度不是块大小的整数倍
+	detext, err = c.decrypt([]byte("MTIzNDU="))
+	a.Error(err).Nil(detext)
+
+	// 空内容
+	detext, err = c.decrypt(nil)
+	a.Error(err).Nil(detext)
+
+	// 其它密钥加密的内容
+	c2, err := New("wx123458de9ae3rdew", "token", rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err).NotNil(c2)
+	detext, err = c2.decrypt(text)
+	a.Error(err).Nil(detext)
 }
 
 func TestCrypto_Encrypt_DecryptObject(t *testing.T) {
@@ -72,3 +86,35 @@ func TestCrypto_Encrypt_DecryptObject(t *testing.T) {
 	a.Equal(msgobj.CreateTime, obj.CreateTime)
 	a.Equal(msgobj.Content, obj.Content)
 }
+
+func TestCrypto_DecryptJSON(t *testing.T) {
+	a := assert.New(t, false)
+	c, err := New("wx123458de9ae3rdew", "token", rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err).NotNil(c)
+
+	timesamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := nonceString()
+	msg := `{"ToUserName":"gh_123","MsgType":"text","Content":"示例内容"}`
+
+	text, err := c.encrypt([]byte(msg))
+	a.NotError(err).NotNil(text)
+	body := `{"ToUserName":"gh_123","Encrypt":"` + string(text) + `"}`
+	sign := sha1Sign("token", timesamp, nonce)
+
+	data, err := c.DecryptJSON([]byte(body), sign, timesamp, nonce)
+	a.NotError(err).Equal(string(data), msg)
+
+	// 签名错误
+	data, err = c.DecryptJSON([]byte(body), "sign", timesamp, nonce)
+	a.Error(err).Nil(data)
+}
+
+func TestCrypto_MsgSignature(t *testing.T) {
+	a := assert.New(t, false)
+	c, err := New("wx123458de9ae3rdew", "token", rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err).NotNil(c)
+
+	a.Equal(c.MsgSignature("1", "nonce", "encrypt"), sha1Sign("encrypt", "nonce", "token", "1")).
+		NotEqual(c.MsgSignature("1", "nonce", "encrypt"), c.MsgSignature("1", "nonce", "other")).
+		NotEqual(c.MsgSignature("1", "nonce", "encrypt"), sha1Sign("token", "1", "nonce"))
+}
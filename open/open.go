@@ -6,6 +6,7 @@ package open
 
 import (
 	"encoding/xml"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
@@ -14,8 +15,8 @@ import (
 )
 
 const (
-	getAPIGetAuthorizerOptionURL = "https://api.weixin.qq.com/cgi-bin/component/ api_get_authorizer_option?component_access_token=%s"
-	getAPISetAuthorizerOptionURL = "https://api.weixin.qq.com/cgi-bin/component/ api_set_authorizer_option?component_access_token=%s"
+	getAPIGetAuthorizerOptionURL = "https://api.weixin.qq.com/cgi-bin/component/api_get_authorizer_option?component_access_token=%s"
+	getAPISetAuthorizerOptionURL = "https://api.weixin.qq.com/cgi-bin/component/api_set_authorizer_option?component_access_token=%s"
 )
 
 // 表示几种验证类型
@@ -25,6 +26,8 @@ const (
 	AuthTypeAll   = 3
 )
 
+var errInvalidMsgSignature = errors.New("无效的 msg_signature")
+
 // AuthType 验证类型
 type AuthType int8
 
@@ -50,22 +53,33 @@ type VerifyTicket struct {
 }
 
 // ParseVerifyTicket 处理 component_verify_ticket 事件中返回的数据
+//
+// 会验证 signature 和 msg_signature，验证通过且解密成功之后才会向微信返回 success。
 func ParseVerifyTicket(c *crypto.Crypto, w http.ResponseWriter, r *http.Request) (*VerifyTicket, error) {
-	w.Write([]byte("success")) // 先返回内容
-
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Body.Close()
 
-	ticket := &VerifyTicket{}
-	sign := r.FormValue("signature")
+	// msg_signature 包含了 Encrypt 的内容，用于验证消息体的完整性。
+	env := &struct {
+		Encrypt string `xml:"Encrypt"`
+	}{}
+	if err = xml.Unmarshal(data, env); err != nil {
+		return nil, err
+	}
 	timestamp := r.FormValue("timestamp")
 	nonce := r.FormValue("nonce")
-	if err = c.DecryptObject(data, sign, timestamp, nonce, ticket); err != nil {
+	if r.FormValue("msg_signature") != c.MsgSignature(timestamp, nonce, env.Encrypt) {
+		return nil, errInvalidMsgSignature
+	}
+
+	ticket := &VerifyTicket{}
+	if err = c.DecryptObject(data, r.FormValue("signature"), timestamp, nonce, ticket); err != nil {
 		return nil, err
 	}
 
+	w.Write([]byte("success"))
 	return ticket, nil
 }
@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package open
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+	"github.com/issue9/rands/v2"
+
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestParseVerifyTicket(t *testing.T) {
+	a := assert.New(t, false)
+	c, err := crypto.New(wechattest.ComponentAppID, testToken, rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err)
+
+	var ticket *VerifyTicket
+	var ticketErr error
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket, ticketErr = ParseVerifyTicket(c, w, r)
+	})
+
+	reply, err := wechattest.NewCallback(h, testToken, c).VerifyTicket(wechattest.ComponentAppID, "ticket")
+	a.NotError(err).NotError(ticketErr).Equal(string(reply.Body), "success")
+	a.Equal(ticket.AppID, wechattest.ComponentAppID).
+		Equal(ticket.ComponentVerifyTicket, "ticket")
+
+	// 手动构造请求，以便修改 msg_signature
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	data, _, err := c.Encrypt([]byte(`<xml><InfoType>component_verify_ticket</InfoType><ComponentVerifyTicket>ticket2</ComponentVerifyTicket></xml>`), timestamp, "nonce")
+	a.NotError(err)
+	env := &struct {
+		Encrypt      string `xml:"Encrypt"`
+		MsgSignature string `xml:"MsgSignature"`
+	}{}
+	a.NotError(xml.Unmarshal(data, env))
+
+	post := func(msgSignature string) *httptest.ResponseRecorder {
+		q := url.Values{}
+		q.Set("signature", env.MsgSignature) // 即 sha1(token, timestamp, nonce)
+		q.Set("timestamp", timestamp)
+		q.Set("nonce", "nonce")
+		q.Set("encrypt_type", "aes")
+		if msgSignature != "" {
+			q.Set("msg_signature", msgSignature)
+		}
+
+		ticket, ticketErr = nil, nil
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?"+q.Encode(), bytes.NewReader(data)))
+		return w
+	}
+
+	// 伪造的 msg_signature
+	w := post(c.MsgSignature(timestamp, "nonce", "other"))
+	a.ErrorIs(ticketErr, errInvalidMsgSignature).Nil(ticket).Empty(w.Body.String())
+
+	// 缺少 msg_signature
+	w = post("")
+	a.ErrorIs(ticketErr, errInvalidMsgSignature).Nil(ticket).Empty(w.Body.String())
+
+	// 正确的 msg_signature
+	w = post(c.MsgSignature(timestamp, "nonce", env.Encrypt))
+	a.NotError(ticketErr).Equal(w.Body.String(), "success")
+	a.Equal(ticket.ComponentVerifyTicket, "ticket2")
+}
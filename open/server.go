@@ -0,0 +1,287 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package open
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/open/crypto"
+)
+
+const infoTypeVerifyTicket = "component_verify_ticket"
+
+var errTicketNotFound = errors.New("尚未收到 component_verify_ticket")
+
+// TicketStore 保存 component_verify_ticket 的接口
+//
+// 微信每 10 分钟推送一次 component_verify_ticket，
+// 保存该值可以保证重启之后不必等待下一次推送即可获取 component_access_token。
+type TicketStore interface {
+	// 加载已保存的 component_verify_ticket，不存在时返回空值。
+	LoadTicket() (string, error)
+
+	// 保存最新的 component_verify_ticket
+	SaveTicket(ticket string) error
+}
+
+// ComponentServer 第三方平台的中控服务器
+//
+// 接收微信推送的 component_verify_ticket，并维护 component_access_token 的有效性。
+type ComponentServer struct {
+	conf   *common.Config
+	crypto *crypto.Crypto
+	store  TicketStore
+	errlog *log.Logger
+
+	mu      sync.RWMutex
+	ticket  string
+	token   *token.AccessToken
+	started bool        // 是否已经开始定时刷新
+	closed  bool        // 是否已经停止定时刷新
+	timer   *time.Timer // 下一次刷新的定时器
+}
+
+type fileTicketStore string
+
+// NewFileTicketStore 将 component_verify_ticket 保存在文件 path 中的 [TicketStore]
+func NewFileTicketStore(path string) TicketStore { return fileTicketStore(path) }
+
+func (s fileTicketStore) LoadTicket() (string, error) {
+	data, err := os.ReadFile(string(s))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s fileTicketStore) SaveTicket(ticket string) error {
+	return os.WriteFile(string(s), []byte(ticket), 0o600)
+}
+
+// NewComponentServer 声明第三方平台的中控服务器
+//
+// conf 中的 AppID 和 AppSecret 为第三方平台的 component_appid 和 component_appsecret；
+// c 用于解密推送的内容；
+// store 用于保存 component_verify_ticket，为空表示仅保存在内存中；
+// 若将 errlog 指定为 nil，则会将错误信息输出到 stderr 中。
+//
+// 如果 store 中已经存在 component_verify_ticket，会立即在后台获取 component_access_token，
+// 否则要等到第一次收到推送之后才会获取。不再需要时应该调用 [ComponentServer.Close]。
+func NewComponentServer(conf *common.Config, c *crypto.Crypto, store TicketStore, errlog *log.Logger) (*ComponentServer, error) {
+	if errlog == nil {
+		errlog = log.Default()
+	}
+
+	srv := &ComponentServer{
+		conf:   conf,
+		crypto: c,
+		store:  store,
+		errlog: errlog,
+	}
+
+	if store != nil {
+		ticket, err := store.LoadTicket()
+		if err != nil {
+			return nil, err
+		}
+		if ticket != "" {
+			srv.setTicket(ticket)
+		}
+	}
+
+	return srv, nil
+}
+
+// ServeHTTP 处理 component_verify_ticket 的推送
+//
+// 其它类型的推送会被忽略。
+func (s *ComponentServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ticket, err := ParseVerifyTicket(s.crypto, w, r)
+	if err != nil {
+		s.errlog.Println(err)
+		return
+	}
+
+	if ticket.InfoType != infoTypeVerifyTicket || ticket.ComponentVerifyTicket == "" {
+		return
+	}
+
+	if s.store != nil {
+		if err := s.store.SaveTicket(ticket.ComponentVerifyTicket); err != nil {
+			s.errlog.Println(err)
+		}
+	}
+	s.setTicket(ticket.ComponentVerifyTicket)
+}
+
+// 更新 component_verify_ticket，如果是第一次获取，则开始定时刷新 component_access_token。
+func (s *ComponentServer) setTicket(ticket string) {
+	s.mu.Lock()
+	s.ticket = ticket
+	started := s.started
+	s.started = true
+	s.mu.Unlock()
+
+	if !started { // 推送需要尽快返回，所以在后台获取 component_access_token。
+		go s.refresh()
+	}
+}
+
+// Ticket 最新的 component_verify_ticket
+func (s *ComponentServer) Ticket() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ticket
+}
+
+// Token 获取当前的 component_access_token
+//
+// 在未获取到 component_verify_ticket 之前返回 nil。
+func (s *ComponentServer) Token() *token.AccessToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token
+}
+
+// Refresh 刷新 component_access_token
+func (s *ComponentServer) Refresh() (*token.AccessToken, error) {
+	ticket := s.Ticket()
+	if ticket == "" {
+		return nil, errTicketNotFound
+	}
+
+	req := map[string]string{
+		"component_appid":         s.conf.AppID,
+		"component_appsecret":     s.conf.AppSecret,
+		"component_verify_ticket": ticket,
+	}
+	resp := &struct {
+		Token     string `json:"component_access_token"`
+		ExpiresIn int    `json:"expires_in"`
+	}{}
+	if err := internal.PostJSON(s.conf.URL("cgi-bin/component/api_component_token", nil), req, resp); err != nil {
+		return nil, err
+	}
+
+	t := &token.AccessToken{
+		AccessToken: resp.Token,
+		ExpiresIn:   time.Duration(resp.ExpiresIn),
+		Created:     time.Now(),
+	}
+	s.mu.Lock()
+	s.token = t
+	s.mu.Unlock()
+
+	return t, nil
+}
+
+// Config 获取相关的配置对象
+func (s *ComponentServer) Config() *common.Config {
+	return s.conf
+}
+
+// Close 停止定时刷新 component_access_token
+func (s *ComponentServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// 定时刷新
+func (s *ComponentServer) refresh() {
+	dur := minRefreshDelay // 出错时，一分钟之后重试
+	if t, err := s.Refresh(); err != nil {
+		s.errlog.Println(err)
+	} else {
+		dur = refreshDelay(t)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.timer = time.AfterFunc(dur, s.refresh)
+	}
+}
+
+// 刷新的最短间隔
+const minRefreshDelay = time.Minute
+
+// 计算下一次刷新的时间，提前 10 分钟刷新，但不会少于 minRefreshDelay。
+func refreshDelay(t *token.AccessToken) time.Duration {
+	dur := time.Duration(t.ExpiresIn-600) * time.Second
+	if dur < minRefreshDelay {
+		dur = minRefreshDelay
+	}
+	return dur
+}
+
+// 以 component_access_token 调用第三方平台的接口
+//
+// req 中会自动添加 component_appid 字段。
+func (s *ComponentServer) post(api string, req map[string]string, resp interface{}) error {
+	t := s.Token()
+	if t == nil {
+		return errTicketNotFound
+	}
+
+	req["component_appid"] = s.conf.AppID
+	url := s.conf.URL("cgi-bin/component/"+api, map[string]string{"component_access_token": t.AccessToken})
+	return internal.PostJSON(url, req, resp)
+}
+
+// PreAuthCode 获取预授权码
+func (s *ComponentServer) PreAuthCode() (*PreAuthCode, error) {
+	p := &PreAuthCode{}
+	if err := s.post("api_create_preauthcode", map[string]string{}, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// QueryAuth 使用授权码换取授权方的接口调用凭据和授权信息
+func (s *ComponentServer) QueryAuth(authorizationCode string) (*QueryAuth, error) {
+	q := &QueryAuth{}
+	if err := s.post("api_query_auth", map[string]string{"authorization_code": authorizationCode}, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// AuthorizerToken 获取（刷新）授权方的接口调用凭据
+func (s *ComponentServer) AuthorizerToken(authorizerAppID, refreshToken string) (*AuthorizerToken, error) {
+	req := map[string]string{
+		"authorizer_appid":         authorizerAppID,
+		"authorizer_refresh_token": refreshToken,
+	}
+	t := &AuthorizerToken{}
+	if err := s.post("api_authorizer_token", req, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// AuthorizerInfo 获取授权方的帐号基本信息
+func (s *ComponentServer) AuthorizerInfo(authorizerAppID string) (*AuthorizerObj, error) {
+	obj := &AuthorizerObj{}
+	if err := s.post("api_get_authorizer_info", map[string]string{"authorizer_appid": authorizerAppID}, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package open
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+	"github.com/issue9/rands/v2"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/wechattest"
+)
+
+const testToken = "token"
+
+func newComponentConfig(srv *wechattest.Server) *common.Config {
+	conf := srv.Config()
+	conf.AppID = wechattest.ComponentAppID
+	conf.AppSecret = wechattest.ComponentAppSecret
+	return conf
+}
+
+// 等待后台获取 component_access_token
+func waitToken(a *assert.Assertion, cs *ComponentServer) {
+	for i := 0; i < 100 && cs.Token() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.NotNil(cs.Token())
+}
+
+func TestRefreshDelay(t *testing.T) {
+	a := assert.New(t, false)
+
+	a.Equal(refreshDelay(&token.AccessToken{ExpiresIn: 7200}), 6600*time.Second).
+		Equal(refreshDelay(&token.AccessToken{ExpiresIn: 600}), minRefreshDelay).
+		Equal(refreshDelay(&token.AccessToken{ExpiresIn: 10}), minRefreshDelay)
+}
+
+func TestFileTicketStore(t *testing.T) {
+	a := assert.New(t, false)
+	store := NewFileTicketStore(filepath.Join(t.TempDir(), "ticket"))
+
+	ticket, err := store.LoadTicket()
+	a.NotError(err).Empty(ticket)
+
+	a.NotError(store.SaveTicket("ticket"))
+	ticket, err = store.LoadTicket()
+	a.NotError(err).Equal(ticket, "ticket")
+}
+
+func TestComponentServer(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+
+	c, err := crypto.New(wechattest.ComponentAppID, testToken, rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err)
+	store := NewFileTicketStore(filepath.Join(t.TempDir(), "ticket"))
+	cs, err := NewComponentServer(newComponentConfig(srv), c, store, nil)
+	a.NotError(err).NotNil(cs)
+	defer cs.Close()
+
+	// 未收到推送
+	a.Nil(cs.Token()).Empty(cs.Ticket())
+	_, err = cs.Refresh()
+	a.Equal(err, errTicketNotFound)
+	_, err = cs.PreAuthCode()
+	a.Equal(err, errTicketNotFound)
+
+	// 推送 component_verify_ticket
+	cb := wechattest.NewCallback(cs, testToken, c)
+	reply, err := cb.VerifyTicket(wechattest.ComponentAppID, wechattest.ComponentVerifyTicket)
+	a.NotError(err).Equal(reply.Body, []byte("success"))
+	waitToken(a, cs)
+	a.Equal(cs.Ticket(), wechattest.ComponentVerifyTicket).
+		NotEmpty(cs.Token().AccessToken)
+	ticket, err := store.LoadTicket()
+	a.NotError(err).Equal(ticket, wechattest.ComponentVerifyTicket)
+
+	code, err := cs.PreAuthCode()
+	a.NotError(err).NotEmpty(code.Code)
+
+	// 授权
+	q, err := cs.QueryAuth(srv.Authorize("wx-authorizer"))
+	a.NotError(err).
+		Equal(q.AuthorizationInfo.AppID, "wx-authorizer").
+		NotEmpty(q.AuthorizationInfo.AccessToken).
+		NotEmpty(q.AuthorizationInfo.RefreshToken)
+
+	at, err := cs.AuthorizerToken("wx-authorizer", q.AuthorizationInfo.RefreshToken)
+	a.NotError(err).
+		NotEmpty(at.AccessToken).
+		NotEqual(at.AccessToken, q.AuthorizationInfo.AccessToken)
+
+	info, err := cs.AuthorizerInfo("wx-authorizer")
+	a.NotError(err).Equal(info.Info.Username, "gh_wx-authorizer")
+
+	// 授权码只能使用一次
+	_, err = cs.QueryAuth("invalid-code")
+	a.Equal(err, &common.Result{Code: 61010, Message: "错误代码 61010"})
+
+	// component_access_token 失效之后重新刷新
+	srv.ExpireTokens()
+	_, err = cs.PreAuthCode()
+	a.Error(err)
+	_, err = cs.Refresh()
+	a.NotError(err)
+	_, err = cs.PreAuthCode()
+	a.NotError(err)
+
+	// 从 store 中恢复
+	cs2, err := NewComponentServer(newComponentConfig(srv), c, store, nil)
+	a.NotError(err).Equal(cs2.Ticket(), wechattest.ComponentVerifyTicket)
+	waitToken(a, cs2)
+
+	// 停止之后不再刷新
+	cs2.Close()
+	cs2.mu.RLock()
+	a.True(cs2.closed)
+	cs2.mu.RUnlock()
+}
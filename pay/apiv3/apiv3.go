@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package apiv3 微信支付 APIv3 的相关接口
+//
+// 与 [pay.Pay] 采用的 XML 协议不同，APIv3 采用 JSON 格式的内容，
+// 请求以商户 API 私钥签名，应答则需要以微信支付平台证书或是微信支付公钥验证。
+//
+//	key, err := apiv3.LoadPrivateKey("apiclient_key.pem")
+//	c := apiv3.New(mchid, appid, serialNo, key, nil, nil)
+//
+//	m, err := apiv3.NewCertificateManager(c, apiv3Key, 0, nil)
+//	c.SetVerifier(m)
+//
+//	o := apiv3.NewOrder(c)
+//	o.TradeType = pay.TradeTypeJSAPI
+//	o.NotifyURL = "https://example.com/notify"
+//
+//	order := o.NewOrder()
+//	order.Body = "..."
+//	order.Pay(ctx)
+package apiv3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/pay"
+)
+
+const (
+	host       = "https://api.mch.weixin.qq.com"
+	authSchema = "WECHATPAY2-SHA256-RSA2048"
+)
+
+// Client APIv3 的客户端
+type Client struct {
+	mchID    string
+	appID    string
+	serialNo string
+	key      *rsa.PrivateKey
+	verifier Verifier
+	client   *http.Client
+	host     string
+}
+
+// Error APIv3 返回的错误信息
+type Error struct {
+	Status  int             `json:"-"` // HTTP 状态码
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Detail  json.RawMessage `json:"detail,omitempty"`
+}
+
+// New 声明 APIv3 的客户端
+//
+// serialNo 为商户 API 证书的序列号；key 为商户 API 私钥；
+// v 用于验证应答的签名；client 若为空，则采用 http.DefaultClient。
+func New(mchid, appid, serialNo string, key *rsa.PrivateKey, v Verifier, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{
+		mchID:    mchid,
+		appID:    appid,
+		serialNo: serialNo,
+		key:      key,
+		verifier: v,
+		client:   client,
+		host:     host,
+	}
+}
+
+// MchID 获取商户 ID
+func (c *Client) MchID() string { return c.mchID }
+
+// AppID 获取 appid
+func (c *Client) AppID() string { return c.appID }
+
+// SetVerifier 修改验证应答签名的 [Verifier]
+//
+// 一般用于在声明 [CertificateManager] 之后将其指定为 Client 的 Verifier。
+func (c *Client) SetVerifier(v Verifier) { c.verifier = v }
+
+// Sign 以商户 API 私钥对 message 进行签名
+//
+// 返回 base64 编码的签名内容，可用于调起支付等需要签名的场景。
+func (c *Client) Sign(message string) (string, error) {
+	return sign(c.key, message)
+}
+
+// Do 发送请求
+//
+// method 为请求方法；path 为接口地址，不包含域名，比如 /v3/pay/transactions/jsapi；
+// req 为请求对象，会被转换成 JSON，为空表示没有报文主体；
+// resp 用于接收应答内容，为空表示不需要应答的内容。
+// 如果微信返回了错误信息，则返回 [Error] 类型的错误。
+func (c *Client) Do(ctx context.Context, method, path string, req, resp interface{}) error {
+	body, header, err := c.doHeader(ctx, method, path, req)
+	if err != nil {
+		return err
+	}
+
+	if err = verifyHeader(c.verifier, header, body); err != nil {
+		return err
+	}
+
+	if resp == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, resp)
+}
+
+// 发送请求并返回应答的内容及报头
+//
+// 不会验证应答的签名。
+func (c *Client) doHeader(ctx context.Context, method, path string, req interface{}) ([]byte, http.Header, error) {
+	var body []byte
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = data
+	}
+
+	auth, err := c.authorization(method, path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, c.host+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Header.Set("Authorization", auth)
+	r.Header.Set("Accept", "application/json")
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		e := &Error{Status: resp.StatusCode}
+		if len(data) == 0 || json.Unmarshal(data, e) != nil {
+			e.Code = strconv.Itoa(resp.StatusCode)
+			e.Message = resp.Status
+		}
+		return nil, nil, e
+	}
+
+	return data, resp.Header, nil
+}
+
+// 生成 Authorization 报头的内容
+func (c *Client) authorization(method, path string, body []byte) (string, error) {
+	nonce := pay.NonceString()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	signature, err := c.Sign(buildMessage(method, path, timestamp, nonce, string(body)))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		authSchema, c.mchID, nonce, signature, timestamp, c.serialNo), nil
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+// 模拟微信支付的服务端
+//
+// 验证请求的签名，并以 key 对应答进行签名。
+func newTestServer(a *assert.Assertion, c *Client, key *rsa.PrivateKey, h func(w http.ResponseWriter, r *http.Request, body []byte)) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		a.NotError(err)
+
+		auth := r.Header.Get("Authorization")
+		a.True(strings.HasPrefix(auth, authSchema+" "))
+		params := map[string]string{}
+		for _, item := range strings.Split(strings.TrimPrefix(auth, authSchema+" "), ",") {
+			kv := strings.SplitN(item, "=", 2)
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+		a.Equal(params["mchid"], c.MchID()).Equal(params["serial_no"], c.serialNo)
+		message := buildMessage(r.Method, r.URL.RequestURI(), params["timestamp"], params["nonce_str"], string(body))
+		a.NotError(verify(&c.key.PublicKey, message, params["signature"]))
+
+		rec := httptest.NewRecorder()
+		h(rec, r, body)
+
+		s, err := sign(key, buildMessage("1554208460", "nonce", rec.Body.String()))
+		a.NotError(err)
+		w.Header().Set(HeaderTimestamp, "1554208460")
+		w.Header().Set(HeaderNonce, "nonce")
+		w.Header().Set(HeaderSignature, s)
+		w.Header().Set(HeaderSerial, "platform")
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+
+	c.host = srv.URL
+	return srv
+}
+
+func newTestClient(a *assert.Assertion) (*Client, *rsa.PrivateKey) {
+	platform := newTestKey(a)
+	c := New("mchid", "appid", "serial", newTestKey(a), NewPublicKeyVerifier("platform", &platform.PublicKey), nil)
+	return c, platform
+}
+
+func TestClient_Do(t *testing.T) {
+	a := assert.New(t, false)
+	c, platform := newTestClient(a)
+
+	srv := newTestServer(a, c, platform, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		switch r.URL.Path {
+		case "/ok":
+			a.Equal(r.Header.Get("Content-Type"), "application/json")
+			a.Equal(string(body), `{"id":"1"}`)
+			w.Write([]byte(`{"id":"2"}`))
+		case "/no-content":
+			a.Empty(body)
+			w.WriteHeader(http.StatusNoContent)
+		case "/error":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"PARAM_ERROR","message":"参数错误"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	defer srv.Close()
+
+	resp := map[string]string{}
+	a.NotError(c.Do(context.Background(), http.MethodPost, "/ok", map[string]string{"id": "1"}, &resp))
+	a.Equal(resp["id"], "2")
+
+	a.NotError(c.Do(context.Background(), http.MethodGet, "/no-content", nil, &resp))
+
+	err := c.Do(context.Background(), http.MethodGet, "/error", nil, nil)
+	e, ok := err.(*Error)
+	a.True(ok).Equal(e.Status, http.StatusBadRequest).Equal(e.Code, "PARAM_ERROR")
+
+	err = c.Do(context.Background(), http.MethodGet, "/500", nil, nil)
+	e, ok = err.(*Error)
+	a.True(ok).Equal(e.Status, http.StatusInternalServerError).Equal(e.Code, "500")
+
+	// 无法验证应答的签名
+	c.verifier = NewPublicKeyVerifier("other", &platform.PublicKey)
+	a.ErrorIs(c.Do(context.Background(), http.MethodPost, "/ok", map[string]string{"id": "1"}, &resp), ErrUnknownSerial)
+}
+
+func TestError(t *testing.T) {
+	a := assert.New(t, false)
+
+	e := &Error{}
+	a.NotError(json.Unmarshal([]byte(`{"code":"NOTENOUGH","message":"余额不足","detail":{"field":"amount"}}`), e))
+	e.Status = 403
+	a.Equal(e.Error(), "403 NOTENOUGH: 余额不足")
+}
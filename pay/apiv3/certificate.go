@@ -0,0 +1,304 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AlgorithmAEADAES256GCM 加密资源所采用的算法
+const AlgorithmAEADAES256GCM = "AEAD_AES_256_GCM"
+
+// 默认的证书刷新间隔
+const defaultCertificateInterval = 12 * time.Hour
+
+// 错误信息
+var (
+	ErrInvalidAPIv3Key    = errors.New("APIv3 密钥的长度必须为 32 字节")
+	ErrCertificateExpired = errors.New("平台证书已经过期")
+)
+
+// Resource 加密的资源
+//
+// 平台证书及回调通知中的数据均以此格式返回。
+type Resource struct {
+	Algorithm      string `json:"algorithm"`
+	Nonce          string `json:"nonce"`
+	AssociatedData string `json:"associated_data"`
+	Ciphertext     string `json:"ciphertext"`
+	OriginalType   string `json:"original_type,omitempty"`
+}
+
+// Certificate 微信支付平台证书
+type Certificate struct {
+	Serial    string
+	Effective time.Time
+	Expire    time.Time
+	Cert      *x509.Certificate
+}
+
+// CertificateManager 微信支付平台证书的管理
+//
+// 负责下载、解密并缓存平台证书，会定时刷新证书内容，
+// 同时实现了 [Verifier] 接口，可用于验证应答及回调通知的签名。
+//
+//	m, err := apiv3.NewCertificateManager(c, apiv3Key, 0, nil)
+//	c.SetVerifier(m)
+type CertificateManager struct {
+	c        *Client
+	key      []byte
+	interval time.Duration
+	errlog   *log.Logger
+
+	mu     sync.RWMutex
+	certs  map[string]*Certificate
+	closed bool        // 是否已经停止定时刷新
+	timer  *time.Timer // 下一次刷新的定时器
+}
+
+// Decrypt 解密资源内容
+//
+// key 为 APIv3 密钥。
+func (r *Resource) Decrypt(key string) ([]byte, error) {
+	if r.Algorithm != AlgorithmAEADAES256GCM {
+		return nil, fmt.Errorf("不支持的加密算法 %s", r.Algorithm)
+	}
+	return DecryptAEAD(key, r.Nonce, r.AssociatedData, r.Ciphertext)
+}
+
+// DecryptAEAD 以 AEAD_AES_256_GCM 算法解密内容
+//
+// key 为 APIv3 密钥；ciphertext 为 base64 编码的密文。
+func DecryptAEAD(key, nonce, associatedData, ciphertext string) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidAPIv3Key
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+}
+
+// NewCertificateManager 声明 [CertificateManager] 实例
+//
+// key 为 APIv3 密钥；interval 为刷新证书的间隔，若为 0，则采用 12 小时；
+// 若将 errlog 指定为 nil，则会将错误信息输出到 stderr 中。
+//
+// 在返回之前会先下载一次证书，如果下载失败，则返回错误信息。
+func NewCertificateManager(c *Client, key string, interval time.Duration, errlog *log.Logger) (*CertificateManager, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidAPIv3Key
+	}
+
+	if interval <= 0 {
+		interval = defaultCertificateInterval
+	}
+
+	if errlog == nil {
+		errlog = log.Default()
+	}
+
+	m := &CertificateManager{
+		c:        c,
+		key:      []byte(key),
+		interval: interval,
+		errlog:   errlog,
+		certs:    make(map[string]*Certificate, 2),
+	}
+
+	if err := m.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	m.timer = time.AfterFunc(m.interval, m.refresh)
+
+	return m, nil
+}
+
+// Refresh 下载并更新平台证书
+//
+// 管理器本身会定时刷新，此函数仅用于在某些特定的情况下手动刷新。
+func (m *CertificateManager) Refresh(ctx context.Context) error {
+	certs, err := m.download(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.certs = certs
+	m.mu.Unlock()
+
+	return nil
+}
+
+// 定时刷新
+func (m *CertificateManager) refresh() {
+	if err := m.Refresh(context.Background()); err != nil {
+		m.errlog.Println(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.timer = time.AfterFunc(m.interval, m.refresh)
+	}
+}
+
+// Close 停止定时刷新证书
+//
+// 已经下载的证书依然可以用于验证签名。
+func (m *CertificateManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+}
+
+// 下载并解密证书
+//
+// 应答的签名以下载的证书进行验证，同时也接受当前已经缓存的证书。
+func (m *CertificateManager) download(ctx context.Context) (map[string]*Certificate, error) {
+	body, header, err := m.c.doHeader(ctx, http.MethodGet, "/v3/certificates", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &struct {
+		Data []*struct {
+			SerialNo           string    `json:"serial_no"`
+			EffectiveTime      time.Time `json:"effective_time"`
+			ExpireTime         time.Time `json:"expire_time"`
+			EncryptCertificate *Resource `json:"encrypt_certificate"`
+		} `json:"data"`
+	}{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		return nil, err
+	}
+
+	certs := make(map[string]*Certificate, len(resp.Data))
+	for _, item := range resp.Data {
+		if item.EncryptCertificate == nil {
+			return nil, fmt.Errorf("证书 %s 缺少 encrypt_certificate", item.SerialNo)
+		}
+
+		data, err := item.EncryptCertificate.Decrypt(string(m.key))
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := parseCertificate(data)
+		if err != nil {
+			return nil, err
+		}
+
+		certs[item.SerialNo] = &Certificate{
+			Serial:    item.SerialNo,
+			Effective: item.EffectiveTime,
+			Expire:    item.ExpireTime,
+			Cert:      cert,
+		}
+	}
+
+	v := certificateVerifier(func(serial, message, signature string) error {
+		if cert, found := certs[serial]; found {
+			return verifyCertificate(cert, message, signature)
+		}
+		return m.Verify(serial, message, signature)
+	})
+	if err = verifyHeader(v, header, body); err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+// Certificate 获取指定序列号的证书
+func (m *CertificateManager) Certificate(serial string) *Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.certs[serial]
+}
+
+// Newest 获取最新的证书
+//
+// 在需要以平台证书加密敏感信息时，应该采用此证书。
+func (m *CertificateManager) Newest() *Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ret *Certificate
+	for _, cert := range m.certs {
+		if ret == nil || cert.Effective.After(ret.Effective) {
+			ret = cert
+		}
+	}
+	return ret
+}
+
+// Verify 实现 [Verifier] 接口
+//
+// 如果证书已经过期，返回 [ErrCertificateExpired]。
+func (m *CertificateManager) Verify(serial, message, signature string) error {
+	cert := m.Certificate(serial)
+	if cert == nil {
+		return ErrUnknownSerial
+	}
+	return verifyCertificate(cert, message, signature)
+}
+
+type certificateVerifier func(serial, message, signature string) error
+
+func (f certificateVerifier) Verify(serial, message, signature string) error {
+	return f(serial, message, signature)
+}
+
+func verifyCertificate(cert *Certificate, message, signature string) error {
+	if time.Now().After(cert.Cert.NotAfter) {
+		return ErrCertificateExpired
+	}
+
+	key, ok := cert.Cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("不支持的公钥类型 %T", cert.Cert.PublicKey)
+	}
+	return verify(key, message, signature)
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEMBlock
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+)
+
+const testAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+// 以 AEAD_AES_256_GCM 加密内容
+func encryptAEAD(a *assert.Assertion, key, nonce, associatedData string, data []byte) *Resource {
+	block, err := aes.NewCipher([]byte(key))
+	a.NotError(err)
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	a.NotError(err)
+
+	return &Resource{
+		Algorithm:      AlgorithmAEADAES256GCM,
+		Nonce:          nonce,
+		AssociatedData: associatedData,
+		Ciphertext:     base64.StdEncoding.EncodeToString(gcm.Seal(nil, []byte(nonce), data, []byte(associatedData))),
+	}
+}
+
+func newTestCertificate(a *assert.Assertion, key *rsa.PrivateKey, notAfter time.Time) []byte {
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    notAfter.Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	a.NotError(err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestDecryptAEAD(t *testing.T) {
+	a := assert.New(t, false)
+
+	r := encryptAEAD(a, testAPIv3Key, "123456789012", "certificate", []byte("data"))
+	data, err := r.Decrypt(testAPIv3Key)
+	a.NotError(err).Equal(string(data), "data")
+
+	_, err = DecryptAEAD("short", r.Nonce, r.AssociatedData, r.Ciphertext)
+	a.ErrorIs(err, ErrInvalidAPIv3Key)
+
+	_, err = DecryptAEAD(testAPIv3Key, r.Nonce, "other", r.Ciphertext)
+	a.Error(err)
+
+	r.Algorithm = "other"
+	_, err = r.Decrypt(testAPIv3Key)
+	a.Error(err)
+}
+
+func TestCertificateManager(t *testing.T) {
+	a := assert.New(t, false)
+	c, platform := newTestClient(a)
+	c.SetVerifier(nil)
+
+	cert := encryptAEAD(a, testAPIv3Key, "123456789012", "certificate", newTestCertificate(a, platform, time.Now().Add(time.Hour)))
+	srv := newTestServer(a, c, platform, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		a.Equal(r.URL.Path, "/v3/certificates")
+		data, err := json.Marshal(map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{
+					"serial_no":           "platform",
+					"effective_time":      "2018-06-08T10:34:56+08:00",
+					"expire_time":         "2023-06-08T10:34:56+08:00",
+					"encrypt_certificate": cert,
+				},
+			},
+		})
+		a.NotError(err)
+		w.Write(data)
+	})
+	defer srv.Close()
+
+	_, err := NewCertificateManager(c, "short", 0, nil)
+	a.ErrorIs(err, ErrInvalidAPIv3Key)
+
+	m, err := NewCertificateManager(c, testAPIv3Key, time.Hour, nil)
+	a.NotError(err).NotNil(m)
+	a.NotNil(m.Certificate("platform")).
+		Nil(m.Certificate("other")).
+		Equal(m.Newest().Serial, "platform")
+
+	s, err := sign(platform, "message")
+	a.NotError(err)
+	a.NotError(m.Verify("platform", "message", s))
+	a.ErrorIs(m.Verify("other", "message", s), ErrUnknownSerial)
+
+	c.SetVerifier(m)
+	a.NotError(m.Refresh(context.Background()))
+
+	// 无法解密证书
+	m.key = []byte("00000000000000000000000000000000")
+	a.Error(m.Refresh(context.Background()))
+	a.NotNil(m.Certificate("platform"))
+
+	m.Close()
+}
+
+func TestCertificateManager_expired(t *testing.T) {
+	a := assert.New(t, false)
+	c, platform := newTestClient(a)
+	c.SetVerifier(nil)
+
+	der, _ := pem.Decode(newTestCertificate(a, platform, time.Now().Add(-time.Minute)))
+	cert, err := x509.ParseCertificate(der.Bytes)
+	a.NotError(err)
+
+	m := &CertificateManager{c: c, certs: map[string]*Certificate{
+		"platform": {Serial: "platform", Cert: cert},
+	}}
+	s, err := sign(platform, "message")
+	a.NotError(err)
+	a.ErrorIs(m.Verify("platform", "message", s), ErrCertificateExpired)
+}
+
+func TestCertificateManager_Close(t *testing.T) {
+	a := assert.New(t, false)
+	c, platform := newTestClient(a)
+	c.SetVerifier(nil)
+
+	var mu sync.Mutex
+	var count int
+	cert := encryptAEAD(a, testAPIv3Key, "123456789012", "certificate", newTestCertificate(a, platform, time.Now().Add(time.Hour)))
+	srv := newTestServer(a, c, platform, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+
+		data, err := json.Marshal(map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{"serial_no": "platform", "encrypt_certificate": cert},
+			},
+		})
+		a.NotError(err)
+		w.Write(data)
+	})
+	defer srv.Close()
+
+	m, err := NewCertificateManager(c, testAPIv3Key, 10*time.Millisecond, nil)
+	a.NotError(err).NotNil(m)
+	time.Sleep(50 * time.Millisecond)
+	m.Close()
+
+	mu.Lock()
+	n := count
+	mu.Unlock()
+	a.True(n > 1)
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	a.True(count <= n+1) // Close 时可能有一个正在进行的刷新
+	mu.Unlock()
+}
@@ -0,0 +1,302 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 回调通知中 resource.original_type 的可能值
+const (
+	ResourceTypeTransaction   = "transaction"
+	ResourceTypeRefund        = "refund"
+	ResourceTypeProfitSharing = "profitsharing"
+)
+
+// 回调通知的事件类型
+const (
+	EventTypeTransactionSuccess = "TRANSACTION.SUCCESS"
+	EventTypeRefundSuccess      = "REFUND.SUCCESS"
+	EventTypeRefundAbnormal     = "REFUND.ABNORMAL"
+	EventTypeRefundClosed       = "REFUND.CLOSED"
+)
+
+// NotifyMaxAge 回调通知的时间戳与当前时间允许的最大差值
+const NotifyMaxAge = 5 * time.Minute
+
+// 回调通知相关的错误信息
+var (
+	ErrTimestampExpired = errors.New("回调通知的时间戳已经过期")
+	ErrNotifyUnhandled  = errors.New("未指定该类型回调通知的处理函数")
+)
+
+// Notification 回调通知的内容
+type Notification struct {
+	ID           string    `json:"id"`
+	CreateTime   time.Time `json:"create_time"`
+	EventType    string    `json:"event_type"`
+	ResourceType string    `json:"resource_type"`
+	Summary      string    `json:"summary"`
+	Resource     *Resource `json:"resource"`
+}
+
+// RefundNotification 退款结果通知的内容
+type RefundNotification struct {
+	MchID               string    `json:"mchid"`
+	TransactionID       string    `json:"transaction_id"`
+	OutTradeNO          string    `json:"out_trade_no"`
+	RefundID            string    `json:"refund_id"`
+	OutRefundNO         string    `json:"out_refund_no"`
+	RefundStatus        string    `json:"refund_status"` // 退款状态，RefundStatus* 系列常量
+	SuccessTime         time.Time `json:"success_time"`
+	UserReceivedAccount string    `json:"user_received_account"`
+	Amount              *struct {
+		Total       int `json:"total"`
+		Refund      int `json:"refund"`
+		PayerTotal  int `json:"payer_total"`
+		PayerRefund int `json:"payer_refund"`
+	} `json:"amount"`
+}
+
+// ProfitSharingNotification 分账动账通知的内容
+type ProfitSharingNotification struct {
+	MchID         string    `json:"mchid"`
+	SpMchID       string    `json:"sp_mchid,omitempty"`
+	SubMchID      string    `json:"sub_mchid,omitempty"`
+	TransactionID string    `json:"transaction_id"`
+	OrderID       string    `json:"order_id"`
+	OutOrderNO    string    `json:"out_order_no"`
+	SuccessTime   time.Time `json:"success_time"`
+	Receiver      *struct {
+		Type        string `json:"type"`
+		Account     string `json:"account"`
+		Amount      int    `json:"amount"`
+		Description string `json:"description"`
+	} `json:"receiver"`
+}
+
+// Response 向微信返馈的信息
+type Response struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Notifier 处理 APIv3 的回调通知
+//
+// 会验证通知的签名及时间戳，并解密 resource 之后分发给相应的处理函数。
+// 未指定处理函数的通知类型会返回失败，而不是直接丢弃。
+//
+//	n := apiv3.NewNotifier(apiv3Key, m, nil)
+//	n.OnTransaction(func(notify *apiv3.Notification, t *apiv3.Transaction) error {
+//	    // TODO
+//	    return nil
+//	})
+//	http.Handle("/notify", n)
+type Notifier struct {
+	key      string
+	verifier Verifier
+	errlog   *log.Logger
+
+	transaction   func(*Notification, *Transaction) error
+	refund        func(*Notification, *RefundNotification) error
+	profitSharing func(*Notification, *ProfitSharingNotification) error
+}
+
+// Success 构建一个表示正常的 Response 实例
+func Success() *Response {
+	return &Response{Code: "SUCCESS", Message: "成功"}
+}
+
+// Fail 构建一个表示出错的 Response 实例，message 为出错信息
+func Fail(message string) *Response {
+	return &Response{Code: "FAIL", Message: message}
+}
+
+// Render 输出到客户端
+func (r *Response) Render(state int, w http.ResponseWriter) error {
+	bs, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(state)
+	_, err = w.Write(bs)
+	return err
+}
+
+// NewNotifier 声明 [Notifier] 实例
+//
+// key 为 APIv3 密钥；v 用于验证回调通知的签名，一般为 [CertificateManager]；
+// 若将 errlog 指定为 nil，则会将错误信息输出到 stderr 中。
+func NewNotifier(key string, v Verifier, errlog *log.Logger) *Notifier {
+	if errlog == nil {
+		errlog = log.Default()
+	}
+
+	return &Notifier{
+		key:      key,
+		verifier: v,
+		errlog:   errlog,
+	}
+}
+
+// OnTransaction 指定支付成功通知的处理函数
+func (n *Notifier) OnTransaction(f func(*Notification, *Transaction) error) {
+	n.transaction = f
+}
+
+// OnRefund 指定退款结果通知的处理函数
+func (n *Notifier) OnRefund(f func(*Notification, *RefundNotification) error) {
+	n.refund = f
+}
+
+// OnProfitSharing 指定分账动账通知的处理函数
+func (n *Notifier) OnProfitSharing(f func(*Notification, *ProfitSharingNotification) error) {
+	n.profitSharing = f
+}
+
+// Read 读取并验证回调通知
+//
+// 返回通知的内容以及解密之后的 resource 内容。
+func (n *Notifier) Read(r *http.Request) (*Notification, []byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = n.verify(r.Header, body); err != nil {
+		return nil, nil, err
+	}
+
+	notify, err := parseNotification(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := notify.Resource.Decrypt(n.key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return notify, data, nil
+}
+
+// ServeHTTP 处理回调通知
+//
+// 根据出错的阶段返回不同的状态码，且都会输出到 errlog：
+//   - 时间戳或签名验证失败，返回 401；
+//   - 无法读取或解析通知内容，返回 400；
+//   - 解密 resource 失败（一般为 APIv3 密钥错误）或是处理函数返回错误，返回 500；
+//   - 未指定处理函数的通知类型，返回 500 和 [ErrNotifyUnhandled]，微信会在之后重新发送该通知。
+//
+// 除了 200 以外的状态码，都会以 [Fail] 的格式返回错误信息。
+func (n *Notifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		n.fail(http.StatusBadRequest, err, w)
+		return
+	}
+
+	if err = n.verify(r.Header, body); err != nil {
+		n.fail(http.StatusUnauthorized, err, w)
+		return
+	}
+
+	notify, err := parseNotification(body)
+	if err != nil {
+		n.fail(http.StatusBadRequest, err, w)
+		return
+	}
+
+	data, err := notify.Resource.Decrypt(n.key)
+	if err != nil {
+		n.fail(http.StatusInternalServerError, err, w)
+		return
+	}
+
+	if err = n.dispatch(notify, data); err != nil {
+		n.fail(http.StatusInternalServerError, err, w)
+		return
+	}
+
+	n.render(http.StatusOK, Success(), w)
+}
+
+// 验证时间戳及签名
+func (n *Notifier) verify(h http.Header, body []byte) error {
+	ts, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return err
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > NotifyMaxAge || d < -NotifyMaxAge {
+		return ErrTimestampExpired
+	}
+
+	return verifyHeader(n.verifier, h, body)
+}
+
+func parseNotification(body []byte) (*Notification, error) {
+	notify := &Notification{}
+	if err := json.Unmarshal(body, notify); err != nil {
+		return nil, err
+	}
+	if notify.Resource == nil {
+		return nil, errors.New("回调通知缺少 resource")
+	}
+	return notify, nil
+}
+
+// 根据 resource 的类型分发给相应的处理函数
+//
+// 未指定处理函数的通知返回 [ErrNotifyUnhandled]。
+func (n *Notifier) dispatch(notify *Notification, data []byte) error {
+	switch notify.Resource.OriginalType {
+	case ResourceTypeTransaction:
+		if n.transaction != nil {
+			v := &Transaction{}
+			if err := json.Unmarshal(data, v); err != nil {
+				return err
+			}
+			return n.transaction(notify, v)
+		}
+	case ResourceTypeRefund:
+		if n.refund != nil {
+			v := &RefundNotification{}
+			if err := json.Unmarshal(data, v); err != nil {
+				return err
+			}
+			return n.refund(notify, v)
+		}
+	case ResourceTypeProfitSharing:
+		if n.profitSharing != nil {
+			v := &ProfitSharingNotification{}
+			if err := json.Unmarshal(data, v); err != nil {
+				return err
+			}
+			return n.profitSharing(notify, v)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrNotifyUnhandled, notify.Resource.OriginalType)
+}
+
+func (n *Notifier) fail(state int, err error, w http.ResponseWriter) {
+	n.errlog.Println(err)
+	n.render(state, Fail(err.Error()), w)
+}
+
+func (n *Notifier) render(state int, resp *Response, w http.ResponseWriter) {
+	if err := resp.Render(state, w); err != nil {
+		n.errlog.Println(err)
+	}
+}
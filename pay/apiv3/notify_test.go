@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+)
+
+func newNotifyRequest(a *assert.Assertion, key *rsa.PrivateKey, ts time.Time, originalType, data string) *http.Request {
+	resource := encryptAEAD(a, testAPIv3Key, "123456789012", "transaction", []byte(data))
+	resource.OriginalType = originalType
+	body, err := json.Marshal(&Notification{
+		ID:           "EV-2018022511223320873",
+		CreateTime:   ts,
+		EventType:    EventTypeTransactionSuccess,
+		ResourceType: "encrypt-resource",
+		Resource:     resource,
+	})
+	a.NotError(err)
+
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	s, err := sign(key, buildMessage(timestamp, "nonce", string(body)))
+	a.NotError(err)
+
+	r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, "nonce")
+	r.Header.Set(HeaderSignature, s)
+	r.Header.Set(HeaderSerial, "platform")
+	return r
+}
+
+func TestNotifier(t *testing.T) {
+	a := assert.New(t, false)
+	key := newTestKey(a)
+	logs := &bytes.Buffer{}
+	errlog := log.New(logs, "", 0)
+
+	n := NewNotifier(testAPIv3Key, NewPublicKeyVerifier("platform", &key.PublicKey), errlog)
+	var tx *Transaction
+	n.OnTransaction(func(notify *Notification, t *Transaction) error {
+		tx = t
+		return nil
+	})
+	n.OnRefund(func(*Notification, *RefundNotification) error {
+		return errors.New("refund")
+	})
+
+	// transaction
+	w := httptest.NewRecorder()
+	n.ServeHTTP(w, newNotifyRequest(a, key, time.Now(), ResourceTypeTransaction, `{"out_trade_no":"no1","trade_state":"SUCCESS"}`))
+	a.Equal(w.Code, http.StatusOK).Equal(w.Body.String(), `{"code":"SUCCESS","message":"成功"}`)
+	a.NotNil(tx).Equal(tx.OutTradeNO, "no1")
+
+	// refund 返回错误
+	w = httptest.NewRecorder()
+	n.ServeHTTP(w, newNotifyRequest(a, key, time.Now(), ResourceTypeRefund, `{"out_refund_no":"no1"}`))
+	a.Equal(w.Code, http.StatusInternalServerError)
+
+	// 未指定处理函数，返回失败以便微信重试
+	logs.Reset()
+	w = httptest.NewRecorder()
+	n.ServeHTTP(w, newNotifyRequest(a, key, time.Now(), ResourceTypeProfitSharing, `{}`))
+	a.Equal(w.Code, http.StatusInternalServerError).
+		Contains(w.Body.String(), `"code":"FAIL"`).
+		Contains(logs.String(), ErrNotifyUnhandled.Error())
+
+	// 时间戳过期
+	_, _, err := n.Read(newNotifyRequest(a, key, time.Now().Add(-NotifyMaxAge-time.Minute), ResourceTypeTransaction, `{}`))
+	a.ErrorIs(err, ErrTimestampExpired)
+
+	w = httptest.NewRecorder()
+	n.ServeHTTP(w, newNotifyRequest(a, key, time.Now().Add(-NotifyMaxAge-time.Minute), ResourceTypeTransaction, `{}`))
+	a.Equal(w.Code, http.StatusUnauthorized)
+
+	// 签名错误
+	logs.Reset()
+	r := newNotifyRequest(a, key, time.Now(), ResourceTypeTransaction, `{}`)
+	r.Header.Set(HeaderNonce, "other")
+	w = httptest.NewRecorder()
+	n.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusUnauthorized).
+		Contains(logs.String(), ErrInvalidSign.Error())
+
+	// 内容格式错误
+	body := []byte(`{"id":`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	s, err := sign(key, buildMessage(ts, "nonce", string(body)))
+	a.NotError(err)
+	r = httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderNonce, "nonce")
+	r.Header.Set(HeaderSignature, s)
+	r.Header.Set(HeaderSerial, "platform")
+	w = httptest.NewRecorder()
+	n.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusBadRequest)
+
+	// APIv3 密钥错误，无法解密
+	logs.Reset()
+	n2 := NewNotifier("00000000000000000000000000000000", NewPublicKeyVerifier("platform", &key.PublicKey), errlog)
+	n2.OnTransaction(func(*Notification, *Transaction) error { return nil })
+	w = httptest.NewRecorder()
+	n2.ServeHTTP(w, newNotifyRequest(a, key, time.Now(), ResourceTypeTransaction, `{}`))
+	a.Equal(w.Code, http.StatusInternalServerError).NotEmpty(logs.String())
+
+	// 解密
+	notify, data, err := n.Read(newNotifyRequest(a, key, time.Now(), ResourceTypeTransaction, `{"id":1}`))
+	a.NotError(err).NotNil(notify).Equal(string(data), `{"id":1}`)
+}
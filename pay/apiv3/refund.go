@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 退款状态
+const (
+	RefundStatusSuccess    = "SUCCESS"    // 退款成功
+	RefundStatusClosed     = "CLOSED"     // 退款关闭
+	RefundStatusProcessing = "PROCESSING" // 退款处理中
+	RefundStatusAbnormal   = "ABNORMAL"   // 退款异常
+)
+
+// Refund 退款申请
+//
+// TransactionID 和 OutTradeNO 二选一。
+type Refund struct {
+	TransactionID string        `json:"transaction_id,omitempty"`
+	OutTradeNO    string        `json:"out_trade_no,omitempty"`
+	OutRefundNO   string        `json:"out_refund_no"`
+	Reason        string        `json:"reason,omitempty"`
+	NotifyURL     string        `json:"notify_url,omitempty"`
+	FundsAccount  string        `json:"funds_account,omitempty"` // 退款资金来源，仅支持 AVAILABLE
+	Amount        *RefundAmount `json:"amount"`
+}
+
+// RefundAmount 退款金额
+type RefundAmount struct {
+	Refund           int    `json:"refund"` // 退款金额
+	Total            int    `json:"total"`  // 原订单金额
+	Currency         string `json:"currency"`
+	PayerTotal       int    `json:"payer_total,omitempty"`
+	PayerRefund      int    `json:"payer_refund,omitempty"`
+	SettlementRefund int    `json:"settlement_refund,omitempty"`
+	SettlementTotal  int    `json:"settlement_total,omitempty"`
+	DiscountRefund   int    `json:"discount_refund,omitempty"`
+}
+
+// RefundResult 退款的结果
+type RefundResult struct {
+	RefundID            string        `json:"refund_id"`
+	OutRefundNO         string        `json:"out_refund_no"`
+	TransactionID       string        `json:"transaction_id"`
+	OutTradeNO          string        `json:"out_trade_no"`
+	Channel             string        `json:"channel"`               // 退款渠道，ORIGINAL、BALANCE、OTHER_BALANCE、OTHER_BANKCARD
+	UserReceivedAccount string        `json:"user_received_account"` // 退款入账账户
+	SuccessTime         time.Time     `json:"success_time"`
+	CreateTime          time.Time     `json:"create_time"`
+	Status              string        `json:"status"` // 退款状态，RefundStatus* 系列常量
+	FundsAccount        string        `json:"funds_account"`
+	Amount              *RefundAmount `json:"amount"`
+}
+
+// Refund 申请退款
+//
+// r.Amount.Currency 为空时采用 CNY，不会修改 r 的内容。
+func (c *Client) Refund(ctx context.Context, r *Refund) (*RefundResult, error) {
+	if r.Amount != nil && r.Amount.Currency == "" {
+		req := *r
+		amount := *r.Amount
+		amount.Currency = "CNY"
+		req.Amount = &amount
+		r = &req
+	}
+
+	ret := &RefundResult{}
+	if err := c.Do(ctx, http.MethodPost, "/v3/refund/domestic/refunds", r, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryRefund 根据商户退款单号查询退款
+func (c *Client) QueryRefund(ctx context.Context, outRefundNO string) (*RefundResult, error) {
+	ret := &RefundResult{}
+	if err := c.Do(ctx, http.MethodGet, "/v3/refund/domestic/refunds/"+url.PathEscape(outRefundNO), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
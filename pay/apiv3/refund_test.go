@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestClient_Refund(t *testing.T) {
+	a := assert.New(t, false)
+	c, platform := newTestClient(a)
+
+	srv := newTestServer(a, c, platform, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		switch r.URL.Path {
+		case "/v3/refund/domestic/refunds":
+			req := &Refund{}
+			a.NotError(json.Unmarshal(body, req))
+			a.Equal(req.OutRefundNO, "r1").Equal(req.Amount.Currency, "CNY")
+			w.Write([]byte(`{"refund_id":"50000000382019052709732678859","out_refund_no":"r1","status":"PROCESSING","amount":{"refund":10,"total":100,"currency":"CNY"}}`))
+		case "/v3/refund/domestic/refunds/r1":
+			w.Write([]byte(`{"refund_id":"50000000382019052709732678859","out_refund_no":"r1","status":"SUCCESS","success_time":"2020-12-01T16:18:12+08:00"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	// 不会修改参数的内容
+	r := &Refund{OutTradeNO: "no1", OutRefundNO: "r1", Amount: &RefundAmount{Refund: 10, Total: 100}}
+	ret, err := c.Refund(context.Background(), r)
+	a.NotError(err).
+		Equal(ret.Status, RefundStatusProcessing).
+		Equal(ret.Amount.Refund, 10).
+		Empty(r.Amount.Currency)
+
+	ret, err = c.QueryRefund(context.Background(), "r1")
+	a.NotError(err).
+		Equal(ret.Status, RefundStatusSuccess).
+		Equal(ret.SuccessTime.Unix(), 1606810692)
+}
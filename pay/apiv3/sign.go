@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/issue9/wechat/internal"
+)
+
+// 应答及回调中与签名相关的报头
+const (
+	HeaderTimestamp = "Wechatpay-Timestamp"
+	HeaderNonce     = "Wechatpay-Nonce"
+	HeaderSignature = "Wechatpay-Signature"
+	HeaderSerial    = "Wechatpay-Serial"
+)
+
+// 签名相关的错误信息
+var (
+	ErrInvalidSign     = errors.New("签名无法验证")
+	ErrUnknownSerial   = errors.New("不存在该序列号的证书")
+	ErrVerifierNotSet  = errors.New("未指定 Verifier，无法验证应答的签名")
+	ErrInvalidPEMBlock = internal.ErrInvalidPEMBlock
+)
+
+// Verifier 验证微信支付的签名
+//
+// 用于验证应答及回调通知中的签名。
+type Verifier interface {
+	// 验证签名
+	//
+	// serial 为微信支付平台证书或是微信支付公钥的序列号，即 Wechatpay-Serial 报头；
+	// message 为待验证的内容；signature 为 base64 编码的签名。
+	Verify(serial, message, signature string) error
+}
+
+type publicKeyVerifier struct {
+	serial string
+	key    *rsa.PublicKey
+}
+
+// NewPublicKeyVerifier 根据微信支付公钥声明 [Verifier]
+//
+// serial 为公钥 ID；key 为微信支付公钥。
+func NewPublicKeyVerifier(serial string, key *rsa.PublicKey) Verifier {
+	return &publicKeyVerifier{serial: serial, key: key}
+}
+
+func (v *publicKeyVerifier) Verify(serial, message, signature string) error {
+	if serial != v.serial {
+		return ErrUnknownSerial
+	}
+	return verify(v.key, message, signature)
+}
+
+// LoadPrivateKey 从文件中加载商户 API 私钥
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(data)
+}
+
+// ParsePrivateKey 解析 PEM 格式的商户 API 私钥
+//
+// 支持 PKCS#8 和 PKCS#1 两种格式。
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEMBlock
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("不支持的私钥类型 %T", key)
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey 解析 PEM 格式的 RSA 公钥
+//
+// 支持 PKIX 和 PKCS#1 格式。
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	return internal.ParsePublicKey(data)
+}
+
+// 采用 SHA256 with RSA 签名，返回 base64 编码的签名。
+func sign(key *rsa.PrivateKey, message string) (string, error) {
+	h := sha256.Sum256([]byte(message))
+	bs, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bs), nil
+}
+
+func verify(key *rsa.PublicKey, message, signature string) error {
+	bs, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.Sum256([]byte(message))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], bs); err != nil {
+		return ErrInvalidSign
+	}
+	return nil
+}
+
+// 拼接各行内容，每行以 \n 结尾
+func buildMessage(lines ...string) string {
+	size := 0
+	for _, l := range lines {
+		size += len(l) + 1
+	}
+
+	bs := make([]byte, 0, size)
+	for _, l := range lines {
+		bs = append(bs, l...)
+		bs = append(bs, '\n')
+	}
+	return string(bs)
+}
+
+// 验证应答或回调通知中的签名
+func verifyHeader(v Verifier, h http.Header, body []byte) error {
+	if v == nil {
+		return ErrVerifierNotSet
+	}
+
+	signature := h.Get(HeaderSignature)
+	if signature == "" {
+		return ErrInvalidSign
+	}
+
+	message := buildMessage(h.Get(HeaderTimestamp), h.Get(HeaderNonce), string(body))
+	return v.Verify(h.Get(HeaderSerial), message, signature)
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func newTestKey(a *assert.Assertion) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NotError(err).NotNil(key)
+	return key
+}
+
+func TestBuildMessage(t *testing.T) {
+	a := assert.New(t, false)
+
+	a.Equal(buildMessage(), "")
+	a.Equal(buildMessage("GET", "/v3/certificates", "", ""), "GET\n/v3/certificates\n\n\n")
+}
+
+func TestSignVerify(t *testing.T) {
+	a := assert.New(t, false)
+	key := newTestKey(a)
+
+	s, err := sign(key, "message\n")
+	a.NotError(err).NotEmpty(s)
+	a.NotError(verify(&key.PublicKey, "message\n", s))
+	a.ErrorIs(verify(&key.PublicKey, "message", s), ErrInvalidSign)
+
+	v := NewPublicKeyVerifier("serial", &key.PublicKey)
+	a.NotError(v.Verify("serial", "message\n", s))
+	a.ErrorIs(v.Verify("other", "message\n", s), ErrUnknownSerial)
+
+	// verifyHeader
+	h := http.Header{}
+	a.ErrorIs(verifyHeader(nil, h, nil), ErrVerifierNotSet)
+	a.ErrorIs(verifyHeader(v, h, nil), ErrInvalidSign)
+
+	s, err = sign(key, buildMessage("1554208460", "nonce", "{}"))
+	a.NotError(err)
+	h.Set(HeaderTimestamp, "1554208460")
+	h.Set(HeaderNonce, "nonce")
+	h.Set(HeaderSignature, s)
+	h.Set(HeaderSerial, "serial")
+	a.NotError(verifyHeader(v, h, []byte("{}")))
+	a.ErrorIs(verifyHeader(v, h, []byte("{ }")), ErrInvalidSign)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	a := assert.New(t, false)
+	key := newTestKey(a)
+
+	_, err := ParsePrivateKey([]byte("invalid"))
+	a.ErrorIs(err, ErrInvalidPEMBlock)
+
+	// PKCS#1
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	k, err := ParsePrivateKey(data)
+	a.NotError(err).True(k.Equal(key))
+
+	// PKCS#8
+	bs, err := x509.MarshalPKCS8PrivateKey(key)
+	a.NotError(err)
+	data = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: bs})
+	k, err = ParsePrivateKey(data)
+	a.NotError(err).True(k.Equal(key))
+
+	// public key
+	bs, err = x509.MarshalPKIXPublicKey(&key.PublicKey)
+	a.NotError(err)
+	data = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: bs})
+	pk, err := ParsePublicKey(data)
+	a.NotError(err).True(pk.Equal(&key.PublicKey))
+
+	// PKCS#1 public key
+	data = pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	pk, err = ParsePublicKey(data)
+	a.NotError(err).True(pk.Equal(&key.PublicKey))
+}
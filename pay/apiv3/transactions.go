@@ -0,0 +1,362 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/pay"
+	"github.com/issue9/wechat/pay/unifiedorder"
+)
+
+// H5 支付的场景类型
+const (
+	H5TypeWap     = "Wap"
+	H5TypeIOS     = "iOS"
+	H5TypeAndroid = "Android"
+)
+
+// 各交易类型对应的下单地址
+var tradeTypePaths = map[string]string{
+	pay.TradeTypeJSAPI:  "/v3/pay/transactions/jsapi",
+	pay.TradeTypeApp:    "/v3/pay/transactions/app",
+	pay.TradeTypeMWEB:   "/v3/pay/transactions/h5",
+	pay.TradeTypeNative: "/v3/pay/transactions/native",
+}
+
+// Order 订单数据
+//
+// 与 [unifiedorder.Order] 相同，建议先声明一个包含公用数据的 Order 实例，
+// 之后用 [Order.NewOrder] 生成新的订单，新的订单会继承这些公用数据。
+//
+//	conf := apiv3.NewOrder(c)
+//	conf.TradeType = pay.TradeTypeJSAPI
+//	conf.NotifyURL = "https://example.com/notify"
+//
+//	o1 := conf.NewOrder() // 继承了 conf.TradeType 等数据
+//	o1.Body = "..."
+//	o1.Pay(ctx)
+type Order struct {
+	c              *Client
+	DeviceInfo     string        // 设备号
+	FeeType        string        // 货币类型，默认 CNY
+	SpbillCreateIP string        // 终端 IP，H5 支付时必填
+	ExpireIn       time.Duration // 交易结束时间
+	NotifyURL      string        // 通知地址
+	TradeType      string        // 交易类型，pay.TradeType* 系列常量
+	H5Type         string        // H5 支付的场景类型，H5Type* 系列常量
+	ProfitSharing  bool          // 是否指定分账
+
+	Body       string    // 商品描述
+	Attach     string    // 附加数据
+	OutTradeNO string    // 商户订单号
+	TotalFee   int       // 总金额
+	Start      time.Time // 交易起始时间，用于计算 ExpireIn，为空表示当前时间
+	Tag        string    // 商品标记
+	OpenID     string    // 用户标识，JSAPI 支付时必填
+	goods      []*unifiedorder.Good
+}
+
+// Amount 订单金额
+type Amount struct {
+	Total    int    `json:"total"`              // 总金额，单位为分
+	Currency string `json:"currency,omitempty"` // 货币类型，默认 CNY
+}
+
+// Payer 支付者信息
+type Payer struct {
+	OpenID string `json:"openid"`
+}
+
+// Return 下单之后的返回值
+type Return struct {
+	c         *Client
+	TradeType string
+	PrepayID  string
+	CodeURL   string // 二维码链接，仅 NATIVE 支付有效
+	H5URL     string // 支付跳转链接，仅 H5 支付有效
+}
+
+// Transaction 订单的详细信息
+type Transaction struct {
+	AppID           string             `json:"appid"`
+	MchID           string             `json:"mchid"`
+	OutTradeNO      string             `json:"out_trade_no"`
+	TransactionID   string             `json:"transaction_id"`
+	TradeType       string             `json:"trade_type"`
+	TradeState      string             `json:"trade_state"` // 交易状态，pay.TradeState* 系列常量
+	TradeStateDesc  string             `json:"trade_state_desc"`
+	BankType        string             `json:"bank_type"`
+	Attach          string             `json:"attach"`
+	SuccessTime     time.Time          `json:"success_time"`
+	Payer           *Payer             `json:"payer"`
+	Amount          *TransactionAmount `json:"amount"`
+	PromotionDetail []*Promotion       `json:"promotion_detail,omitempty"`
+}
+
+// TransactionAmount 订单的金额信息
+type TransactionAmount struct {
+	Total         int    `json:"total"`
+	PayerTotal    int    `json:"payer_total"` // 用户实际支付金额
+	Currency      string `json:"currency"`
+	PayerCurrency string `json:"payer_currency"`
+}
+
+// Promotion 优惠信息
+type Promotion struct {
+	CouponID            string `json:"coupon_id"`
+	Name                string `json:"name"`
+	Scope               string `json:"scope"` // GLOBAL 全场代金券，SINGLE 单品优惠
+	Type                string `json:"type"`  // CASH 充值型代金券，NOCASH 免充值型代金券
+	Amount              int    `json:"amount"`
+	StockID             string `json:"stock_id"`
+	WechatpayContribute int    `json:"wechatpay_contribute"`
+	MerchantContribute  int    `json:"merchant_contribute"`
+	OtherContribute     int    `json:"other_contribute"`
+	Currency            string `json:"currency"`
+}
+
+type goodsDetail struct {
+	MerchantGoodsID  string `json:"merchant_goods_id"`
+	WechatpayGoodsID string `json:"wechatpay_goods_id,omitempty"`
+	GoodsName        string `json:"goods_name,omitempty"`
+	Quantity         int    `json:"quantity"`
+	UnitPrice        int    `json:"unit_price"`
+}
+
+type orderRequest struct {
+	AppID       string `json:"appid"`
+	MchID       string `json:"mchid"`
+	Description string `json:"description"`
+	OutTradeNO  string `json:"out_trade_no"`
+	TimeExpire  string `json:"time_expire,omitempty"`
+	Attach      string `json:"attach,omitempty"`
+	NotifyURL   string `json:"notify_url"`
+	GoodsTag    string `json:"goods_tag,omitempty"`
+	SettleInfo  *struct {
+		ProfitSharing bool `json:"profit_sharing"`
+	} `json:"settle_info,omitempty"`
+	Amount *Amount `json:"amount"`
+	Payer  *Payer  `json:"payer,omitempty"`
+	Detail *struct {
+		GoodsDetail []*goodsDetail `json:"goods_detail"`
+	} `json:"detail,omitempty"`
+	SceneInfo *sceneInfo `json:"scene_info,omitempty"`
+}
+
+type sceneInfo struct {
+	PayerClientIP string `json:"payer_client_ip"`
+	DeviceID      string `json:"device_id,omitempty"`
+	H5Info        *struct {
+		Type string `json:"type"`
+	} `json:"h5_info,omitempty"`
+}
+
+// NewOrder 新的订单实例
+func NewOrder(c *Client) *Order {
+	return &Order{
+		c: c,
+	}
+}
+
+// NewOrder 生成一条新的订单
+func (o *Order) NewOrder() *Order {
+	ret := &Order{}
+	*ret = *o
+
+	// 重置数据
+	ret.Body = ""
+	ret.Attach = ""
+	ret.OutTradeNO = ""
+	ret.TotalFee = 0
+	ret.Start = time.Time{}
+	ret.Tag = ""
+	ret.OpenID = ""
+	ret.goods = nil
+
+	return ret
+}
+
+// Goods 为当前订单添加一条或是多条物品记录
+func (o *Order) Goods(goods ...*unifiedorder.Good) {
+	o.goods = append(o.goods, goods...)
+}
+
+// 获取订单的实际金额
+func (o *Order) totalFee() (int, error) {
+	if len(o.goods) == 0 {
+		return o.TotalFee, nil
+	}
+
+	totalFee := 0
+	for _, g := range o.goods {
+		totalFee += g.Quantity * g.Price
+	}
+
+	if o.TotalFee > 0 && o.TotalFee != totalFee {
+		return 0, errors.New("指定了 TotalFee，但与实际的 goods 计算值不相同")
+	}
+
+	return totalFee, nil
+}
+
+// 将当前实例转换成请求对象
+func (o *Order) request() (*orderRequest, error) {
+	totalFee, err := o.totalFee()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &orderRequest{
+		AppID:       o.c.AppID(),
+		MchID:       o.c.MchID(),
+		Description: o.Body,
+		OutTradeNO:  o.OutTradeNO,
+		Attach:      o.Attach,
+		NotifyURL:   o.NotifyURL,
+		GoodsTag:    o.Tag,
+		Amount:      &Amount{Total: totalFee, Currency: o.FeeType},
+	}
+
+	if o.ExpireIn > 0 {
+		start := o.Start
+		if start.IsZero() {
+			start = time.Now()
+		}
+		req.TimeExpire = start.Add(o.ExpireIn).In(pay.Location).Format(time.RFC3339)
+	}
+
+	if o.ProfitSharing {
+		req.SettleInfo = &struct {
+			ProfitSharing bool `json:"profit_sharing"`
+		}{ProfitSharing: true}
+	}
+
+	if o.OpenID != "" {
+		req.Payer = &Payer{OpenID: o.OpenID}
+	}
+
+	if len(o.goods) > 0 {
+		req.Detail = &struct {
+			GoodsDetail []*goodsDetail `json:"goods_detail"`
+		}{GoodsDetail: make([]*goodsDetail, 0, len(o.goods))}
+
+		for _, g := range o.goods {
+			req.Detail.GoodsDetail = append(req.Detail.GoodsDetail, &goodsDetail{
+				MerchantGoodsID:  g.ID,
+				WechatpayGoodsID: g.WxpayGoodsID,
+				GoodsName:        g.Name,
+				Quantity:         g.Quantity,
+				UnitPrice:        g.Price,
+			})
+		}
+	}
+
+	if o.SpbillCreateIP != "" || o.TradeType == pay.TradeTypeMWEB {
+		req.SceneInfo = &sceneInfo{
+			PayerClientIP: o.SpbillCreateIP,
+			DeviceID:      o.DeviceInfo,
+		}
+
+		if o.TradeType == pay.TradeTypeMWEB {
+			typ := o.H5Type
+			if typ == "" {
+				typ = H5TypeWap
+			}
+			req.SceneInfo.H5Info = &struct {
+				Type string `json:"type"`
+			}{Type: typ}
+		}
+	}
+
+	return req, nil
+}
+
+// Pay 下单
+func (o *Order) Pay(ctx context.Context) (*Return, error) {
+	path, found := tradeTypePaths[o.TradeType]
+	if !found {
+		return nil, fmt.Errorf("无效的交易类型 %s", o.TradeType)
+	}
+
+	req, err := o.request()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &struct {
+		PrepayID string `json:"prepay_id"`
+		CodeURL  string `json:"code_url"`
+		H5URL    string `json:"h5_url"`
+	}{}
+	if err = o.c.Do(ctx, http.MethodPost, path, req, resp); err != nil {
+		return nil, err
+	}
+
+	return &Return{
+		c:         o.c,
+		TradeType: o.TradeType,
+		PrepayID:  resp.PrepayID,
+		CodeURL:   resp.CodeURL,
+		H5URL:     resp.H5URL,
+	}, nil
+}
+
+// GetBrandWCPayRequest 获取调起 JSAPI 支付的参数
+//
+// 与 APIv2 不同，签名类型固定为 RSA。
+func (r *Return) GetBrandWCPayRequest() (*unifiedorder.BrandWCPayRequest, error) {
+	ret := &unifiedorder.BrandWCPayRequest{
+		AppID:       r.c.AppID(),
+		TimeStamp:   strconv.FormatInt(time.Now().Unix(), 10),
+		NonceString: pay.NonceString(),
+		Package:     "prepay_id=" + r.PrepayID,
+		SignType:    "RSA",
+	}
+
+	sign, err := r.c.Sign(buildMessage(ret.AppID, ret.TimeStamp, ret.NonceString, ret.Package))
+	if err != nil {
+		return nil, err
+	}
+	ret.PaySign = sign
+
+	return ret, nil
+}
+
+// QueryTransaction 根据微信支付订单号查询订单
+func (c *Client) QueryTransaction(ctx context.Context, transactionID string) (*Transaction, error) {
+	return c.queryTransaction(ctx, "/v3/pay/transactions/id/"+url.PathEscape(transactionID))
+}
+
+// QueryOutTradeNO 根据商户订单号查询订单
+func (c *Client) QueryOutTradeNO(ctx context.Context, outTradeNO string) (*Transaction, error) {
+	return c.queryTransaction(ctx, "/v3/pay/transactions/out-trade-no/"+url.PathEscape(outTradeNO))
+}
+
+func (c *Client) queryTransaction(ctx context.Context, path string) (*Transaction, error) {
+	t := &Transaction{}
+	if err := c.Do(ctx, http.MethodGet, path+"?mchid="+url.QueryEscape(c.MchID()), nil, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// CloseOrder 关闭订单
+func (c *Client) CloseOrder(ctx context.Context, outTradeNO string) error {
+	req := &struct {
+		MchID string `json:"mchid"`
+	}{
+		MchID: c.MchID(),
+	}
+
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(outTradeNO) + "/close"
+	return c.Do(ctx, http.MethodPost, path, req, nil)
+}
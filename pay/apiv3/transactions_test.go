@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package apiv3
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+	"github.com/issue9/wechat/pay/unifiedorder"
+)
+
+func TestOrder_NewOrder(t *testing.T) {
+	a := assert.New(t, false)
+
+	conf := NewOrder(nil)
+	conf.TradeType = pay.TradeTypeJSAPI
+	conf.NotifyURL = "https://example.com/notify"
+	conf.Body = "body"
+	conf.Goods(&unifiedorder.Good{ID: "1", Quantity: 1, Price: 1})
+
+	o := conf.NewOrder()
+	a.Equal(o.TradeType, pay.TradeTypeJSAPI).
+		Equal(o.NotifyURL, "https://example.com/notify").
+		Empty(o.Body).
+		Empty(o.goods)
+}
+
+func TestOrder_request(t *testing.T) {
+	a := assert.New(t, false)
+	c, _ := newTestClient(a)
+
+	o := NewOrder(c)
+	o.TradeType = pay.TradeTypeMWEB
+	o.SpbillCreateIP = "127.0.0.1"
+	o.ExpireIn = time.Hour
+	o.Start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	o.TotalFee = 5
+	o.Goods(&unifiedorder.Good{ID: "1", Quantity: 2, Price: 1})
+	_, err := o.request()
+	a.Error(err)
+
+	o.TotalFee = 2
+	req, err := o.request()
+	a.NotError(err).NotNil(req)
+	a.Equal(req.AppID, "appid").
+		Equal(req.MchID, "mchid").
+		Equal(req.TimeExpire, "2024-01-02T04:04:05+08:00").
+		Equal(req.Amount.Total, 2).
+		Nil(req.Payer).
+		Nil(req.SettleInfo).
+		Equal(req.SceneInfo.PayerClientIP, "127.0.0.1").
+		Equal(req.SceneInfo.H5Info.Type, H5TypeWap).
+		Length(req.Detail.GoodsDetail, 1)
+
+	// UTC 时间转换成北京时间
+	o.Start = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	req, err = o.request()
+	a.NotError(err).Equal(req.TimeExpire, "2024-01-02T12:04:05+08:00")
+
+	o = NewOrder(c)
+	o.TradeType = pay.TradeTypeJSAPI
+	o.OpenID = "openid"
+	o.TotalFee = 1
+	o.ProfitSharing = true
+	req, err = o.request()
+	a.NotError(err).NotNil(req)
+	a.Equal(req.Payer.OpenID, "openid").
+		True(req.SettleInfo.ProfitSharing).
+		Nil(req.SceneInfo).
+		Nil(req.Detail).
+		Empty(req.TimeExpire)
+}
+
+func TestOrder_Pay(t *testing.T) {
+	a := assert.New(t, false)
+	c, platform := newTestClient(a)
+
+	srv := newTestServer(a, c, platform, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		switch r.URL.Path {
+		case "/v3/pay/transactions/jsapi":
+			req := &orderRequest{}
+			a.NotError(json.Unmarshal(body, req))
+			a.Equal(req.OutTradeNO, "no1").Equal(req.Payer.OpenID, "openid")
+			w.Write([]byte(`{"prepay_id":"wx201410272009395522657a690389285100"}`))
+		case "/v3/pay/transactions/out-trade-no/no1":
+			a.Equal(r.URL.Query().Get("mchid"), "mchid")
+			w.Write([]byte(`{"out_trade_no":"no1","trade_state":"SUCCESS","success_time":"2018-06-08T10:34:56+08:00","amount":{"total":100,"payer_total":90}}`))
+		case "/v3/pay/transactions/out-trade-no/no1/close":
+			a.Equal(string(body), `{"mchid":"mchid"}`)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	o := NewOrder(c)
+	o.TradeType = "invalid"
+	_, err := o.Pay(context.Background())
+	a.Error(err)
+
+	o.TradeType = pay.TradeTypeJSAPI
+	o.OutTradeNO = "no1"
+	o.OpenID = "openid"
+	o.TotalFee = 100
+	ret, err := o.Pay(context.Background())
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.PrepayID, "wx201410272009395522657a690389285100")
+
+	req, err := ret.GetBrandWCPayRequest()
+	a.NotError(err).NotNil(req)
+	a.Equal(req.SignType, "RSA").Equal(req.Package, "prepay_id="+ret.PrepayID)
+	a.NotError(verify(&c.key.PublicKey, buildMessage(req.AppID, req.TimeStamp, req.NonceString, req.Package), req.PaySign))
+
+	tx, err := c.QueryOutTradeNO(context.Background(), "no1")
+	a.NotError(err).NotNil(tx)
+	a.Equal(tx.TradeState, pay.TradeStateSuccess).
+		Equal(tx.Amount.PayerTotal, 90).
+		Equal(tx.SuccessTime.Unix(), time.Date(2018, 6, 8, 2, 34, 56, 0, time.UTC).Unix())
+
+	a.NotError(c.CloseOrder(context.Background(), "no1"))
+}
@@ -0,0 +1,229 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package bill 下载交易账单和资金账单
+//
+// 账单内容以流的形式解析，每解析一条记录便调用一次回调函数，
+// 最后返回账单的汇总数据。
+//
+//	p := pay.New(...)
+//	b := bill.Bill{
+//	    Pay: p,
+//	    Gzip: true,
+//	}
+//
+//	sum, err := b.Trade(date, bill.TypeAll, func(r *bill.TradeRecord) error {
+//	    // TODO 对账
+//	    return nil
+//	})
+package bill
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// 交易账单的类型
+const (
+	TypeAll            = "ALL"             // 当日所有订单信息（不含充值退款订单）
+	TypeSuccess        = "SUCCESS"         // 当日成功支付的订单（不含充值退款订单）
+	TypeRefund         = "REFUND"          // 当日退款订单（不含充值退款订单）
+	TypeRechargeRefund = "RECHARGE_REFUND" // 当日充值退款订单
+)
+
+// 资金账户的类型
+const (
+	AccountTypeBasic     = "Basic"     // 基本账户
+	AccountTypeOperation = "Operation" // 运营账户
+	AccountTypeFees      = "Fees"      // 手续费账户
+)
+
+// 账单中的日期格式
+const (
+	billDateFormat = "20060102"
+	timeFormat     = "2006-01-02 15:04:05"
+)
+
+// Bill 下载账单的配置
+type Bill struct {
+	Pay      *pay.Pay
+	SignType string // 签名类型，仅对交易账单有效，资金账单固定为 HMAC-SHA256。
+	Gzip     bool   // 是否以 GZIP 压缩的形式下载
+}
+
+// Trade 下载并解析交易账单
+//
+// date 为账单日期；typ 为账单类型，Type* 系列常量；
+// f 为每一条记录的回调函数，返回错误会中断解析。
+func (b *Bill) Trade(date time.Time, typ string, f func(*TradeRecord) error) (*TradeSummary, error) {
+	r, err := b.download(pay.DownloadBillURL, map[string]string{
+		"sign_type": b.SignType,
+		"bill_date": date.Format(billDateFormat),
+		"bill_type": typ,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ParseTrade(r, f)
+}
+
+// FundFlow 下载并解析资金账单
+//
+// 资金账单需要使用证书，即 Pay 需要由 [pay.NewTLSPay] 创建。
+// date 为账单日期；accountType 为资金账户类型，AccountType* 系列常量；
+// f 为每一条记录的回调函数，返回错误会中断解析。
+func (b *Bill) FundFlow(date time.Time, accountType string, f func(*FundFlowRecord) error) (*FundFlowSummary, error) {
+	r, err := b.download(pay.FundFlowURL, map[string]string{
+		"sign_type":    pay.SignTypeHmacSha256,
+		"bill_date":    date.Format(billDateFormat),
+		"account_type": accountType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ParseFundFlow(r, f)
+}
+
+// 下载账单并返回解压之后的内容
+//
+// 如果微信返回的是 XML 格式的错误信息，则将其转换成错误返回。
+func (b *Bill) download(url string, params map[string]string) (io.ReadCloser, error) {
+	if b.Gzip {
+		params["tar_type"] = "GZIP"
+	}
+
+	body, err := b.Pay.PostRaw(url, params)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newReader(body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+type reader struct {
+	io.Reader
+	body io.Closer
+}
+
+func (r *reader) Close() error { return r.body.Close() }
+
+// 根据内容判断是否需要解压或是出错信息
+func newReader(body io.ReadCloser) (io.ReadCloser, error) {
+	buf := bufio.NewReader(body)
+	head, err := buf.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b:
+		gr, err := gzip.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &reader{Reader: gr, body: body}, nil
+	case len(head) > 0 && head[0] == '<':
+		params, err := xxml.MapFromXMLReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %s", params["error_code"], params["return_msg"])
+	default:
+		return &reader{Reader: buf, body: body}, nil
+	}
+}
+
+// 解析账单内容
+//
+// summaryKey 为汇总数据表头的第一列名称；
+// row 和 summary 分别用于处理普通的记录和汇总数据，参数分别为表头和当前行的内容。
+func parse(r io.Reader, summaryKey string, row, summary func(header, cols []string) error) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 1024*1024)
+
+	var header []string
+	inSummary := false
+	for s.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(s.Text()), "\ufeff") // 去掉 BOM
+		if line == "" {
+			continue
+		}
+
+		if header == nil {
+			header = strings.Split(line, ",")
+			continue
+		}
+
+		if !strings.HasPrefix(line, "`") { // 汇总数据的表头
+			if strings.HasPrefix(line, summaryKey) {
+				header = strings.Split(line, ",")
+				inSummary = true
+				continue
+			}
+			return fmt.Errorf("无法识别的行 %s", line)
+		}
+
+		cols := strings.Split(strings.TrimPrefix(line, "`"), ",`")
+		if len(cols) != len(header) {
+			return fmt.Errorf("列数量 %d 与表头的数量 %d 不相符", len(cols), len(header))
+		}
+
+		var err error
+		if inSummary {
+			err = summary(header, cols)
+		} else {
+			err = row(header, cols)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	if !inSummary {
+		return errors.New("缺少汇总数据")
+	}
+	return nil
+}
+
+// 将以元为单位的金额转换成以分为单位
+func parseFen(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(f * 100)), nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(timeFormat, v, pay.Location)
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package bill
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+)
+
+const tradeBill = "\ufeff交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\n" +
+	"`2014-11-10 16:33:45,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1001690740201411100005734289,`1415640626,`085e9858e3ba5186aafcbaed1,`MICROPAY,`SUCCESS,`OTHERS,`CNY,`0.01,`0.0,`0,`0,`0,`0,`,`,`被扫支付测试,`订单额外描述,`0.00000,`0.60%,`0.01,`0.00,`\n" +
+	"`2014-11-10 16:46:14,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1002780740201411100005729794,`1415635270,`085e9858e3ba5186aafcbaed1,`MICROPAY,`REFUND,`OTHERS,`CNY,`1.00,`0.0,`2008450740201411110000174436,`1415635270,`1.00,`0.00,`ORIGINAL,`SUCCESS,`商品,A,`,`0.00600,`0.60%,`1.00,`1.00,`\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\n" +
+	"`2,`1.01,`1.00,`0.00,`0.00600,`1.01,`1.00\n"
+
+const fundFlowBill = "记账时间,微信支付业务单号,资金流水单号,业务名称,业务类型,收支类型,收支金额（元）,账户结余（元）,资金变更提交申请人,备注,业务凭证号\n" +
+	"`2018-02-01 04:21:23,`50000305742018020103387128253,`1900009231201802015884652186,`退款,`退款,`支出,`0.02,`0.17,`system,`缺货,`REF4200000068201801293084726067\n" +
+	"资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\n" +
+	"`1,`0,`0.00,`1,`0.02\n"
+
+func TestParseTrade(t *testing.T) {
+	a := assert.New(t, false)
+
+	records := make([]*TradeRecord, 0, 2)
+	sum, err := ParseTrade(strings.NewReader(tradeBill), func(r *TradeRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	a.NotError(err).NotNil(sum)
+	a.Equal(sum, &TradeSummary{
+		Count:              2,
+		SettlementTotalFee: 101,
+		RefundFee:          100,
+		ServiceCharge:      1,
+		TotalFee:           101,
+		ApplyRefundFee:     100,
+	})
+
+	a.Length(records, 2)
+	a.Equal(records[0].Time, time.Date(2014, 11, 10, 16, 33, 45, 0, pay.Location)).
+		Equal(records[0].AppID, "wx2421b1c4370ec43b").
+		Equal(records[0].TradeState, "SUCCESS").
+		Equal(records[0].SettlementTotalFee, 1).
+		Equal(records[0].Body, "被扫支付测试").
+		Equal(records[0].Rate, "0.60%")
+	a.Equal(records[1].RefundFee, 100).
+		Equal(records[1].RefundType, "ORIGINAL").
+		Equal(records[1].Body, "商品,A"). // 内容中包含逗号
+		Equal(records[1].ServiceCharge, 1)
+
+	// 回调函数返回错误
+	e := errors.New("error")
+	sum, err = ParseTrade(strings.NewReader(tradeBill), func(r *TradeRecord) error { return e })
+	a.ErrorIs(err, e).Nil(sum)
+
+	// 缺少汇总数据
+	bill := tradeBill[:strings.Index(tradeBill, "总交易单数")]
+	sum, err = ParseTrade(strings.NewReader(bill), func(r *TradeRecord) error { return nil })
+	a.Error(err).Nil(sum)
+}
+
+func TestParseFundFlow(t *testing.T) {
+	a := assert.New(t, false)
+
+	var record *FundFlowRecord
+	sum, err := ParseFundFlow(strings.NewReader(fundFlowBill), func(r *FundFlowRecord) error {
+		record = r
+		return nil
+	})
+	a.NotError(err).NotNil(sum)
+	a.Equal(sum, &FundFlowSummary{Count: 1, ExpenseCount: 1, Expense: 2})
+	a.NotNil(record).
+		Equal(record.FlowType, FlowTypeExpense).
+		Equal(record.Amount, 2).
+		Equal(record.Balance, 17).
+		Equal(record.VoucherNO, "REF4200000068201801293084726067")
+}
+
+func TestNewReader(t *testing.T) {
+	a := assert.New(t, false)
+
+	// 文本
+	r, err := newReader(io.NopCloser(strings.NewReader(fundFlowBill)))
+	a.NotError(err).NotNil(r)
+	data, err := io.ReadAll(r)
+	a.NotError(err).Equal(string(data), fundFlowBill)
+
+	// gzip
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err = w.Write([]byte(fundFlowBill))
+	a.NotError(err).NotError(w.Close())
+	r, err = newReader(io.NopCloser(buf))
+	a.NotError(err).NotNil(r)
+	data, err = io.ReadAll(r)
+	a.NotError(err).Equal(string(data), fundFlowBill)
+
+	// 错误信息
+	xml := `<xml><return_code><![CDATA[FAIL]]></return_code><return_msg><![CDATA[No Bill Exist]]></return_msg><error_code><![CDATA[20002]]></error_code></xml>`
+	r, err = newReader(io.NopCloser(strings.NewReader(xml)))
+	a.Error(err).Nil(r)
+	a.Equal(err.Error(), "20002: No Bill Exist")
+}
+
+func TestParseFen(t *testing.T) {
+	a := assert.New(t, false)
+
+	test := func(v string, fen int) {
+		n, err := parseFen(v)
+		a.NotError(err).Equal(n, fen, v)
+	}
+	test("", 0)
+	test("0.01", 1)
+	test("1.00", 100)
+	test("5.76", 576)
+	test("0.00600", 1)
+	test("-0.02", -2)
+
+	_, err := parseFen("x")
+	a.Error(err)
+}
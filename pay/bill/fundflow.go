@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package bill
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+// 资金账单中的收支类型
+const (
+	FlowTypeIncome  = "收入"
+	FlowTypeExpense = "支出"
+)
+
+// FundFlowRecord 资金账单中的记录
+//
+// 金额均已转换成以分为单位。
+type FundFlowRecord struct {
+	Time          time.Time // 记账时间
+	TransactionID string    // 微信支付业务单号
+	FlowID        string    // 资金流水单号
+	BizName       string    // 业务名称
+	BizType       string    // 业务类型
+	FlowType      string    // 收支类型，FlowType* 系列常量
+	Amount        int       // 收支金额
+	Balance       int       // 账户结余
+	Applicant     string    // 资金变更提交申请人
+	Remark        string    // 备注
+	VoucherNO     string    // 业务凭证号
+}
+
+// FundFlowSummary 资金账单的汇总数据
+type FundFlowSummary struct {
+	Count        int // 资金流水总笔数
+	IncomeCount  int // 收入笔数
+	Income       int // 收入金额
+	ExpenseCount int // 支出笔数
+	Expense      int // 支出金额
+}
+
+func fundFlowString(f func(*FundFlowRecord) *string) func(*FundFlowRecord, string) error {
+	return func(r *FundFlowRecord, v string) error {
+		*f(r) = v
+		return nil
+	}
+}
+
+func fundFlowFen(f func(*FundFlowRecord) *int) func(*FundFlowRecord, string) error {
+	return func(r *FundFlowRecord, v string) (err error) {
+		*f(r), err = parseFen(v)
+		return err
+	}
+}
+
+// 资金账单各列的处理函数
+var fundFlowColumns = map[string]func(*FundFlowRecord, string) error{
+	"记账时间": func(r *FundFlowRecord, v string) (err error) {
+		r.Time, err = parseTime(v)
+		return err
+	},
+	"微信支付业务单号":  fundFlowString(func(r *FundFlowRecord) *string { return &r.TransactionID }),
+	"资金流水单号":    fundFlowString(func(r *FundFlowRecord) *string { return &r.FlowID }),
+	"业务名称":      fundFlowString(func(r *FundFlowRecord) *string { return &r.BizName }),
+	"业务类型":      fundFlowString(func(r *FundFlowRecord) *string { return &r.BizType }),
+	"收支类型":      fundFlowString(func(r *FundFlowRecord) *string { return &r.FlowType }),
+	"收支金额（元）":   fundFlowFen(func(r *FundFlowRecord) *int { return &r.Amount }),
+	"账户结余（元）":   fundFlowFen(func(r *FundFlowRecord) *int { return &r.Balance }),
+	"资金变更提交申请人": fundFlowString(func(r *FundFlowRecord) *string { return &r.Applicant }),
+	"备注":        fundFlowString(func(r *FundFlowRecord) *string { return &r.Remark }),
+	"业务凭证号":     fundFlowString(func(r *FundFlowRecord) *string { return &r.VoucherNO }),
+}
+
+func fundFlowSummaryInt(f func(*FundFlowSummary) *int) func(*FundFlowSummary, string) error {
+	return func(s *FundFlowSummary, v string) (err error) {
+		*f(s), err = strconv.Atoi(v)
+		return err
+	}
+}
+
+func fundFlowSummaryFen(f func(*FundFlowSummary) *int) func(*FundFlowSummary, string) error {
+	return func(s *FundFlowSummary, v string) (err error) {
+		*f(s), err = parseFen(v)
+		return err
+	}
+}
+
+// 资金账单汇总数据各列的处理函数
+var fundFlowSummaryColumns = map[string]func(*FundFlowSummary, string) error{
+	"资金流水总笔数": fundFlowSummaryInt(func(s *FundFlowSummary) *int { return &s.Count }),
+	"收入笔数":    fundFlowSummaryInt(func(s *FundFlowSummary) *int { return &s.IncomeCount }),
+	"收入金额":    fundFlowSummaryFen(func(s *FundFlowSummary) *int { return &s.Income }),
+	"支出笔数":    fundFlowSummaryInt(func(s *FundFlowSummary) *int { return &s.ExpenseCount }),
+	"支出金额":    fundFlowSummaryFen(func(s *FundFlowSummary) *int { return &s.Expense }),
+}
+
+// ParseFundFlow 解析资金账单
+//
+// r 为未压缩的账单内容；f 为每一条记录的回调函数，返回错误会中断解析。
+// 无法识别的列会被忽略。
+func ParseFundFlow(r io.Reader, f func(*FundFlowRecord) error) (*FundFlowSummary, error) {
+	sum := &FundFlowSummary{}
+
+	err := parse(r, "资金流水总笔数", func(header, cols []string) error {
+		record := &FundFlowRecord{}
+		for i, name := range header {
+			if set, found := fundFlowColumns[name]; found {
+				if err := set(record, cols[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return f(record)
+	}, func(header, cols []string) error {
+		for i, name := range header {
+			if set, found := fundFlowSummaryColumns[name]; found {
+				if err := set(sum, cols[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sum, nil
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package bill
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+// TradeRecord 交易账单中的记录
+//
+// 金额均已转换成以分为单位。不同类型的账单包含的列并不相同，不存在的列为零值。
+type TradeRecord struct {
+	Time               time.Time // 交易时间
+	AppID              string    // 公众账号 ID
+	MchID              string    // 商户号
+	SubMchID           string    // 特约商户号
+	DeviceInfo         string    // 设备号
+	TransactionID      string    // 微信订单号
+	OutTradeNO         string    // 商户订单号
+	OpenID             string    // 用户标识
+	TradeType          string    // 交易类型
+	TradeState         string    // 交易状态
+	BankType           string    // 付款银行
+	FeeType            string    // 货币种类
+	SettlementTotalFee int       // 应结订单金额
+	CouponFee          int       // 代金券金额
+	RefundID           string    // 微信退款单号
+	OutRefundNO        string    // 商户退款单号
+	RefundFee          int       // 退款金额
+	CouponRefundFee    int       // 充值券退款金额
+	RefundType         string    // 退款类型
+	RefundStatus       string    // 退款状态
+	Body               string    // 商品名称
+	Attach             string    // 商户数据包
+	ServiceCharge      int       // 手续费
+	Rate               string    // 费率
+	TotalFee           int       // 订单金额
+	ApplyRefundFee     int       // 申请退款金额
+	RateRemark         string    // 费率备注
+}
+
+// TradeSummary 交易账单的汇总数据
+type TradeSummary struct {
+	Count              int // 总交易单数
+	SettlementTotalFee int // 应结订单总金额
+	RefundFee          int // 退款总金额
+	CouponRefundFee    int // 充值券退款总金额
+	ServiceCharge      int // 手续费总金额
+	TotalFee           int // 订单总金额
+	ApplyRefundFee     int // 申请退款总金额
+}
+
+func tradeString(f func(*TradeRecord) *string) func(*TradeRecord, string) error {
+	return func(r *TradeRecord, v string) error {
+		*f(r) = v
+		return nil
+	}
+}
+
+func tradeFen(f func(*TradeRecord) *int) func(*TradeRecord, string) error {
+	return func(r *TradeRecord, v string) (err error) {
+		*f(r), err = parseFen(v)
+		return err
+	}
+}
+
+// 交易账单各列的处理函数
+var tradeColumns = map[string]func(*TradeRecord, string) error{
+	"交易时间": func(r *TradeRecord, v string) (err error) {
+		r.Time, err = parseTime(v)
+		return err
+	},
+	"公众账号ID":  tradeString(func(r *TradeRecord) *string { return &r.AppID }),
+	"商户号":     tradeString(func(r *TradeRecord) *string { return &r.MchID }),
+	"特约商户号":   tradeString(func(r *TradeRecord) *string { return &r.SubMchID }),
+	"子商户号":    tradeString(func(r *TradeRecord) *string { return &r.SubMchID }),
+	"设备号":     tradeString(func(r *TradeRecord) *string { return &r.DeviceInfo }),
+	"微信订单号":   tradeString(func(r *TradeRecord) *string { return &r.TransactionID }),
+	"商户订单号":   tradeString(func(r *TradeRecord) *string { return &r.OutTradeNO }),
+	"用户标识":    tradeString(func(r *TradeRecord) *string { return &r.OpenID }),
+	"交易类型":    tradeString(func(r *TradeRecord) *string { return &r.TradeType }),
+	"交易状态":    tradeString(func(r *TradeRecord) *string { return &r.TradeState }),
+	"付款银行":    tradeString(func(r *TradeRecord) *string { return &r.BankType }),
+	"货币种类":    tradeString(func(r *TradeRecord) *string { return &r.FeeType }),
+	"应结订单金额":  tradeFen(func(r *TradeRecord) *int { return &r.SettlementTotalFee }),
+	"代金券金额":   tradeFen(func(r *TradeRecord) *int { return &r.CouponFee }),
+	"微信退款单号":  tradeString(func(r *TradeRecord) *string { return &r.RefundID }),
+	"商户退款单号":  tradeString(func(r *TradeRecord) *string { return &r.OutRefundNO }),
+	"退款金额":    tradeFen(func(r *TradeRecord) *int { return &r.RefundFee }),
+	"充值券退款金额": tradeFen(func(r *TradeRecord) *int { return &r.CouponRefundFee }),
+	"退款类型":    tradeString(func(r *TradeRecord) *string { return &r.RefundType }),
+	"退款状态":    tradeString(func(r *TradeRecord) *string { return &r.RefundStatus }),
+	"商品名称":    tradeString(func(r *TradeRecord) *string { return &r.Body }),
+	"商户数据包":   tradeString(func(r *TradeRecord) *string { return &r.Attach }),
+	"手续费":     tradeFen(func(r *TradeRecord) *int { return &r.ServiceCharge }),
+	"费率":      tradeString(func(r *TradeRecord) *string { return &r.Rate }),
+	"订单金额":    tradeFen(func(r *TradeRecord) *int { return &r.TotalFee }),
+	"申请退款金额":  tradeFen(func(r *TradeRecord) *int { return &r.ApplyRefundFee }),
+	"费率备注":    tradeString(func(r *TradeRecord) *string { return &r.RateRemark }),
+}
+
+func tradeSummaryFen(f func(*TradeSummary) *int) func(*TradeSummary, string) error {
+	return func(s *TradeSummary, v string) (err error) {
+		*f(s), err = parseFen(v)
+		return err
+	}
+}
+
+// 交易账单汇总数据各列的处理函数
+var tradeSummaryColumns = map[string]func(*TradeSummary, string) error{
+	"总交易单数": func(s *TradeSummary, v string) (err error) {
+		s.Count, err = strconv.Atoi(v)
+		return err
+	},
+	"应结订单总金额":  tradeSummaryFen(func(s *TradeSummary) *int { return &s.SettlementTotalFee }),
+	"退款总金额":    tradeSummaryFen(func(s *TradeSummary) *int { return &s.RefundFee }),
+	"充值券退款总金额": tradeSummaryFen(func(s *TradeSummary) *int { return &s.CouponRefundFee }),
+	"手续费总金额":   tradeSummaryFen(func(s *TradeSummary) *int { return &s.ServiceCharge }),
+	"订单总金额":    tradeSummaryFen(func(s *TradeSummary) *int { return &s.TotalFee }),
+	"申请退款总金额":  tradeSummaryFen(func(s *TradeSummary) *int { return &s.ApplyRefundFee }),
+}
+
+// ParseTrade 解析交易账单
+//
+// r 为未压缩的账单内容；f 为每一条记录的回调函数，返回错误会中断解析。
+// 无法识别的列会被忽略。
+func ParseTrade(r io.Reader, f func(*TradeRecord) error) (*TradeSummary, error) {
+	sum := &TradeSummary{}
+
+	err := parse(r, "总交易单数", func(header, cols []string) error {
+		record := &TradeRecord{}
+		for i, name := range header {
+			if set, found := tradeColumns[name]; found {
+				if err := set(record, cols[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return f(record)
+	}, func(header, cols []string) error {
+		for i, name := range header {
+			if set, found := tradeSummaryColumns[name]; found {
+				if err := set(sum, cols[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sum, nil
+}
@@ -18,6 +18,17 @@ const TimeFixed time.Duration = 3600 * 8
 // DateFormat 日期格式
 const DateFormat = "20060102150405"
 
+// Location 接口中的时间所采用的时区，即北京时间
+//
+// 解析接口返回的时间时，应该采用 time.ParseInLocation 并指定此值。
+var Location = time.FixedZone("CST", 8*3600)
+
+// BaseURL 接口地址的前缀
+const BaseURL = "https://api.mch.weixin.qq.com"
+
+// SandboxPath 仿真测试环境下接口地址的路径前缀
+const SandboxPath = "/sandboxnew"
+
 // 接口地址
 const (
 	UnifiedOrderURL = "https://api.mch.weixin.qq.com/pay/unifiedorder"
@@ -26,14 +37,50 @@ const (
 	RefundURL       = "https://api.mch.weixin.qq.com/secapi/pay/refund"
 	RefundQueryURL  = "https://api.mch.weixin.qq.com/pay/refundquery"
 	DownloadBillURL = "https://api.mch.weixin.qq.com/pay/downloadbill"
+	FundFlowURL     = "https://api.mch.weixin.qq.com/pay/downloadfundflow"
 	ReportURL       = "https://api.mch.weixin.qq.com/payitil/report"
+	MicropayURL     = "https://api.mch.weixin.qq.com/pay/micropay"
+	ReverseURL      = "https://api.mch.weixin.qq.com/secapi/pay/reverse"
+	GetSignKeyURL   = "https://api.mch.weixin.qq.com/sandboxnew/pay/getsignkey"
+
+	TransfersURL       = "https://api.mch.weixin.qq.com/mmpaymkttransfers/promotion/transfers"
+	GetTransferInfoURL = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gettransferinfo"
+	PayBankURL         = "https://api.mch.weixin.qq.com/mmpaysptrans/pay_bank"
+	QueryBankURL       = "https://api.mch.weixin.qq.com/mmpaysptrans/query_bank"
+	GetPublicKeyURL    = "https://fraud.mch.weixin.qq.com/risk/getpublickey"
+
+	SendRedpackURL      = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendredpack"
+	SendGroupRedpackURL = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendgroupredpack"
+	GetHBInfoURL        = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gethbinfo"
+
+	ProfitSharingAddReceiverURL    = "https://api.mch.weixin.qq.com/pay/profitsharingaddreceiver"
+	ProfitSharingRemoveReceiverURL = "https://api.mch.weixin.qq.com/pay/profitsharingremovereceiver"
+	ProfitSharingURL               = "https://api.mch.weixin.qq.com/secapi/pay/profitsharing"
+	MultiProfitSharingURL          = "https://api.mch.weixin.qq.com/secapi/pay/multiprofitsharing"
+	ProfitSharingQueryURL          = "https://api.mch.weixin.qq.com/pay/profitsharingquery"
+	ProfitSharingFinishURL         = "https://api.mch.weixin.qq.com/secapi/pay/profitsharingfinish"
+	ProfitSharingReturnURL         = "https://api.mch.weixin.qq.com/secapi/pay/profitsharingreturn"
+	ProfitSharingReturnQueryURL    = "https://api.mch.weixin.qq.com/pay/profitsharingreturnquery"
 )
 
 // 交易类型
 const (
-	TradeTypeJSAPI  = "JSAPI"  // 公众号
-	TradeTypeNative = "NATIVE" // 扫码
-	TradeTypeApp    = "APP"
+	TradeTypeJSAPI    = "JSAPI"  // 公众号
+	TradeTypeNative   = "NATIVE" // 扫码
+	TradeTypeApp      = "APP"
+	TradeTypeMWEB     = "MWEB"     // H5
+	TradeTypeMicropay = "MICROPAY" // 付款码
+)
+
+// 交易状态
+const (
+	TradeStateSuccess    = "SUCCESS"    // 支付成功
+	TradeStateRefund     = "REFUND"     // 转入退款
+	TradeStateNotPay     = "NOTPAY"     // 未支付
+	TradeStateClosed     = "CLOSED"     // 已关闭
+	TradeStateRevoked    = "REVOKED"    // 已撤销（付款码支付）
+	TradeStateUserPaying = "USERPAYING" // 用户支付中（付款码支付）
+	TradeStatePayError   = "PAYERROR"   // 支付失败
 )
 
 // 签名的类型
@@ -44,10 +91,18 @@ const (
 
 // 退款奖金来源
 const (
-	RefundSourceRechargeFunds  = "REFUND_SOURCE_UNSETTLED_FUNDS" // 可用余额退款
+	RefundSourceRechargeFunds  = "REFUND_SOURCE_RECHARGE_FUNDS"  // 可用余额退款
 	RefundSourceUnsettledFunds = "REFUND_SOURCE_UNSETTLED_FUNDS" // 未结算资金退款（默认使用未结算资金退款）
 )
 
+// 退款状态
+const (
+	RefundStatusSuccess     = "SUCCESS"     // 退款成功
+	RefundStatusChange      = "CHANGE"      // 退款异常
+	RefundStatusRefundClose = "REFUNDCLOSE" // 退款关闭
+	RefundStatusProcessing  = "PROCESSING"  // 退款处理中
+)
+
 // 退款渠道
 const (
 	RefundChannelOriginal = "ORIGINAL" // 原路退款
@@ -101,3 +101,80 @@ func getCouponIndex(name, prefix string) (int, error) {
 
 	return index, nil
 }
+
+// GetIndexed 从 params 中获取以 _$n 或是 _$n_$m 等形式结尾的字段
+//
+// index 为各级索引值，返回的 map 中，键名为去掉索引后缀之后的名称。
+// 仅返回与 index 层级相同的字段，比如 GetIndexed(params, 0) 会返回
+// refund_fee_0，但不会返回 coupon_refund_fee_0_1。
+func GetIndexed(params map[string]string, index ...int) map[string]string {
+	var suffix string
+	for _, i := range index {
+		suffix += "_" + strconv.Itoa(i)
+	}
+
+	ret := make(map[string]string, 10)
+	for name, val := range params {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		name = strings.TrimSuffix(name, suffix)
+		if name == "" || hasIndexSuffix(name) {
+			continue
+		}
+		ret[name] = val
+	}
+
+	return ret
+}
+
+// GetNestedCoupons 获取第 n 个子项下的代金券信息
+//
+// 用于退款查询等返回 coupon_refund_id_$n_$m 格式的接口，
+// prefix 为代金券各字段的前缀，比如退款查询为 coupon_refund_，
+// 则会依次读取 coupon_refund_id_$n_$m、coupon_type_$n_$m 和 coupon_refund_fee_$n_$m。
+// count 为代金券的数量。
+func GetNestedCoupons(params map[string]string, prefix string, n, count int) ([]*Coupon, error) {
+	ret := make([]*Coupon, 0, count)
+	for m := 0; m < count; m++ {
+		item := GetIndexed(params, n, m)
+
+		c := &Coupon{Type: item["coupon_type"]}
+
+		if v := item[prefix+"id"]; v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, err
+			}
+			c.ID = id
+		}
+
+		if v := item[prefix+"fee"]; v != "" {
+			fee, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, err
+			}
+			c.Fee = fee
+		}
+
+		ret = append(ret, c)
+	}
+
+	return ret, nil
+}
+
+// name 是否以 _$n 结尾
+func hasIndexSuffix(name string) bool {
+	index := strings.LastIndexByte(name, '_')
+	if index < 0 || index == len(name)-1 {
+		return false
+	}
+
+	for _, c := range name[index+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -52,3 +52,52 @@ func TestGetCoupons(t *testing.T) {
 	a.Equal(coupons[1].ID, 1)
 	a.Equal(coupons[2].ID, 2)
 }
+
+func TestGetIndexed(t *testing.T) {
+	a := assert.New(t, false)
+	maps := map[string]string{
+		"refund_count":            "2",
+		"refund_fee_0":            "10",
+		"coupon_refund_fee_0":     "5",
+		"coupon_refund_fee_0_0":   "3",
+		"coupon_refund_fee_0_1":   "2",
+		"coupon_refund_id_0_1":    "11",
+		"coupon_type_0_1":         CouponTypeCash,
+		"refund_fee_1":            "20",
+		"refund_fee_10":           "30",
+		"coupon_refund_fee_1_0":   "1",
+		"coupon_refund_count_0":   "2",
+		"coupon_refund_id_0_0":    "10",
+		"coupon_type_0_0":         CouponTypeNoCash,
+		"settlement_refund_fee_1": "20",
+	}
+
+	item := GetIndexed(maps, 0)
+	a.Equal(item, map[string]string{
+		"refund_fee":          "10",
+		"coupon_refund_fee":   "5",
+		"coupon_refund_count": "2",
+	})
+
+	item = GetIndexed(maps, 1)
+	a.Equal(item, map[string]string{
+		"refund_fee":            "20",
+		"settlement_refund_fee": "20",
+	})
+
+	item = GetIndexed(maps, 0, 1)
+	a.Equal(item, map[string]string{
+		"coupon_refund_fee": "2",
+		"coupon_refund_id":  "11",
+		"coupon_type":       CouponTypeCash,
+	})
+
+	coupons, err := GetNestedCoupons(maps, "coupon_refund_", 0, 2)
+	a.NotError(err).Length(coupons, 2)
+	a.Equal(coupons[0], &Coupon{ID: 10, Type: CouponTypeNoCash, Fee: 3}).
+		Equal(coupons[1], &Coupon{ID: 11, Type: CouponTypeCash, Fee: 2})
+
+	maps["coupon_refund_id_0_0"] = "x"
+	coupons, err = GetNestedCoupons(maps, "coupon_refund_", 0, 2)
+	a.Error(err).Nil(coupons)
+}
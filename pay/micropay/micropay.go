@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package micropay 付款码支付
+//
+// 付款码支付在用户需要输入密码等情况下不会立即返回结果，
+// 此时会定时查询订单状态，直到支付成功或是超时，超时之后会撤销订单。
+//
+//	p := pay.NewTLSPay(...) // 撤销订单需要证书
+//	m := micropay.New(p)
+//	m.SpbillCreateIP = "127.0.0.1"
+//	m.Timeout = 30 * time.Second
+//
+//	ret, err := m.Pay(ctx, &micropay.Order{
+//	    AuthCode: "120061098828009406",
+//	    Body: "body",
+//	    OutTradeNO: "1415757673",
+//	    TotalFee: 1,
+//	})
+package micropay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// 默认的配置项
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultInterval       = 5 * time.Second
+	defaultReverseRetries = 3
+)
+
+// 预定义的错误类型
+var (
+	// ErrReversed 在规定的时间内未完成支付，订单已被撤销。
+	ErrReversed = errors.New("订单未能在规定的时间内完成支付，已被撤销")
+
+	// ErrReverseFailed 订单撤销失败，需要人工介入处理。
+	ErrReverseFailed = errors.New("订单撤销失败")
+
+	// ErrPayFailed 订单处于 PAYERROR 等无法再完成支付的状态，订单已被撤销。
+	//
+	// 返回的错误信息中包含了订单状态 trade_state 及其描述。
+	ErrPayFailed = errors.New("支付失败")
+)
+
+// 需要查询订单状态的错误代码
+var waitingCodes = map[string]bool{
+	"USERPAYING":  true, // 用户支付中，需要输入密码
+	"SYSTEMERROR": true, // 系统超时
+	"BANKERROR":   true, // 银行端超时
+}
+
+// Micropay 付款码支付的配置
+type Micropay struct {
+	pay            *pay.Pay
+	SignType       string        // 签名类型
+	DeviceInfo     string        // 设备号
+	SpbillCreateIP string        // 终端 IP
+	FeeType        string        // 货币类型
+	Timeout        time.Duration // 等待用户支付的最长时间，默认为 30 秒
+	Interval       time.Duration // 查询订单状态的间隔，默认为 5 秒
+	ReverseRetries int           // 撤销订单失败时的重试次数，默认为 3，负数表示不重试
+}
+
+// Order 付款码支付的订单
+type Order struct {
+	AuthCode   string // 付款码
+	Body       string // 商品描述
+	Detail     string // 商品详情
+	Attach     string // 附加数据
+	OutTradeNO string // 商户订单号
+	TotalFee   int    // 订单金额
+	GoodsTag   string // 订单优惠标记
+}
+
+// Return 付款码支付的返回值
+type Return struct {
+	DeviceInfo    string `xml:"device_info"`    // 设备号
+	OpenID        string `xml:"openid"`         // 用户标识
+	IsSubscribe   string `xml:"is_subscribe"`   // 是否关注公众账号
+	TradeType     string `xml:"trade_type"`     // 交易类型
+	BankType      string `xml:"bank_type"`      // 付款银行
+	FeeType       string `xml:"fee_type"`       // 货币类型
+	TotalFee      int    `xml:"total_fee"`      // 订单金额
+	CouponFee     int    `xml:"coupon_fee"`     // 代金券金额
+	CashFee       int    `xml:"cash_fee"`       // 现金支付金额
+	CashFeeType   string `xml:"cash_fee_type"`  // 现金支付货币类型
+	TransactionID string `xml:"transaction_id"` // 微信支付订单号
+	OutTradeNO    string `xml:"out_trade_no"`   // 商户订单号
+	Attach        string `xml:"attach"`         // 商家数据包
+	TimeEnd       string `xml:"time_end"`       // 支付完成时间，格式为yyyyMMddHHmmss
+
+	end time.Time
+}
+
+// End 返回 TimeEnd 的 time.Time 格式数据
+func (ret *Return) End() time.Time {
+	return ret.end
+}
+
+// New 声明 Micropay 实例
+func New(p *pay.Pay) *Micropay {
+	return &Micropay{
+		pay: p,
+	}
+}
+
+// Pay 执行付款码支付
+//
+// 如果用户正在支付中，会每隔 Interval 查询一次订单状态，
+// 超过 Timeout 或是 ctx 被取消时仍未支付成功，则撤销订单，并返回 [ErrReversed]；
+// 订单处于无法再完成支付的状态时，撤销订单并返回 [ErrPayFailed]；
+// 撤销失败则返回 [ErrReverseFailed]。
+func (m *Micropay) Pay(ctx context.Context, o *Order) (*Return, error) {
+	params, err := m.pay.PostContext(ctx, pay.MicropayURL, map[string]string{
+		"device_info":      m.DeviceInfo,
+		"sign_type":        m.SignType,
+		"body":             o.Body,
+		"detail":           o.Detail,
+		"attach":           o.Attach,
+		"out_trade_no":     o.OutTradeNO,
+		"total_fee":        strconv.Itoa(o.TotalFee),
+		"fee_type":         m.FeeType,
+		"spbill_create_ip": m.SpbillCreateIP,
+		"goods_tag":        o.GoodsTag,
+		"auth_code":        o.AuthCode,
+	})
+
+	switch {
+	case err != nil: // 网络错误等，无法确定支付状态
+	case params["return_code"] != pay.Success:
+		return nil, m.pay.ValidateReturn(params)
+	case params["result_code"] == pay.Success:
+		if err = m.pay.ValidateAll(m.SignType, params); err != nil {
+			return nil, err
+		}
+		return newReturn(params)
+	case !waitingCodes[params["err_code"]]:
+		return nil, m.pay.ValidateResult(params)
+	}
+
+	return m.wait(ctx, o.OutTradeNO)
+}
+
+// 等待用户完成支付
+func (m *Micropay) wait(ctx context.Context, outTradeNO string) (*Return, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout())
+	defer cancel()
+
+	timer := time.NewTimer(m.interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, m.reverse(outTradeNO)
+		case <-timer.C:
+		}
+
+		params, err := m.pay.PostContext(ctx, pay.OrderQueryURL, map[string]string{
+			"out_trade_no": outTradeNO,
+			"sign_type":    m.SignType,
+		})
+		if err == nil {
+			err = m.pay.ValidateAll(m.SignType, params)
+		}
+
+		if err == nil {
+			switch params["trade_state"] {
+			case pay.TradeStateSuccess:
+				return newReturn(params)
+			case pay.TradeStateUserPaying, pay.TradeStateNotPay:
+			default: // PAYERROR、CLOSED、REVOKED 等无法再完成支付的状态
+				if err := m.reverse(outTradeNO); !errors.Is(err, ErrReversed) {
+					return nil, err
+				}
+				return nil, fmt.Errorf("%w，订单状态为 %s：%s", ErrPayFailed, params["trade_state"], params["trade_state_desc"])
+			}
+		}
+
+		timer.Reset(m.interval())
+	}
+}
+
+// 撤销订单
+//
+// 撤销订单时，原有的 ctx 可能已经被取消，所以采用新的 context.Context，
+// 包括重试在内，最多执行 Timeout 的时间。
+func (m *Micropay) reverse(outTradeNO string) error {
+	retries := m.ReverseRetries
+	if retries == 0 {
+		retries = defaultReverseRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout())
+	defer cancel()
+
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w：%s", ErrReverseFailed, ctx.Err().Error())
+			case <-time.After(m.interval()):
+			}
+		}
+
+		params, err := m.pay.PostContext(ctx, pay.ReverseURL, map[string]string{
+			"out_trade_no": outTradeNO,
+			"sign_type":    m.SignType,
+		})
+		if err != nil {
+			continue
+		}
+
+		if err = m.pay.ValidateAll(m.SignType, params); err == nil {
+			return ErrReversed
+		}
+
+		if params["recall"] == "N" { // 无需重试
+			return fmt.Errorf("%w：%s", ErrReverseFailed, err.Error())
+		}
+	}
+
+	return ErrReverseFailed
+}
+
+func (m *Micropay) timeout() time.Duration {
+	if m.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return m.Timeout
+}
+
+func (m *Micropay) interval() time.Duration {
+	if m.Interval <= 0 {
+		return defaultInterval
+	}
+	return m.Interval
+}
+
+func newReturn(params map[string]string) (*Return, error) {
+	ret := &Return{}
+	if err := xxml.Map2XMLObj(params, ret); err != nil {
+		return nil, err
+	}
+
+	if ret.TimeEnd != "" {
+		end, err := time.ParseInLocation(pay.DateFormat, ret.TimeEnd, pay.Location)
+		if err != nil {
+			return nil, err
+		}
+		ret.end = end
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package micropay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+)
+
+// 模拟微信的返回内容
+//
+// responses 以 url 为键名，每次请求依次返回其中的内容，最后一项会被重复使用，
+// nil 表示网络错误。返回的函数用于获取各个 url 的请求次数。
+func newTestMicropay(a *assert.Assertion, responses map[string][]map[string]string) (*Micropay, func(string) int) {
+	p := pay.New("mchid", "appid", "apikey", nil)
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		url := pay.BaseURL + r.URL.Path
+		list := responses[url]
+		a.NotEmpty(list, url)
+
+		mu.Lock()
+		index := counts[url]
+		counts[url]++
+		mu.Unlock()
+		if index >= len(list) {
+			index = len(list) - 1
+		}
+
+		if list[index] == nil {
+			panic(http.ErrAbortHandler) // 中断连接，模拟网络错误
+		}
+
+		ret := map[string]string{
+			"return_code": pay.Success,
+			"appid":       "appid",
+			"mch_id":      "mchid",
+		}
+		for k, v := range list[index] {
+			ret[k] = v
+		}
+		sign, err := p.Sign("", ret)
+		a.NotError(err)
+		ret["sign"] = sign
+
+		buf := &bytes.Buffer{}
+		buf.WriteString("<xml>")
+		for k, v := range ret {
+			buf.WriteString("<" + k + "><![CDATA[" + v + "]]></" + k + ">")
+		}
+		buf.WriteString("</xml>")
+		w.Write(buf.Bytes())
+	}))
+	a.TB().Cleanup(srv.Close)
+	p = p.SetBaseURL(srv.URL)
+
+	m := New(p)
+	m.Timeout = 100 * time.Millisecond
+	m.Interval = 10 * time.Millisecond
+
+	return m, func(url string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[url]
+	}
+}
+
+func TestMicropay_Pay(t *testing.T) {
+	a := assert.New(t, false)
+	o := &Order{AuthCode: "120061098828009406", OutTradeNO: "1415757673", TotalFee: 1}
+
+	// 直接成功
+	m, counts := newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL: {{"result_code": pay.Success, "transaction_id": "1", "time_end": "20141030133525"}},
+	})
+	ret, err := m.Pay(context.Background(), o)
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.TransactionID, "1").
+		Equal(ret.End(), time.Date(2014, 10, 30, 13, 35, 25, 0, pay.Location)).
+		Equal(counts(pay.OrderQueryURL), 0)
+
+	// 确定的错误，不需要查询
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL: {{"result_code": pay.Fail, "err_code": "AUTHCODEEXPIRE"}},
+	})
+	ret, err = m.Pay(context.Background(), o)
+	a.Error(err).Nil(ret).Equal(counts(pay.OrderQueryURL), 0)
+
+	// 用户支付中，查询之后成功
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL: {{"result_code": pay.Fail, "err_code": "USERPAYING"}},
+		pay.OrderQueryURL: {
+			{"result_code": pay.Success, "trade_state": pay.TradeStateUserPaying},
+			nil, // 网络错误
+			{"result_code": pay.Success, "trade_state": pay.TradeStateSuccess, "transaction_id": "2"},
+		},
+	})
+	ret, err = m.Pay(context.Background(), o)
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.TransactionID, "2").
+		Equal(counts(pay.OrderQueryURL), 3).
+		Equal(counts(pay.ReverseURL), 0)
+
+	// 网络错误，超时之后撤销
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {nil},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStateUserPaying}},
+		pay.ReverseURL:    {{"result_code": pay.Fail, "recall": "Y"}, {"result_code": pay.Success, "recall": "N"}},
+	})
+	ret, err = m.Pay(context.Background(), o)
+	a.ErrorIs(err, ErrReversed).Nil(ret).Equal(counts(pay.ReverseURL), 2)
+
+	// ctx 被取消
+	m, _ = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {{"result_code": pay.Fail, "err_code": "SYSTEMERROR"}},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStateUserPaying}},
+		pay.ReverseURL:    {{"result_code": pay.Success}},
+	})
+	m.Timeout = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ret, err = m.Pay(ctx, o)
+	a.ErrorIs(err, ErrReversed).Nil(ret)
+
+	// 支付失败，撤销失败
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {{"result_code": pay.Fail, "err_code": "USERPAYING"}},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStatePayError}},
+		pay.ReverseURL:    {{"result_code": pay.Fail, "err_code": "SYSTEMERROR", "recall": "Y"}},
+	})
+	m.ReverseRetries = 1
+	ret, err = m.Pay(context.Background(), o)
+	a.ErrorIs(err, ErrReverseFailed).Nil(ret).Equal(counts(pay.ReverseURL), 2)
+
+	// 撤销失败，无需重试
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {{"result_code": pay.Fail, "err_code": "USERPAYING"}},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStateUserPaying}},
+		pay.ReverseURL:    {{"result_code": pay.Fail, "err_code": "INVALID_TRANSACTIONID", "recall": "N"}},
+	})
+	ret, err = m.Pay(context.Background(), o)
+	a.ErrorIs(err, ErrReverseFailed).Nil(ret).Equal(counts(pay.ReverseURL), 1)
+
+	// 订单状态为 PAYERROR，撤销成功之后返回订单状态
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {{"result_code": pay.Fail, "err_code": "USERPAYING"}},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStatePayError, "trade_state_desc": "余额不足"}},
+		pay.ReverseURL:    {{"result_code": pay.Success, "recall": "N"}},
+	})
+	ret, err = m.Pay(context.Background(), o)
+	a.ErrorIs(err, ErrPayFailed).Nil(ret).
+		False(errors.Is(err, ErrReversed)).
+		Equal(counts(pay.ReverseURL), 1)
+	a.Contains(err.Error(), pay.TradeStatePayError).Contains(err.Error(), "余额不足")
+
+	// 订单状态为 PAYERROR，撤销失败
+	m, _ = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {{"result_code": pay.Fail, "err_code": "USERPAYING"}},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStatePayError}},
+		pay.ReverseURL:    {{"result_code": pay.Fail, "err_code": "SYSTEMERROR", "recall": "N"}},
+	})
+	ret, err = m.Pay(context.Background(), o)
+	a.ErrorIs(err, ErrReverseFailed).Nil(ret).False(errors.Is(err, ErrPayFailed))
+
+	// 撤销一直出错，在 Timeout 之后放弃重试
+	m, counts = newTestMicropay(a, map[string][]map[string]string{
+		pay.MicropayURL:   {{"result_code": pay.Fail, "err_code": "USERPAYING"}},
+		pay.OrderQueryURL: {{"result_code": pay.Success, "trade_state": pay.TradeStateUserPaying}},
+		pay.ReverseURL:    {nil},
+	})
+	m.ReverseRetries = 1000
+	start := time.Now()
+	ret, err = m.Pay(context.Background(), o)
+	a.ErrorIs(err, ErrReverseFailed).Nil(ret).
+		True(time.Since(start) < time.Second).
+		True(counts(pay.ReverseURL) < 1000)
+}
@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package notify
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// RefundDateFormat 退款通知中 success_time 的格式
+const RefundDateFormat = "2006-01-02 15:04:05"
+
+// Refund 退款结果通知的信息
+type Refund struct {
+	TransactionID       string `xml:"transaction_id"`        // 微信订单号
+	OutTradeNO          string `xml:"out_trade_no"`          // 商户订单号
+	RefundID            string `xml:"refund_id"`             // 微信退款单号
+	OutRefundNO         string `xml:"out_refund_no"`         // 商户退款单号
+	TotalFee            int    `xml:"total_fee"`             // 订单金额
+	SettlementTotalFee  int    `xml:"settlement_total_fee"`  // 应结订单金额
+	RefundFee           int    `xml:"refund_fee"`            // 申请退款金额
+	SettlementRefundFee int    `xml:"settlement_refund_fee"` // 退款金额
+	RefundStatus        string `xml:"refund_status"`         // 退款状态，pay.RefundStatus* 系列常量
+	SuccessTime         string `xml:"success_time"`          // 退款成功时间，格式为 yyyy-MM-dd HH:mm:ss
+	RefundRecvAccout    string `xml:"refund_recv_accout"`    // 退款入账账户
+	RefundAccount       string `xml:"refund_account"`        // 退款资金来源，pay.RefundSource* 系列常量
+	RefundRequestSource string `xml:"refund_request_source"` // 退款发起来源，API 或是 VENDOR_PLATFORM
+
+	Coupons []*pay.Coupon
+	success time.Time
+}
+
+// Success 返回 SuccessTime 的 time.Time 格式数据
+//
+// 退款未成功时，返回零值。
+func (ret *Refund) Success() time.Time {
+	return ret.success
+}
+
+// ReadRefund 从 r 读取退款结果通知的内容，并尝试转换成 Refund 实例
+//
+// 退款结果通知没有签名，其 req_info 字段以 apikey 加密，解密成功即表示内容可信。
+func ReadRefund(p *pay.Pay, r io.Reader) (*Refund, error) {
+	params, err := xxml.MapFromXMLReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) == 0 {
+		return nil, errors.New("未读取到任何数据")
+	}
+
+	if err = p.ValidateReturn(params); err != nil {
+		return nil, err
+	}
+
+	if params["mch_id"] != p.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+
+	if params["appid"] != p.AppID() {
+		return nil, pay.ErrInvalidAppid
+	}
+
+	data, err := decryptReqInfo(p.APIKey(), params["req_info"])
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := xxml.MapFromXMLReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Refund{}
+	if err = xxml.Map2XMLObj(info, ret); err != nil {
+		return nil, err
+	}
+
+	if ret.Coupons, err = pay.GetCoupons(info); err != nil {
+		return nil, err
+	}
+
+	if ret.SuccessTime != "" {
+		if ret.success, err = time.ParseInLocation(RefundDateFormat, ret.SuccessTime, pay.Location); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// 解密 req_info
+//
+// 以 apikey 的 md5 值（小写）作为密钥，采用 AES-256-ECB 解密，填充方式为 PKCS#7。
+func decryptReqInfo(apikey, reqInfo string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(reqInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum([]byte(apikey))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		return nil, err
+	}
+
+	size := block.BlockSize()
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, errors.New("req_info 的长度不正确")
+	}
+
+	for i := 0; i < len(data); i += size {
+		block.Decrypt(data[i:i+size], data[i:i+size])
+	}
+
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > size || pad > len(data) {
+		return nil, errors.New("req_info 的填充内容不正确")
+	}
+	return data[:len(data)-pad], nil
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package notify
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+)
+
+// 以 AES-256-ECB 加密 req_info
+func encryptReqInfo(a *assert.Assertion, apikey string, data []byte) string {
+	sum := md5.Sum([]byte(apikey))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(sum[:])))
+	a.NotError(err)
+
+	size := block.BlockSize()
+	pad := size - len(data)%size
+	data = append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	for i := 0; i < len(data); i += size {
+		block.Encrypt(data[i:i+size], data[i:i+size])
+	}
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestReadRefund(t *testing.T) {
+	a := assert.New(t, false)
+	p := pay.New("mchid", "appid", "apikey", nil)
+
+	info := `<root>
+<out_refund_no><![CDATA[131811191610442717309]]></out_refund_no>
+<out_trade_no><![CDATA[71106718111915575302817]]></out_trade_no>
+<refund_account><![CDATA[REFUND_SOURCE_RECHARGE_FUNDS]]></refund_account>
+<refund_fee><![CDATA[3960]]></refund_fee>
+<refund_id><![CDATA[50000408942018111907145868882]]></refund_id>
+<refund_recv_accout><![CDATA[支付用户零钱]]></refund_recv_accout>
+<refund_request_source><![CDATA[API]]></refund_request_source>
+<refund_status><![CDATA[SUCCESS]]></refund_status>
+<settlement_refund_fee><![CDATA[3960]]></settlement_refund_fee>
+<settlement_total_fee><![CDATA[3960]]></settlement_total_fee>
+<success_time><![CDATA[2018-11-19 16:24:13]]></success_time>
+<total_fee><![CDATA[3960]]></total_fee>
+<transaction_id><![CDATA[4200000215201811190261405420]]></transaction_id>
+</root>`
+
+	body := `<xml>
+<return_code>SUCCESS</return_code>
+<appid><![CDATA[appid]]></appid>
+<mch_id><![CDATA[mchid]]></mch_id>
+<nonce_str><![CDATA[TeqClE3i0mvn3DrK]]></nonce_str>
+<req_info><![CDATA[` + encryptReqInfo(a, "apikey", []byte(info)) + `]]></req_info>
+</xml>`
+
+	ret, err := ReadRefund(p, strings.NewReader(body))
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.OutRefundNO, "131811191610442717309").
+		Equal(ret.RefundFee, 3960).
+		Equal(ret.RefundStatus, pay.RefundStatusSuccess).
+		Equal(ret.RefundAccount, pay.RefundSourceRechargeFunds).
+		Equal(ret.RefundRecvAccout, "支付用户零钱").
+		Equal(ret.Success(), time.Date(2018, 11, 19, 16, 24, 13, 0, pay.Location)).
+		Empty(ret.Coupons)
+
+	// apikey 不正确
+	p2 := pay.New("mchid", "appid", "other", nil)
+	_, err = ReadRefund(p2, strings.NewReader(body))
+	a.Error(err)
+
+	// mchid 不正确
+	p2 = pay.New("other", "appid", "apikey", nil)
+	_, err = ReadRefund(p2, strings.NewReader(body))
+	a.ErrorIs(err, pay.ErrInvalidMchid)
+
+	// return_code 为 FAIL
+	_, err = ReadRefund(p, strings.NewReader(`<xml><return_code>FAIL</return_code><return_msg>msg</return_msg></xml>`))
+	a.Error(err)
+}
@@ -34,6 +34,12 @@ type Return struct {
 	Attach             string `xml:"attach"`               // 商家数据包
 	TimeEnd            string `xml:"time_end"`             // 支付完成时间，格式为yyyyMMddHHmmss
 
+	// 以下仅服务商模式下有效
+	SubMchID       string `xml:"sub_mch_id"`       // 子商户号
+	SubAppID       string `xml:"sub_appid"`        // 子商户 appid
+	SubOpenID      string `xml:"sub_openid"`       // 用户在子商户 appid 下的标识
+	SubIsSubscribe string `xml:"sub_is_subscribe"` // 是否关注子商户公众账号，Y-关注，N-未关注
+
 	Coupons []*pay.Coupon
 	end     time.Time
 }
@@ -50,6 +56,38 @@ func (ret *Return) Subscribed() bool {
 
 // Read 从 r 读取内容，并尝试转换成 Return 实例
 func Read(p *pay.Pay, r io.Reader) (*Return, error) {
+	params, err := readParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReturn(p, params)
+}
+
+// ReadPartner 服务商模式下，从 r 读取内容，并尝试转换成 Return 实例
+//
+// lookup 根据通知中的 sub_mch_id 返回对应子商户的 *pay.Pay 实例，
+// 一般为服务商 [pay.Pay.Sub] 的返回值，通知的内容会以该实例进行验证。
+func ReadPartner(r io.Reader, lookup func(subMchID string) (*pay.Pay, error)) (*Return, error) {
+	params, err := readParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	subMchID := params["sub_mch_id"]
+	if subMchID == "" {
+		return nil, errors.New("缺少 sub_mch_id")
+	}
+
+	p, err := lookup(subMchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReturn(p, params)
+}
+
+func readParams(r io.Reader) (map[string]string, error) {
 	params, err := xxml.MapFromXMLReader(r)
 	if err != nil {
 		return nil, err
@@ -59,12 +97,16 @@ func Read(p *pay.Pay, r io.Reader) (*Return, error) {
 		return nil, errors.New("未读取到任何数据")
 	}
 
-	if err = p.ValidateAll(params["sign_type"], params); err != nil {
+	return params, nil
+}
+
+func newReturn(p *pay.Pay, params map[string]string) (*Return, error) {
+	if err := p.ValidateAll(params["sign_type"], params); err != nil {
 		return nil, err
 	}
 
 	ret := &Return{}
-	err = xxml.Map2XMLObj(params, ret)
+	err := xxml.Map2XMLObj(params, ret)
 	if err != nil {
 		return nil, err
 	}
@@ -79,12 +121,9 @@ func Read(p *pay.Pay, r io.Reader) (*Return, error) {
 	}
 
 	// 转换时间值
-	end, err := time.Parse(pay.DateFormat, ret.TimeEnd)
-	if err != nil {
+	if ret.end, err = time.ParseInLocation(pay.DateFormat, ret.TimeEnd, pay.Location); err != nil {
 		return nil, err
 	}
-	//ret.end = end.Add(-pay.TimeFixed) // 返回的为区八区，需要减去，才是 UTC
-	ret.end = end
 
 	return ret, nil
 }
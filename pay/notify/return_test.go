@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package notify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+)
+
+func buildReturn(a *assert.Assertion, p *pay.Pay, params map[string]string) string {
+	sign, err := p.Sign("", params)
+	a.NotError(err)
+	params["sign"] = sign
+
+	var buf strings.Builder
+	buf.WriteString("<xml>")
+	for k, v := range params {
+		buf.WriteString("<" + k + "><![CDATA[" + v + "]]></" + k + ">")
+	}
+	buf.WriteString("</xml>")
+	return buf.String()
+}
+
+func TestReadPartner(t *testing.T) {
+	a := assert.New(t, false)
+	sp := pay.New("mchid", "appid", "apikey", nil)
+	lookup := func(subMchID string) (*pay.Pay, error) {
+		if subMchID != "sub_mchid" {
+			return nil, errors.New("not found")
+		}
+		return sp.Sub("sub_mchid", "sub_appid"), nil
+	}
+
+	params := map[string]string{
+		"return_code":      pay.Success,
+		"result_code":      pay.Success,
+		"appid":            "appid",
+		"mch_id":           "mchid",
+		"sub_mch_id":       "sub_mchid",
+		"sub_appid":        "sub_appid",
+		"sub_openid":       "sub_openid",
+		"sub_is_subscribe": "Y",
+		"nonce_str":        "nonce",
+		"openid":           "openid",
+		"trade_type":       pay.TradeTypeJSAPI,
+		"total_fee":        "100",
+		"transaction_id":   "1004400740201409030005092168",
+		"out_trade_no":     "1409811653",
+		"time_end":         "20140903131540",
+	}
+	ret, err := ReadPartner(strings.NewReader(buildReturn(a, sp, params)), lookup)
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.SubMchID, "sub_mchid").
+		Equal(ret.SubAppID, "sub_appid").
+		Equal(ret.SubOpenID, "sub_openid").
+		Equal(ret.TotalFee, 100).
+		Equal(ret.End(), time.Date(2014, 9, 3, 13, 15, 40, 0, pay.Location))
+
+	// sub_appid 不匹配
+	params["sub_appid"] = "other"
+	_, err = ReadPartner(strings.NewReader(buildReturn(a, sp, params)), lookup)
+	a.Equal(err, pay.ErrInvalidSubAppid)
+
+	// 不存在的子商户
+	params["sub_mch_id"] = "other"
+	_, err = ReadPartner(strings.NewReader(buildReturn(a, sp, params)), lookup)
+	a.Error(err)
+
+	// 缺少 sub_mch_id
+	delete(params, "sub_mch_id")
+	_, err = ReadPartner(strings.NewReader(buildReturn(a, sp, params)), lookup)
+	a.Error(err)
+}
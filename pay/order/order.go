@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package order 订单的查询与关闭
+//
+//	p := pay.New(...)
+//	o := order.Order{
+//	    Pay: p,
+//	    SignType: pay.SignTypeMD5,
+//	}
+//
+//	ret, err := o.OutTradeNO(...)
+//	if ret.Paid() {
+//	    // TODO
+//	}
+package order
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// Order 订单查询与关闭的配置
+type Order struct {
+	Pay      *pay.Pay
+	SignType string // 签名类型
+}
+
+// Return 订单查询的返回值
+type Return struct {
+	DeviceInfo         string `xml:"device_info"`          // 设备号
+	OpenID             string `xml:"openid"`               // 用户标识
+	IsSubscribe        string `xml:"is_subscribe"`         // 是否关注公众账号，Y-关注，N-未关注
+	TradeType          string `xml:"trade_type"`           // 交易类型，JSAPI、NATIVE、APP、MICROPAY
+	TradeState         string `xml:"trade_state"`          // 交易状态，pay.TradeState* 系列常量
+	TradeStateDesc     string `xml:"trade_state_desc"`     // 交易状态描述
+	BankType           string `xml:"bank_type"`            // 付款银行
+	TotalFee           int    `xml:"total_fee"`            // 订单金额，单位为分
+	SettlementTotalFee int    `xml:"settlement_total_fee"` // 应结订单金额
+	FeeType            string `xml:"fee_type"`             // 货币种类
+	CashFee            int    `xml:"cash_fee"`             // 现金支付金额
+	CashFeeType        string `xml:"cash_fee_type"`        // 现金支付货币类型
+	CouponFee          int    `xml:"coupon_fee"`           // 总代金券金额
+	CouponCount        int    `xml:"coupon_count"`         // 代金券使用数量
+	TransactionID      string `xml:"transaction_id"`       // 微信支付订单号
+	OutTradeNO         string `xml:"out_trade_no"`         // 商户订单号
+	Attach             string `xml:"attach"`               // 附加数据
+	TimeEnd            string `xml:"time_end"`             // 支付完成时间，格式为yyyyMMddHHmmss
+
+	Coupons []*pay.Coupon
+	end     time.Time
+}
+
+// End 返回 TimeEnd 的 time.Time 格式数据
+//
+// 订单未支付时，返回零值。
+func (ret *Return) End() time.Time {
+	return ret.end
+}
+
+// Subscribed 当前用户是否已经关注公众账号
+func (ret *Return) Subscribed() bool {
+	return ret.IsSubscribe == "Y"
+}
+
+// Paid 订单是否已经支付成功
+func (ret *Return) Paid() bool {
+	return ret.TradeState == pay.TradeStateSuccess
+}
+
+// TransactionID 通过微信订单号查询订单
+func (o *Order) TransactionID(transactionID string) (*Return, error) {
+	return o.query(map[string]string{
+		"transaction_id": transactionID,
+		"sign_type":      o.SignType,
+	})
+}
+
+// OutTradeNO 通过商户订单号查询订单
+func (o *Order) OutTradeNO(outTradeNO string) (*Return, error) {
+	return o.query(map[string]string{
+		"out_trade_no": outTradeNO,
+		"sign_type":    o.SignType,
+	})
+}
+
+func (o *Order) query(params map[string]string) (*Return, error) {
+	maps, err := o.Pay.OrderQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = o.Pay.ValidateAll(o.SignType, maps); err != nil {
+		return nil, err
+	}
+
+	return newReturn(maps)
+}
+
+// Close 关闭订单
+func (o *Order) Close(outTradeNO string) error {
+	maps, err := o.Pay.CloseOrder(map[string]string{
+		"out_trade_no": outTradeNO,
+		"sign_type":    o.SignType,
+	})
+	if err != nil {
+		return err
+	}
+
+	return o.Pay.ValidateAll(o.SignType, maps)
+}
+
+func newReturn(params map[string]string) (*Return, error) {
+	ret := &Return{}
+	if err := xxml.Map2XMLObj(params, ret); err != nil {
+		return nil, err
+	}
+
+	coupons, err := pay.GetCoupons(params)
+	if err != nil {
+		return nil, err
+	}
+	if ret.CouponCount != len(coupons) {
+		return nil, fmt.Errorf("返回的代金券数量[%v]和实际的数量[%v]不相符", ret.CouponCount, len(coupons))
+	}
+	ret.Coupons = coupons
+
+	if ret.TimeEnd != "" {
+		if ret.end, err = time.ParseInLocation(pay.DateFormat, ret.TimeEnd, pay.Location); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+)
+
+func TestNewReturn(t *testing.T) {
+	a := assert.New(t, false)
+
+	params := map[string]string{
+		"openid":         "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o",
+		"is_subscribe":   "Y",
+		"trade_type":     pay.TradeTypeJSAPI,
+		"trade_state":    pay.TradeStateSuccess,
+		"total_fee":      "100",
+		"coupon_fee":     "20",
+		"coupon_count":   "2",
+		"coupon_id_0":    "1",
+		"coupon_type_0":  pay.CouponTypeCash,
+		"coupon_fee_0":   "10",
+		"coupon_id_1":    "2",
+		"coupon_type_1":  pay.CouponTypeNoCash,
+		"coupon_fee_1":   "10",
+		"transaction_id": "1008450740201411110005820873",
+		"out_trade_no":   "1415757673",
+		"time_end":       "20141111170043",
+	}
+
+	ret, err := newReturn(params)
+	a.NotError(err).NotNil(ret)
+	a.True(ret.Paid()).
+		True(ret.Subscribed()).
+		Equal(ret.TotalFee, 100).
+		Length(ret.Coupons, 2).
+		Equal(ret.Coupons[1].Type, pay.CouponTypeNoCash).
+		Equal(ret.End(), time.Date(2014, 11, 11, 17, 0, 43, 0, pay.Location))
+
+	// 未支付
+	params = map[string]string{
+		"trade_state":  pay.TradeStateNotPay,
+		"out_trade_no": "1415757673",
+	}
+	ret, err = newReturn(params)
+	a.NotError(err).NotNil(ret)
+	a.False(ret.Paid()).True(ret.End().IsZero())
+
+	// 代金券数量不相符
+	params["coupon_count"] = "1"
+	ret, err = newReturn(params)
+	a.Error(err).Nil(ret)
+}
@@ -6,6 +6,7 @@
 package pay
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/xml"
@@ -14,6 +15,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/issue9/errwrap"
 
@@ -25,6 +27,9 @@ var (
 	ErrInvalidAppid = errors.New("返回的 appid 与当前的不匹配")
 	ErrInvalidMchid = errors.New("返回的 mch_id 与当前的不匹配")
 	ErrInvalidSign  = errors.New("不存在签名或是签名无法验证")
+
+	ErrInvalidSubAppid = errors.New("返回的 sub_appid 与当前的不匹配")
+	ErrInvalidSubMchid = errors.New("返回的 sub_mch_id 与当前的不匹配")
 )
 
 // Pay 支付的基本配置
@@ -33,6 +38,13 @@ type Pay struct {
 	appID  string
 	apiKey string
 	client *http.Client
+
+	// 服务商模式下的子商户
+	subMchID string
+	subAppID string
+
+	baseURL string // 接口地址的前缀，为空表示 BaseURL
+	sandbox bool
 }
 
 // New 声明一个新的 *Pay 实例
@@ -65,6 +77,103 @@ func (p *Pay) APIKey() string {
 	return p.apiKey
 }
 
+// Sub 声明服务商模式下子商户的 *Pay 实例
+//
+// 当前实例表示服务商，返回的实例与当前实例共用 apikey、http.Client 等配置，
+// 在发送请求时会自动添加 sub_mch_id 和 sub_appid 参数，并在 [Pay.ValidateAll] 中验证。
+// subAppID 可以为空，表示不需要 sub_appid。
+func (p *Pay) Sub(subMchID, subAppID string) *Pay {
+	sub := *p
+	sub.subMchID = subMchID
+	sub.subAppID = subAppID
+	return &sub
+}
+
+// SubMchID 获取子商户号，非服务商模式下为空
+func (p *Pay) SubMchID() string {
+	return p.subMchID
+}
+
+// SubAppID 获取子商户的 appid
+func (p *Pay) SubAppID() string {
+	return p.subAppID
+}
+
+// BaseURL 获取接口地址的前缀
+func (p *Pay) BaseURL() string {
+	if p.baseURL == "" {
+		return BaseURL
+	}
+	return p.baseURL
+}
+
+// SetBaseURL 声明一个修改了接口地址前缀的 *Pay 实例
+//
+// 所有以 [BaseURL] 开头的接口地址，在发送请求时都会将该前缀替换成 base，
+// 一般用于将请求指向代理或是本地的模拟服务。base 为空表示恢复为 [BaseURL]。
+//
+// 与 [Pay.Sub] 相同，返回的是新实例，不会修改当前实例。
+func (p *Pay) SetBaseURL(base string) *Pay {
+	bp := *p
+	bp.baseURL = strings.TrimSuffix(base, "/")
+	return &bp
+}
+
+// Sandbox 声明一个仿真测试环境下的 *Pay 实例
+//
+// 会从微信获取仿真测试环境的签名密钥，并以此作为新实例的 apikey，
+// 新实例的所有请求都会被指向仿真测试环境的地址，其它配置与当前实例相同。
+func (p *Pay) Sandbox() (*Pay, error) {
+	return p.SandboxContext(context.Background())
+}
+
+// SandboxContext 带 context.Context 的 [Pay.Sandbox]
+func (p *Pay) SandboxContext(ctx context.Context) (*Pay, error) {
+	sp := *p
+	sp.sandbox = true
+
+	// 获取签名密钥的接口只需要 mch_id，且以正式的 apikey 签名。
+	m, err := sp.postContext(ctx, GetSignKeyURL, map[string]string{
+		"sign_type": SignTypeMD5,
+	}, []string{"appid"})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = sp.ValidateReturn(m); err != nil {
+		return nil, err
+	}
+
+	key := m["sandbox_signkey"]
+	if key == "" {
+		return nil, errors.New("未获取到 sandbox_signkey")
+	}
+	sp.apiKey = key
+
+	return &sp, nil
+}
+
+// IsSandbox 是否为仿真测试环境
+func (p *Pay) IsSandbox() bool {
+	return p.sandbox
+}
+
+// 获取 url 实际请求的地址
+//
+// 仿真测试环境下，会在路径之前加上 /sandboxnew，且去掉路径中的 /secapi。
+func (p *Pay) url(url string) string {
+	if !strings.HasPrefix(url, BaseURL) {
+		return url
+	}
+
+	path := url[len(BaseURL):]
+	if p.sandbox && !strings.HasPrefix(path, SandboxPath+"/") {
+		path = SandboxPath + strings.TrimPrefix(path, "/secapi")
+	}
+
+	return p.BaseURL() + path
+}
+
 // NewTLSPay 声明一个带证书的支付实例
 //
 // 如果想要用系统的根证书，则将 rootCAPath 置为空就行。
@@ -78,15 +187,51 @@ func NewTLSPay(mchid, appid, apikey, certPath, keyPath, rootCAPath string) (*Pay
 }
 
 // Post 发送请求，会优先使用 params 中的相关参数。
-// 比如：若已经指定了 appid，会不会使用 pay.AppID；
+// 比如：若已经指定了 appid，则不会使用 pay.AppID；
 // 若使用了 sign，则不会再计算 sign 值。
 func (p *Pay) Post(url string, params map[string]string) (map[string]string, error) {
-	r, err := p.map2XML(params)
+	return p.PostContext(context.Background(), url, params)
+}
+
+// PostContext 带 context.Context 的 [Pay.Post]
+func (p *Pay) PostContext(ctx context.Context, url string, params map[string]string) (map[string]string, error) {
+	return p.postContext(ctx, url, params, nil)
+}
+
+// PostOmit 与 [Pay.Post] 相同，但是不会自动添加 omit 中指定的字段
+//
+// 部分接口的字段名称并不是 appid 和 mch_id，比如企业付款的 mch_appid 和 mchid，
+// 或是根本不需要 appid，此时可以通过 omit 阻止自动添加这些字段。
+// omit 可以是 appid、mch_id、sub_appid 和 sub_mch_id。
+func (p *Pay) PostOmit(url string, params map[string]string, omit ...string) (map[string]string, error) {
+	return p.postContext(context.Background(), url, params, omit)
+}
+
+func (p *Pay) postContext(ctx context.Context, url string, params map[string]string, omit []string) (map[string]string, error) {
+	body, err := p.postRaw(ctx, url, params, omit)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return xxml.MapFromXMLReader(body)
+}
+
+// PostRaw 发送请求并返回未经处理的报文主体
+//
+// 参数的处理方式与 [Pay.Post] 相同，适用于返回内容并非 XML 的接口，比如下载对账单。
+// 调用者需要负责关闭返回的内容。
+func (p *Pay) PostRaw(url string, params map[string]string) (io.ReadCloser, error) {
+	return p.postRaw(context.Background(), url, params, nil)
+}
+
+func (p *Pay) postRaw(ctx context.Context, url string, params map[string]string, omit []string) (io.ReadCloser, error) {
+	r, err := p.map2XML(params, omit...)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, r)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url(url), r)
 	if err != nil {
 		return nil, err
 	}
@@ -97,11 +242,11 @@ func (p *Pay) Post(url string, params map[string]string) (map[string]string, err
 		return nil, err
 	}
 	if resp.StatusCode > 399 {
+		resp.Body.Close()
 		return nil, fmt.Errorf("微信服务端返回[%v]状态码", resp.StatusCode)
 	}
-	defer resp.Body.Close()
 
-	return xxml.MapFromXMLReader(resp.Body)
+	return resp.Body, nil
 }
 
 // UnifiedOrder 执行统一下单
@@ -130,6 +275,9 @@ func (p *Pay) RefundQuery(params map[string]string) (map[string]string, error) {
 }
 
 // DownloadBill 下载对账单
+//
+// Deprecated: 下载成功时返回的是文本而不是 XML，此方法无法正常工作，
+// 请使用 bill 包中的相关功能。
 func (p *Pay) DownloadBill(params map[string]string) (map[string]string, error) {
 	return p.Post(DownloadBillURL, params)
 }
@@ -185,6 +333,8 @@ func (p *Pay) ValidateSign(signType string, params map[string]string) error {
 }
 
 // ValidateAll 验证 ValidateSign 和 appid 及 mchid 是否匹配
+//
+// 服务商模式下，还会验证 sub_mch_id 和 sub_appid 是否匹配。
 func (p *Pay) ValidateAll(signType string, params map[string]string) error {
 	if err := p.ValidateSign(signType, params); err != nil {
 		return err
@@ -198,6 +348,14 @@ func (p *Pay) ValidateAll(signType string, params map[string]string) error {
 		return ErrInvalidAppid
 	}
 
+	if p.subMchID != "" && params["sub_mch_id"] != p.subMchID {
+		return ErrInvalidSubMchid
+	}
+
+	if p.subAppID != "" && params["sub_appid"] != p.subAppID {
+		return ErrInvalidSubAppid
+	}
+
 	return nil
 }
 
@@ -207,14 +365,24 @@ func (p *Pay) Sign(signType string, params map[string]string) (string, error) {
 }
 
 // 将 map 转换成 xml，并写入到 buf
-func (p *Pay) map2XML(params map[string]string) (io.Reader, error) {
-	if params["appid"] == "" {
-		params["appid"] = p.appID
-	}
-
-	if params["mch_id"] == "" {
-		params["mch_id"] = p.mchID
+//
+// omit 中的字段不会被自动添加。
+func (p *Pay) map2XML(params map[string]string, omit ...string) (io.Reader, error) {
+	fill := func(key, val string) {
+		if params[key] != "" || val == "" {
+			return
+		}
+		for _, k := range omit {
+			if k == key {
+				return
+			}
+		}
+		params[key] = val
 	}
+	fill("appid", p.appID)
+	fill("mch_id", p.mchID)
+	fill("sub_mch_id", p.subMchID)
+	fill("sub_appid", p.subAppID)
 
 	if params["nonce_str"] == "" {
 		params["nonce_str"] = NonceString()
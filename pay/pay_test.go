@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package pay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/internal/xxml"
+)
+
+func TestPay_map2XML(t *testing.T) {
+	a := assert.New(t, false)
+	p := New("mchid", "appid", "apikey", nil)
+
+	r, err := p.map2XML(map[string]string{"body": "body"})
+	a.NotError(err).NotNil(r)
+	params, err := xxml.MapFromXMLReader(r)
+	a.NotError(err)
+	a.Equal(params["appid"], "appid").
+		Equal(params["mch_id"], "mchid").
+		Equal(params["body"], "body").
+		NotEmpty(params["nonce_str"]).
+		NotEmpty(params["sign"])
+
+	// 指定为空值，依然会自动添加
+	r, err = p.map2XML(map[string]string{"appid": "", "mch_id": ""})
+	a.NotError(err).NotNil(r)
+	params, err = xxml.MapFromXMLReader(r)
+	a.NotError(err)
+	a.Equal(params["appid"], "appid").
+		Equal(params["mch_id"], "mchid")
+
+	// 已指定的值不会被覆盖
+	r, err = p.map2XML(map[string]string{"appid": "other"})
+	a.NotError(err).NotNil(r)
+	params, err = xxml.MapFromXMLReader(r)
+	a.NotError(err)
+	a.Equal(params["appid"], "other").
+		Equal(params["mch_id"], "mchid")
+
+	// 通过 omit 阻止自动添加
+	r, err = p.map2XML(map[string]string{"mchid": "mchid"}, "appid", "mch_id")
+	a.NotError(err).NotNil(r)
+	params, err = xxml.MapFromXMLReader(r)
+	a.NotError(err)
+	_, found := params["appid"]
+	a.False(found)
+	_, found = params["mch_id"]
+	a.False(found)
+	a.Equal(params["mchid"], "mchid")
+
+	sign, err := p.Sign("", params)
+	a.NotError(err).Equal(sign, params["sign"])
+}
+
+func TestPay_Sub(t *testing.T) {
+	a := assert.New(t, false)
+	sp := New("mchid", "appid", "apikey", nil)
+	p := sp.Sub("sub_mchid", "sub_appid")
+	a.Equal(p.MchID(), "mchid").
+		Equal(p.AppID(), "appid").
+		Equal(p.APIKey(), "apikey").
+		Equal(p.SubMchID(), "sub_mchid").
+		Equal(p.SubAppID(), "sub_appid").
+		Equal(p.client, sp.client).
+		Empty(sp.SubMchID())
+
+	r, err := p.map2XML(map[string]string{"body": "body"})
+	a.NotError(err).NotNil(r)
+	params, err := xxml.MapFromXMLReader(r)
+	a.NotError(err)
+	a.Equal(params["sub_mch_id"], "sub_mchid").
+		Equal(params["sub_appid"], "sub_appid")
+
+	params["return_code"] = Success
+	params["result_code"] = Success
+	params["sign"], err = p.Sign("", params)
+	a.NotError(err)
+	a.NotError(p.ValidateAll("", params))
+	a.NotError(sp.ValidateAll("", params))
+
+	params["sub_mch_id"] = "other"
+	params["sign"], err = p.Sign("", params)
+	a.NotError(err)
+	a.Equal(p.ValidateAll("", params), ErrInvalidSubMchid)
+
+	params["sub_mch_id"] = "sub_mchid"
+	params["sub_appid"] = "other"
+	params["sign"], err = p.Sign("", params)
+	a.NotError(err)
+	a.Equal(p.ValidateAll("", params), ErrInvalidSubAppid)
+
+	// 未指定 sub_appid
+	p = sp.Sub("sub_mchid", "")
+	r, err = p.map2XML(map[string]string{"body": "body"})
+	a.NotError(err).NotNil(r)
+	params, err = xxml.MapFromXMLReader(r)
+	a.NotError(err)
+	_, found := params["sub_appid"]
+	a.False(found)
+}
+
+func TestPay_url(t *testing.T) {
+	a := assert.New(t, false)
+	p := New("mchid", "appid", "apikey", nil)
+	a.Equal(p.BaseURL(), BaseURL).
+		Equal(p.url(RefundURL), RefundURL).
+		Equal(p.url(GetPublicKeyURL), GetPublicKeyURL)
+
+	p = p.SetBaseURL("http://localhost:8080/")
+	a.Equal(p.BaseURL(), "http://localhost:8080").
+		Equal(p.url(UnifiedOrderURL), "http://localhost:8080/pay/unifiedorder").
+		Equal(p.url(GetPublicKeyURL), GetPublicKeyURL)
+
+	p.sandbox = true
+	a.Equal(p.url(UnifiedOrderURL), "http://localhost:8080/sandboxnew/pay/unifiedorder").
+		Equal(p.url(RefundURL), "http://localhost:8080/sandboxnew/pay/refund").
+		Equal(p.url(GetSignKeyURL), "http://localhost:8080/sandboxnew/pay/getsignkey")
+
+	p2 := p.SetBaseURL("")
+	a.Equal(p.BaseURL(), "http://localhost:8080") // 不会修改原实例
+	a.Equal(p2.BaseURL(), BaseURL).
+		Equal(p2.url(OrderQueryURL), "https://api.mch.weixin.qq.com/sandboxnew/pay/orderquery")
+}
+
+func TestPay_Sandbox(t *testing.T) {
+	a := assert.New(t, false)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, err := xxml.MapFromXMLReader(r.Body)
+		a.NotError(err)
+
+		key := "sandbox_key"
+		if r.URL.Path == "/sandboxnew/pay/getsignkey" {
+			key = "apikey"
+		}
+		sign, err := Sign(key, params["sign_type"], params)
+		a.NotError(err).Equal(sign, params["sign"])
+
+		switch r.URL.Path {
+		case "/sandboxnew/pay/getsignkey":
+			_, found := params["appid"]
+			a.False(found).Equal(params["mch_id"], "mchid")
+			w.Write([]byte("<xml><return_code>SUCCESS</return_code><sandbox_signkey>sandbox_key</sandbox_signkey></xml>"))
+		case "/sandboxnew/pay/refund":
+			w.Write([]byte("<xml><return_code>SUCCESS</return_code><refund_id>1</refund_id></xml>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := New("mchid", "appid", "apikey", nil).SetBaseURL(srv.URL)
+	sp, err := p.Sandbox()
+	a.NotError(err).NotNil(sp)
+	a.True(sp.IsSandbox()).
+		False(p.IsSandbox()).
+		Equal(sp.APIKey(), "sandbox_key").
+		Equal(p.APIKey(), "apikey")
+
+	m, err := sp.Refund(map[string]string{"out_refund_no": "1"})
+	a.NotError(err).Equal(m["refund_id"], "1")
+
+	// 子商户继承仿真测试环境
+	a.True(sp.Sub("sub_mchid", "").IsSandbox())
+}
@@ -0,0 +1,22 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package profitsharing
+
+import (
+	"log"
+
+	"github.com/issue9/wechat/pay/apiv3"
+)
+
+// NewNotifier 声明处理分账动账通知的 [apiv3.Notifier]
+//
+// 分账动账通知采用的是 APIv3 的格式，key 为 APIv3 密钥；
+// v 用于验证通知的签名，一般为 [apiv3.CertificateManager]；
+// f 为分账动账通知的处理函数。
+func NewNotifier(key string, v apiv3.Verifier, errlog *log.Logger, f func(*apiv3.Notification, *apiv3.ProfitSharingNotification) error) *apiv3.Notifier {
+	n := apiv3.NewNotifier(key, v, errlog)
+	n.OnProfitSharing(f)
+	return n
+}
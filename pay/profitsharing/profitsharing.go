@@ -0,0 +1,360 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package profitsharing 分账
+//
+// 分账接口固定采用 HMAC-SHA256 签名，其中请求分账、完结分账和分账回退需要使用证书，
+// 即 Pay 需要由 [pay.NewTLSPay] 创建。
+//
+//	p, err := pay.NewTLSPay(...)
+//	ps := profitsharing.ProfitSharing{Pay: p}
+//
+//	err = ps.AddReceiver(&profitsharing.Receiver{
+//	    Type: profitsharing.ReceiverTypeMerchant,
+//	    Account: "190001001",
+//	    Name: "示例商户全称",
+//	    RelationType: profitsharing.RelationStore,
+//	})
+//
+//	order, err := ps.Share("4208450740201411110007820472", "P20150806125346", []*profitsharing.Sharing{
+//	    {Type: profitsharing.ReceiverTypeMerchant, Account: "190001001", Amount: 100, Description: "分到商户"},
+//	})
+package profitsharing
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// 分账接收方的类型
+const (
+	ReceiverTypeMerchant  = "MERCHANT_ID"         // 商户 ID
+	ReceiverTypeOpenID    = "PERSONAL_OPENID"     // 个人 openid
+	ReceiverTypeSubOpenID = "PERSONAL_SUB_OPENID" // 个人 sub_openid
+)
+
+// ReturnAccountTypeMerchant 分账回退的回退方类型，目前仅支持商户 ID
+const ReturnAccountTypeMerchant = "MERCHANT_ID"
+
+// 分账接口固定采用的签名类型
+const signType = pay.SignTypeHmacSha256
+
+// 与分账接收方的关系类型
+const (
+	RelationServiceProvider = "SERVICE_PROVIDER" // 服务商
+	RelationStore           = "STORE"            // 门店
+	RelationStaff           = "STAFF"            // 员工
+	RelationStoreOwner      = "STORE_OWNER"      // 店主
+	RelationPartner         = "PARTNER"          // 合作伙伴
+	RelationHeadquarter     = "HEADQUARTER"      // 总部
+	RelationBrand           = "BRAND"            // 品牌方
+	RelationDistributor     = "DISTRIBUTOR"      // 分销商
+	RelationUser            = "USER"             // 用户
+	RelationSupplier        = "SUPPLIER"         // 供应商
+	RelationCustom          = "CUSTOM"           // 自定义
+)
+
+// 分账单的状态
+const (
+	StatusAccepted   = "ACCEPTED"   // 受理成功
+	StatusProcessing = "PROCESSING" // 处理中
+	StatusFinished   = "FINISHED"   // 处理完成
+	StatusClosed     = "CLOSED"     // 处理失败，已关单
+)
+
+// 分账接收方的分账结果以及分账回退的结果
+const (
+	ResultPending    = "PENDING"    // 待分账
+	ResultProcessing = "PROCESSING" // 处理中，仅分账回退
+	ResultSuccess    = "SUCCESS"    // 成功
+	ResultClosed     = "CLOSED"     // 已关闭
+	ResultFailed     = "FAILED"     // 失败，仅分账回退
+)
+
+// ProfitSharing 分账的配置
+type ProfitSharing struct {
+	Pay *pay.Pay
+}
+
+// Receiver 分账接收方
+type Receiver struct {
+	Type           string `json:"type"`                      // 接收方类型，ReceiverType* 系列常量
+	Account        string `json:"account"`                   // 接收方账号
+	Name           string `json:"name,omitempty"`            // 接收方名称
+	RelationType   string `json:"relation_type,omitempty"`   // 与分账方的关系类型，Relation* 系列常量
+	CustomRelation string `json:"custom_relation,omitempty"` // 自定义的分账关系
+}
+
+// Sharing 分账数据
+type Sharing struct {
+	Type        string `json:"type"`           // 接收方类型，ReceiverType* 系列常量
+	Account     string `json:"account"`        // 接收方账号
+	Amount      int    `json:"amount"`         // 分账金额，单位为分
+	Description string `json:"description"`    // 分账描述
+	Name        string `json:"name,omitempty"` // 接收方名称
+}
+
+// SharingResult 分账接收方的分账结果
+type SharingResult struct {
+	Sharing
+	Result     string `json:"result"`      // 分账结果，Result* 系列常量
+	FailReason string `json:"fail_reason"` // 分账失败原因
+	FinishTime string `json:"finish_time"` // 分账完成时间，格式为yyyyMMddHHmmss
+
+	finished time.Time
+}
+
+// Order 分账单
+type Order struct {
+	TransactionID string `xml:"transaction_id"` // 微信订单号
+	OutOrderNO    string `xml:"out_order_no"`   // 商户分账单号
+	OrderID       string `xml:"order_id"`       // 微信分账单号
+	Status        string `xml:"status"`         // 分账单状态，Status* 系列常量，仅查询时返回
+	CloseReason   string `xml:"close_reason"`   // 关单原因，仅查询时返回
+	Description   string `xml:"description"`    // 分账完结的原因，仅完结分账时返回
+
+	Receivers []*SharingResult // 分账接收方列表，仅查询时返回
+}
+
+// ReturnOrder 分账回退的数据
+//
+// OrderID 和 OutOrderNO 二选一。
+type ReturnOrder struct {
+	OrderID           string // 微信分账单号
+	OutOrderNO        string // 商户分账单号
+	OutReturnNO       string // 商户回退单号
+	ReturnAccountType string // 回退方类型，默认为 ReturnAccountTypeMerchant
+	ReturnAccount     string // 回退方账号
+	ReturnAmount      int    // 回退金额
+	Description       string // 回退描述
+}
+
+// ReturnResult 分账回退的结果
+type ReturnResult struct {
+	OrderID           string `xml:"order_id"`            // 微信分账单号
+	OutOrderNO        string `xml:"out_order_no"`        // 商户分账单号
+	OutReturnNO       string `xml:"out_return_no"`       // 商户回退单号
+	ReturnNO          string `xml:"return_no"`           // 微信回退单号
+	ReturnAccountType string `xml:"return_account_type"` // 回退方类型
+	ReturnAccount     string `xml:"return_account"`      // 回退方账号
+	ReturnAmount      int    `xml:"return_amount"`       // 回退金额
+	Description       string `xml:"description"`         // 回退描述
+	Result            string `xml:"result"`              // 回退结果，Result* 系列常量
+	FailReason        string `xml:"fail_reason"`         // 失败原因
+	FinishTime        string `xml:"finish_time"`         // 完成时间，格式为yyyyMMddHHmmss
+
+	finished time.Time
+}
+
+// Finished 返回 FinishTime 的 time.Time 格式数据
+//
+// 尚未完成时，返回零值。
+func (r *SharingResult) Finished() time.Time {
+	return r.finished
+}
+
+// Finished 返回 FinishTime 的 time.Time 格式数据
+//
+// 尚未完成时，返回零值。
+func (r *ReturnResult) Finished() time.Time {
+	return r.finished
+}
+
+// AddReceiver 添加分账接收方
+func (ps *ProfitSharing) AddReceiver(r *Receiver) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = ps.post(pay.ProfitSharingAddReceiverURL, map[string]string{
+		"receiver": string(data),
+	})
+	return err
+}
+
+// RemoveReceiver 删除分账接收方
+func (ps *ProfitSharing) RemoveReceiver(typ, account string) error {
+	data, err := json.Marshal(&Receiver{Type: typ, Account: account})
+	if err != nil {
+		return err
+	}
+
+	_, err = ps.post(pay.ProfitSharingRemoveReceiverURL, map[string]string{
+		"receiver": string(data),
+	})
+	return err
+}
+
+// Share 请求单次分账
+//
+// 单次分账请求之后，订单剩余的待分账金额会自动解冻给商户。
+func (ps *ProfitSharing) Share(transactionID, outOrderNO string, receivers []*Sharing) (*Order, error) {
+	return ps.share(pay.ProfitSharingURL, transactionID, outOrderNO, receivers)
+}
+
+// MultiShare 请求多次分账
+//
+// 多次分账之后，需要调用 [ProfitSharing.Finish] 完结分账。
+func (ps *ProfitSharing) MultiShare(transactionID, outOrderNO string, receivers []*Sharing) (*Order, error) {
+	return ps.share(pay.MultiProfitSharingURL, transactionID, outOrderNO, receivers)
+}
+
+func (ps *ProfitSharing) share(url, transactionID, outOrderNO string, receivers []*Sharing) (*Order, error) {
+	data, err := json.Marshal(receivers)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := ps.post(url, map[string]string{
+		"transaction_id": transactionID,
+		"out_order_no":   outOrderNO,
+		"receivers":      string(data),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newOrder(params)
+}
+
+// Query 查询分账结果
+func (ps *ProfitSharing) Query(transactionID, outOrderNO string) (*Order, error) {
+	params, err := ps.post(pay.ProfitSharingQueryURL, map[string]string{
+		"transaction_id": transactionID,
+		"out_order_no":   outOrderNO,
+	}, "appid") // 该接口不需要 appid
+	if err != nil {
+		return nil, err
+	}
+
+	return newOrder(params)
+}
+
+// Finish 完结分账
+//
+// 将订单剩余的待分账金额解冻给商户。
+func (ps *ProfitSharing) Finish(transactionID, outOrderNO, description string) (*Order, error) {
+	params, err := ps.post(pay.ProfitSharingFinishURL, map[string]string{
+		"transaction_id": transactionID,
+		"out_order_no":   outOrderNO,
+		"description":    description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newOrder(params)
+}
+
+// Return 分账回退
+func (ps *ProfitSharing) Return(r *ReturnOrder) (*ReturnResult, error) {
+	typ := r.ReturnAccountType
+	if typ == "" {
+		typ = ReturnAccountTypeMerchant
+	}
+
+	params, err := ps.post(pay.ProfitSharingReturnURL, map[string]string{
+		"order_id":            r.OrderID,
+		"out_order_no":        r.OutOrderNO,
+		"out_return_no":       r.OutReturnNO,
+		"return_account_type": typ,
+		"return_account":      r.ReturnAccount,
+		"return_amount":       strconv.Itoa(r.ReturnAmount),
+		"description":         r.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newReturnResult(params)
+}
+
+// QueryReturn 查询分账回退的结果
+//
+// orderID 和 outOrderNO 二选一。
+func (ps *ProfitSharing) QueryReturn(orderID, outOrderNO, outReturnNO string) (*ReturnResult, error) {
+	params, err := ps.post(pay.ProfitSharingReturnQueryURL, map[string]string{
+		"order_id":      orderID,
+		"out_order_no":  outOrderNO,
+		"out_return_no": outReturnNO,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newReturnResult(params)
+}
+
+// 发送请求并验证返回的结果
+//
+// 部分接口的返回内容中并不包含 appid，所以仅验证签名和 mch_id。
+// omit 为不需要自动添加的字段，参考 [pay.Pay.PostOmit]。
+func (ps *ProfitSharing) post(url string, params map[string]string, omit ...string) (map[string]string, error) {
+	params["sign_type"] = signType
+
+	ret, err := ps.Pay.PostOmit(url, params, omit...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ps.Pay.ValidateSign(signType, ret); err != nil {
+		return nil, err
+	}
+
+	if ret["mch_id"] != ps.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+
+	return ret, nil
+}
+
+func newOrder(params map[string]string) (*Order, error) {
+	o := &Order{}
+	if err := xxml.Map2XMLObj(params, o); err != nil {
+		return nil, err
+	}
+
+	if receivers := params["receivers"]; receivers != "" {
+		if err := json.Unmarshal([]byte(receivers), &o.Receivers); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range o.Receivers {
+		finished, err := parseTime(r.FinishTime)
+		if err != nil {
+			return nil, err
+		}
+		r.finished = finished
+	}
+
+	return o, nil
+}
+
+func newReturnResult(params map[string]string) (*ReturnResult, error) {
+	r := &ReturnResult{}
+	if err := xxml.Map2XMLObj(params, r); err != nil {
+		return nil, err
+	}
+
+	finished, err := parseTime(r.FinishTime)
+	if err != nil {
+		return nil, err
+	}
+	r.finished = finished
+
+	return r, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(pay.DateFormat, v, pay.Location)
+}
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package profitsharing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// 声明一个由 h 处理所有请求的 ProfitSharing 实例
+//
+// h 返回的内容会被添加 return_code、result_code、mch_id 以及签名之后返回给客户端。
+func newTestProfitSharing(a *assert.Assertion, h func(url string, params map[string]string) map[string]string) *ProfitSharing {
+	client := &http.Client{
+		Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			params, err := xxml.MapFromXMLReader(r.Body)
+			a.NotError(err)
+
+			a.Equal(params["sign_type"], pay.SignTypeHmacSha256)
+			sign, err := pay.Sign("apikey", pay.SignTypeHmacSha256, params)
+			a.NotError(err).Equal(sign, params["sign"])
+
+			ret := h(r.URL.String(), params)
+			ret["return_code"] = pay.Success
+			ret["result_code"] = pay.Success
+			ret["mch_id"] = "mchid"
+			ret["sign"], err = pay.Sign("apikey", pay.SignTypeHmacSha256, ret)
+			a.NotError(err)
+
+			w := httptest.NewRecorder()
+			w.WriteString("<xml>")
+			for k, v := range ret {
+				w.WriteString("<" + k + ">")
+				a.NotError(xml.EscapeText(w, []byte(v)))
+				w.WriteString("</" + k + ">")
+			}
+			w.WriteString("</xml>")
+			return w.Result(), nil
+		}),
+	}
+
+	return &ProfitSharing{Pay: pay.New("mchid", "appid", "apikey", client)}
+}
+
+func TestProfitSharing_Receiver(t *testing.T) {
+	a := assert.New(t, false)
+
+	ps := newTestProfitSharing(a, func(url string, params map[string]string) map[string]string {
+		r := &Receiver{}
+		a.NotError(json.Unmarshal([]byte(params["receiver"]), r))
+		a.Equal(r.Type, ReceiverTypeMerchant).Equal(r.Account, "190001001")
+
+		switch url {
+		case pay.ProfitSharingAddReceiverURL:
+			a.Equal(r.RelationType, RelationStore)
+		case pay.ProfitSharingRemoveReceiverURL:
+			a.Empty(r.RelationType)
+		default:
+			a.TB().Fatal("无效的地址", url)
+		}
+		return map[string]string{"receiver": params["receiver"]}
+	})
+
+	a.NotError(ps.AddReceiver(&Receiver{
+		Type:         ReceiverTypeMerchant,
+		Account:      "190001001",
+		Name:         "示例商户全称",
+		RelationType: RelationStore,
+	}))
+	a.NotError(ps.RemoveReceiver(ReceiverTypeMerchant, "190001001"))
+}
+
+func TestProfitSharing_Share(t *testing.T) {
+	a := assert.New(t, false)
+
+	ps := newTestProfitSharing(a, func(url string, params map[string]string) map[string]string {
+		switch url {
+		case pay.ProfitSharingURL, pay.MultiProfitSharingURL:
+			receivers := []*Sharing{}
+			a.NotError(json.Unmarshal([]byte(params["receivers"]), &receivers))
+			a.Length(receivers, 1).Equal(receivers[0].Amount, 100)
+			return map[string]string{
+				"transaction_id": params["transaction_id"],
+				"out_order_no":   params["out_order_no"],
+				"order_id":       "3008450740201411110007820472",
+			}
+		case pay.ProfitSharingQueryURL:
+			_, found := params["appid"]
+			a.False(found)
+			return map[string]string{
+				"transaction_id": params["transaction_id"],
+				"out_order_no":   params["out_order_no"],
+				"order_id":       "3008450740201411110007820472",
+				"status":         StatusFinished,
+				"receivers":      `[{"type":"MERCHANT_ID","account":"190001001","amount":100,"description":"分到商户","result":"SUCCESS","finish_time":"20180608170132"}]`,
+			}
+		case pay.ProfitSharingFinishURL:
+			a.Equal(params["description"], "分账已完成")
+			return map[string]string{
+				"transaction_id": params["transaction_id"],
+				"out_order_no":   params["out_order_no"],
+				"order_id":       "3008450740201411110007820472",
+			}
+		default:
+			a.TB().Fatal("无效的地址", url)
+			return nil
+		}
+	})
+
+	receivers := []*Sharing{{Type: ReceiverTypeMerchant, Account: "190001001", Amount: 100, Description: "分到商户"}}
+
+	o, err := ps.Share("4208450740201411110007820472", "P20150806125346", receivers)
+	a.NotError(err).NotNil(o)
+	a.Equal(o.OrderID, "3008450740201411110007820472").Equal(o.OutOrderNO, "P20150806125346")
+
+	o, err = ps.MultiShare("4208450740201411110007820472", "P20150806125346", receivers)
+	a.NotError(err).NotNil(o)
+
+	o, err = ps.Query("4208450740201411110007820472", "P20150806125346")
+	a.NotError(err).NotNil(o)
+	a.Equal(o.Status, StatusFinished).Length(o.Receivers, 1)
+	a.Equal(o.Receivers[0].Result, ResultSuccess).
+		Equal(o.Receivers[0].Amount, 100).
+		Equal(o.Receivers[0].Finished(), time.Date(2018, 6, 8, 17, 1, 32, 0, pay.Location))
+
+	o, err = ps.Finish("4208450740201411110007820472", "P20150806125346", "分账已完成")
+	a.NotError(err).NotNil(o)
+}
+
+func TestProfitSharing_Return(t *testing.T) {
+	a := assert.New(t, false)
+
+	ps := newTestProfitSharing(a, func(url string, params map[string]string) map[string]string {
+		a.Equal(params["out_return_no"], "R20190516001")
+		ret := map[string]string{
+			"out_order_no":        "P20150806125346",
+			"out_return_no":       "R20190516001",
+			"return_no":           "3008450740201411110007820472",
+			"return_account_type": ReturnAccountTypeMerchant,
+			"return_account":      "86693852",
+			"return_amount":       "888",
+			"result":              ResultSuccess,
+			"finish_time":         "20180608170132",
+		}
+
+		switch url {
+		case pay.ProfitSharingReturnURL:
+			a.Equal(params["return_account_type"], ReturnAccountTypeMerchant).
+				Equal(params["return_amount"], "888")
+		case pay.ProfitSharingReturnQueryURL:
+			ret["result"] = ResultProcessing
+			delete(ret, "finish_time")
+		default:
+			a.TB().Fatal("无效的地址", url)
+		}
+		return ret
+	})
+
+	r, err := ps.Return(&ReturnOrder{
+		OutOrderNO:    "P20150806125346",
+		OutReturnNO:   "R20190516001",
+		ReturnAccount: "86693852",
+		ReturnAmount:  888,
+		Description:   "用户退款",
+	})
+	a.NotError(err).NotNil(r)
+	a.Equal(r.Result, ResultSuccess).
+		Equal(r.ReturnAmount, 888).
+		Equal(r.Finished(), time.Date(2018, 6, 8, 17, 1, 32, 0, pay.Location))
+
+	r, err = ps.QueryReturn("", "P20150806125346", "R20190516001")
+	a.NotError(err).NotNil(r)
+	a.Equal(r.Result, ResultProcessing).True(r.Finished().IsZero())
+}
@@ -0,0 +1,287 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package redpack 现金红包
+//
+// 红包接口需要使用证书，即 Pay 需要由 [pay.NewTLSPay] 创建。
+//
+//	p, err := pay.NewTLSPay(...)
+//	r := redpack.Redpack{
+//	    Pay: p,
+//	    ClientIP: "127.0.0.1",
+//	}
+//
+//	ret, err := r.Send(&redpack.Pack{
+//	    MchBillNO: "10000098201411111234567890",
+//	    SendName: "商户名称",
+//	    ReOpenID: "oxTWIuGaIt6gTKsQRLau2M0yL16E",
+//	    TotalAmount: 100,
+//	    Wishing: "感谢您参加活动",
+//	    ActName: "活动",
+//	    Remark: "备注",
+//	})
+package redpack
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// 场景 ID
+const (
+	ScenePromotion      = "PRODUCT_1" // 商品促销
+	SceneLottery        = "PRODUCT_2" // 抽奖
+	SceneVirtualPrize   = "PRODUCT_3" // 虚拟物品兑奖
+	SceneEnterprise     = "PRODUCT_4" // 企业内部福利
+	SceneChannel        = "PRODUCT_5" // 渠道分润
+	SceneInsurance      = "PRODUCT_6" // 保险回馈
+	SceneLotteryCaiPiao = "PRODUCT_7" // 彩票派奖
+	SceneTax            = "PRODUCT_8" // 税务刮奖
+)
+
+// 红包状态
+const (
+	StatusSending  = "SENDING"   // 发放中
+	StatusSent     = "SENT"      // 已发放待领取
+	StatusFailed   = "FAILED"    // 发放失败
+	StatusReceived = "RECEIVED"  // 已领取
+	StatusRefuning = "RFUND_ING" // 退款中
+	StatusRefund   = "REFUND"    // 已退款
+)
+
+// 金额的限制，单位为分
+const (
+	minAmount      = 100    // 未指定场景时，每个红包的最小金额
+	maxAmount      = 20000  // 未指定场景时，每个红包的最大金额
+	minSceneAmount = 30     // 指定场景时，每个红包的最小金额
+	maxSceneAmount = 499900 // 指定场景时，每个红包的最大金额
+
+	minGroupNum = 3
+	maxGroupNum = 20
+)
+
+// 返回内容中的时间格式
+const timeFormat = "2006-01-02 15:04:05"
+
+// 预定义的错误类型
+var (
+	ErrInvalidAmount   = errors.New("红包金额超出范围，小于 1 元或是大于 200 元时需要指定 SceneID")
+	ErrInvalidTotalNum = errors.New("红包发放人数超出范围")
+)
+
+// Redpack 现金红包的配置
+type Redpack struct {
+	Pay      *pay.Pay
+	ClientIP string // 调用接口的机器 IP，仅普通红包需要
+}
+
+// Pack 红包数据
+type Pack struct {
+	MchBillNO   string // 商户订单号
+	SendName    string // 商户名称
+	ReOpenID    string // 用户 openid，裂变红包则为种子用户
+	TotalAmount int    // 付款金额，单位为分，裂变红包为总金额
+	TotalNum    int    // 红包发放总人数，普通红包固定为 1，裂变红包为 3-20
+	Wishing     string // 红包祝福语
+	ActName     string // 活动名称
+	Remark      string // 备注
+	SceneID     string // 场景 ID，Scene* 系列常量
+	RiskInfo    string // 活动信息
+}
+
+// Return 发放红包的返回值
+type Return struct {
+	MchBillNO   string `xml:"mch_billno"`   // 商户订单号
+	ReOpenID    string `xml:"re_openid"`    // 用户 openid
+	TotalAmount int    `xml:"total_amount"` // 付款金额
+	SendListID  string `xml:"send_listid"`  // 微信单号
+}
+
+// Info 红包的查询结果
+type Info struct {
+	MchBillNO    string      `xml:"mch_billno"`    // 商户订单号
+	DetailID     string      `xml:"detail_id"`     // 红包单号
+	Status       string      `xml:"status"`        // 红包状态，Status* 系列常量
+	SendType     string      `xml:"send_type"`     // 发放类型，API、UPLOAD、ACTIVITY
+	HBType       string      `xml:"hb_type"`       // 红包类型，GROUP 裂变红包，NORMAL 普通红包
+	TotalNum     int         `xml:"total_num"`     // 红包个数
+	TotalAmount  int         `xml:"total_amount"`  // 红包总金额
+	Reason       string      `xml:"reason"`        // 失败原因
+	SendTime     string      `xml:"send_time"`     // 红包发送时间
+	RefundTime   string      `xml:"refund_time"`   // 红包退款时间
+	RefundAmount int         `xml:"refund_amount"` // 红包退款金额
+	Wishing      string      `xml:"wishing"`       // 祝福语
+	Remark       string      `xml:"remark"`        // 活动描述
+	ActName      string      `xml:"act_name"`      // 活动名称
+	Receivers    []*Receiver `xml:"hblist>hbinfo"` // 领取红包的用户列表
+
+	sent time.Time
+}
+
+// Receiver 领取红包的用户
+type Receiver struct {
+	OpenID  string `xml:"openid"`   // 领取红包的 openid
+	Amount  int    `xml:"amount"`   // 领取金额
+	RcvTime string `xml:"rcv_time"` // 领取红包的时间
+
+	received time.Time
+}
+
+// Sent 返回 SendTime 的 time.Time 格式数据
+func (info *Info) Sent() time.Time {
+	return info.sent
+}
+
+// Received 返回 RcvTime 的 time.Time 格式数据
+func (r *Receiver) Received() time.Time {
+	return r.received
+}
+
+// Send 发放普通红包
+//
+// TotalNum 会被忽略，固定为 1。
+func (r *Redpack) Send(p *Pack) (*Return, error) {
+	if err := checkAmount(p.TotalAmount, p.SceneID); err != nil {
+		return nil, err
+	}
+
+	params := r.params(p, 1)
+	params["client_ip"] = r.ClientIP
+	return r.send(pay.SendRedpackURL, params)
+}
+
+// SendGroup 发放裂变红包
+//
+// 红包金额随机分配给 TotalNum 个用户，每个用户的平均金额同样需要满足金额的限制。
+func (r *Redpack) SendGroup(p *Pack) (*Return, error) {
+	if p.TotalNum < minGroupNum || p.TotalNum > maxGroupNum {
+		return nil, ErrInvalidTotalNum
+	}
+
+	if err := checkAmount(p.TotalAmount/p.TotalNum, p.SceneID); err != nil {
+		return nil, err
+	}
+
+	params := r.params(p, p.TotalNum)
+	params["amt_type"] = "ALL_RAND"
+	return r.send(pay.SendGroupRedpackURL, params)
+}
+
+func (r *Redpack) params(p *Pack, num int) map[string]string {
+	return map[string]string{
+		"wxappid":      r.Pay.AppID(),
+		"mch_billno":   p.MchBillNO,
+		"send_name":    p.SendName,
+		"re_openid":    p.ReOpenID,
+		"total_amount": strconv.Itoa(p.TotalAmount),
+		"total_num":    strconv.Itoa(num),
+		"wishing":      p.Wishing,
+		"act_name":     p.ActName,
+		"remark":       p.Remark,
+		"scene_id":     p.SceneID,
+		"risk_info":    p.RiskInfo,
+	}
+}
+
+func (r *Redpack) send(url string, params map[string]string) (*Return, error) {
+	ret, err := r.Pay.PostOmit(url, params, "appid") // 以 wxappid 代替
+	if err != nil {
+		return nil, err
+	}
+
+	// 红包接口的返回内容并不包含签名
+	if err = r.Pay.ValidateResult(ret); err != nil {
+		return nil, err
+	}
+
+	if ret["mch_id"] != r.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+	if ret["wxappid"] != r.Pay.AppID() {
+		return nil, pay.ErrInvalidAppid
+	}
+
+	obj := &Return{}
+	if err = xxml.Map2XMLObj(ret, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Query 查询红包记录
+func (r *Redpack) Query(mchBillNO string) (*Info, error) {
+	body, err := r.Pay.PostRaw(pay.GetHBInfoURL, map[string]string{
+		"mch_billno": mchBillNO,
+		"bill_type":  "MCHT",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// 返回内容包含嵌套的 hblist，无法使用 xxml.MapFromXMLReader 解析。
+	resp := &struct {
+		ReturnCode string `xml:"return_code"`
+		ReturnMsg  string `xml:"return_msg"`
+		ResultCode string `xml:"result_code"`
+		ErrCode    string `xml:"err_code"`
+		ErrCodeDes string `xml:"err_code_des"`
+		MchID      string `xml:"mch_id"`
+		Info
+	}{}
+	if err = xml.NewDecoder(body).Decode(resp); err != nil {
+		return nil, err
+	}
+
+	if err = r.Pay.ValidateResult(map[string]string{
+		"return_code":  resp.ReturnCode,
+		"return_msg":   resp.ReturnMsg,
+		"result_code":  resp.ResultCode,
+		"err_code":     resp.ErrCode,
+		"err_code_des": resp.ErrCodeDes,
+	}); err != nil {
+		return nil, err
+	}
+
+	if resp.MchID != r.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+
+	info := &resp.Info
+	if info.sent, err = parseTime(info.SendTime); err != nil {
+		return nil, err
+	}
+	for _, item := range info.Receivers {
+		if item.received, err = parseTime(item.RcvTime); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// 检测单个红包的金额是否符合要求
+func checkAmount(amount int, sceneID string) error {
+	lower, upper := minAmount, maxAmount
+	if sceneID != "" {
+		lower, upper = minSceneAmount, maxSceneAmount
+	}
+
+	if amount < lower || amount > upper {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(timeFormat, v, pay.Location)
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package redpack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func newTestRedpack(a *assert.Assertion, h func(url string, params map[string]string) string) *Redpack {
+	client := &http.Client{
+		Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			params, err := xxml.MapFromXMLReader(r.Body)
+			a.NotError(err)
+
+			sign, err := pay.Sign("apikey", params["sign_type"], params)
+			a.NotError(err).Equal(sign, params["sign"])
+
+			w := httptest.NewRecorder()
+			w.WriteString(h(r.URL.String(), params))
+			return w.Result(), nil
+		}),
+	}
+
+	return &Redpack{
+		Pay:      pay.New("mchid", "appid", "apikey", client),
+		ClientIP: "127.0.0.1",
+	}
+}
+
+const sendReturn = `<xml>
+<return_code><![CDATA[SUCCESS]]></return_code>
+<result_code><![CDATA[SUCCESS]]></result_code>
+<mch_billno><![CDATA[0010010404201411170000046545]]></mch_billno>
+<mch_id><![CDATA[mchid]]></mch_id>
+<wxappid><![CDATA[appid]]></wxappid>
+<re_openid><![CDATA[onqOjjmM1tad-3ROpncN-yUfa6uI]]></re_openid>
+<total_amount>100</total_amount>
+<send_listid><![CDATA[100000000020150520314766074200]]></send_listid>
+</xml>`
+
+func TestRedpack_Send(t *testing.T) {
+	a := assert.New(t, false)
+
+	r := newTestRedpack(a, func(url string, params map[string]string) string {
+		_, found := params["appid"]
+		a.False(found)
+		a.Equal(params["wxappid"], "appid").Equal(params["mch_id"], "mchid")
+
+		switch url {
+		case pay.SendRedpackURL:
+			a.Equal(params["total_num"], "1").Equal(params["client_ip"], "127.0.0.1")
+		case pay.SendGroupRedpackURL:
+			a.Equal(params["total_num"], "3").Equal(params["amt_type"], "ALL_RAND")
+		default:
+			a.TB().Fatal("无效的地址", url)
+		}
+		return sendReturn
+	})
+
+	// 金额超出范围
+	_, err := r.Send(&Pack{TotalAmount: 99})
+	a.ErrorIs(err, ErrInvalidAmount)
+	_, err = r.Send(&Pack{TotalAmount: 20001})
+	a.ErrorIs(err, ErrInvalidAmount)
+	_, err = r.Send(&Pack{TotalAmount: 500000, SceneID: SceneLottery})
+	a.ErrorIs(err, ErrInvalidAmount)
+
+	ret, err := r.Send(&Pack{MchBillNO: "0010010404201411170000046545", TotalAmount: 100})
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.SendListID, "100000000020150520314766074200").Equal(ret.TotalAmount, 100)
+
+	ret, err = r.Send(&Pack{MchBillNO: "0010010404201411170000046545", TotalAmount: 30, SceneID: SceneLottery})
+	a.NotError(err).NotNil(ret)
+
+	// 裂变红包
+	_, err = r.SendGroup(&Pack{TotalAmount: 300, TotalNum: 2})
+	a.ErrorIs(err, ErrInvalidTotalNum)
+	_, err = r.SendGroup(&Pack{TotalAmount: 200, TotalNum: 3})
+	a.ErrorIs(err, ErrInvalidAmount)
+
+	ret, err = r.SendGroup(&Pack{MchBillNO: "0010010404201411170000046545", TotalAmount: 300, TotalNum: 3})
+	a.NotError(err).NotNil(ret)
+}
+
+func TestRedpack_Query(t *testing.T) {
+	a := assert.New(t, false)
+
+	r := newTestRedpack(a, func(url string, params map[string]string) string {
+		a.Equal(url, pay.GetHBInfoURL)
+		a.Equal(params["appid"], "appid").Equal(params["bill_type"], "MCHT")
+
+		return `<xml>
+<return_code><![CDATA[SUCCESS]]></return_code>
+<return_msg><![CDATA[OK]]></return_msg>
+<result_code><![CDATA[SUCCESS]]></result_code>
+<mch_billno><![CDATA[9010080799701411170000046603]]></mch_billno>
+<mch_id><![CDATA[mchid]]></mch_id>
+<detail_id><![CDATA[10000417012016080830956240040]]></detail_id>
+<status><![CDATA[RECEIVED]]></status>
+<send_type><![CDATA[ACTIVITY]]></send_type>
+<hb_type><![CDATA[NORMAL]]></hb_type>
+<total_num>1</total_num>
+<total_amount>100</total_amount>
+<send_time><![CDATA[2016-08-08 21:49:22]]></send_time>
+<hblist>
+<hbinfo>
+<openid><![CDATA[oHkLxtzmyHXX6FW_cAWo_orTSRXs]]></openid>
+<amount>100</amount>
+<rcv_time><![CDATA[2016-08-08 21:49:46]]></rcv_time>
+</hbinfo>
+</hblist>
+</xml>`
+	})
+
+	info, err := r.Query("9010080799701411170000046603")
+	a.NotError(err).NotNil(info)
+	a.Equal(info.Status, StatusReceived).
+		Equal(info.TotalAmount, 100).
+		Equal(info.Sent(), time.Date(2016, 8, 8, 21, 49, 22, 0, pay.Location)).
+		Length(info.Receivers, 1)
+	a.Equal(info.Receivers[0].OpenID, "oHkLxtzmyHXX6FW_cAWo_orTSRXs").
+		Equal(info.Receivers[0].Amount, 100).
+		Equal(info.Receivers[0].Received(), time.Date(2016, 8, 8, 21, 49, 46, 0, pay.Location))
+
+	// 返回错误
+	r = newTestRedpack(a, func(url string, params map[string]string) string {
+		return `<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>NOT_FOUND</err_code><err_code_des>指定单号数据不存在</err_code_des></xml>`
+	})
+	info, err = r.Query("no")
+	a.Error(err).Nil(info)
+}
@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package refund
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// QueryDateFormat 退款查询中 refund_success_time 的格式
+const QueryDateFormat = "2006-01-02 15:04:05"
+
+// QueryReturn 退款查询的返回值
+type QueryReturn struct {
+	TransactionID      string `xml:"transaction_id"`       // 微信订单号
+	OutTradeNO         string `xml:"out_trade_no"`         // 商户订单号
+	TotalFee           int    `xml:"total_fee"`            // 订单总金额
+	SettlementTotalFee int    `xml:"settlement_total_fee"` // 应结订单金额
+	FeeType            string `xml:"fee_type"`             // 订单金额货币类型
+	CashFee            int    `xml:"cash_fee"`             // 现金支付金额
+	TotalRefundCount   int    `xml:"total_refund_count"`   // 订单总共已发生的部分退款次数
+	RefundCount        int    `xml:"refund_count"`         // 当前返回的退款笔数
+
+	Refunds []*Item
+}
+
+// Item 退款查询中的单笔退款记录
+type Item struct {
+	OutRefundNO         string `xml:"out_refund_no"`         // 商户退款单号
+	RefundID            string `xml:"refund_id"`             // 微信退款单号
+	RefundChannel       string `xml:"refund_channel"`        // 退款渠道
+	RefundFee           int    `xml:"refund_fee"`            // 申请退款金额
+	SettlementRefundFee int    `xml:"settlement_refund_fee"` // 退款金额
+	RefundStatus        string `xml:"refund_status"`         // 退款状态，pay.RefundStatus* 系列常量
+	RefundAccount       string `xml:"refund_account"`        // 退款资金来源
+	RefundRecvAccout    string `xml:"refund_recv_accout"`    // 退款入账账户
+	RefundSuccessTime   string `xml:"refund_success_time"`   // 退款成功时间
+	CouponRefundFee     int    `xml:"coupon_refund_fee"`     // 代金券退款总金额
+	CouponRefundCount   int    `xml:"coupon_refund_count"`   // 退款代金券使用数量
+
+	Coupons []*pay.Coupon
+	success time.Time
+}
+
+// Success 返回 RefundSuccessTime 的 time.Time 格式数据
+//
+// 退款未成功时，返回零值。
+func (item *Item) Success() time.Time {
+	return item.success
+}
+
+// QueryOutRefundNO 通过商户退款单号查询退款
+func (r *Refund) QueryOutRefundNO(outRefundNO string) (*QueryReturn, error) {
+	return r.query("out_refund_no", outRefundNO)
+}
+
+// QueryRefundID 通过微信退款单号查询退款
+func (r *Refund) QueryRefundID(refundID string) (*QueryReturn, error) {
+	return r.query("refund_id", refundID)
+}
+
+// QueryOutTradeNO 通过商户订单号查询退款
+func (r *Refund) QueryOutTradeNO(outTradeNO string) (*QueryReturn, error) {
+	return r.query("out_trade_no", outTradeNO)
+}
+
+// QueryTransactionID 通过微信订单号查询退款
+func (r *Refund) QueryTransactionID(transactionID string) (*QueryReturn, error) {
+	return r.query("transaction_id", transactionID)
+}
+
+func (r *Refund) query(key, val string) (*QueryReturn, error) {
+	maps, err := r.Pay.RefundQuery(map[string]string{
+		key:           val,
+		"device_info": r.DeviceInfo,
+		"sign_type":   r.SignType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.Pay.ValidateAll(r.SignType, maps); err != nil {
+		return nil, err
+	}
+
+	return newQueryReturn(maps)
+}
+
+func newQueryReturn(params map[string]string) (*QueryReturn, error) {
+	ret := &QueryReturn{}
+	if err := xxml.Map2XMLObj(params, ret); err != nil {
+		return nil, err
+	}
+
+	ret.Refunds = make([]*Item, 0, ret.RefundCount)
+	for n := 0; n < ret.RefundCount; n++ {
+		item := &Item{}
+		if err := xxml.Map2XMLObj(pay.GetIndexed(params, n), item); err != nil {
+			return nil, err
+		}
+
+		if item.OutRefundNO == "" && item.RefundID == "" {
+			return nil, fmt.Errorf("返回的退款笔数[%v]和实际的数量不相符", ret.RefundCount)
+		}
+
+		coupons, err := pay.GetNestedCoupons(params, "coupon_refund_", n, item.CouponRefundCount)
+		if err != nil {
+			return nil, err
+		}
+		item.Coupons = coupons
+
+		if item.RefundSuccessTime != "" {
+			if item.success, err = time.ParseInLocation(QueryDateFormat, item.RefundSuccessTime, pay.Location); err != nil {
+				return nil, err
+			}
+		}
+
+		ret.Refunds = append(ret.Refunds, item)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package refund
+
+import (
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/pay"
+)
+
+func TestNewQueryReturn(t *testing.T) {
+	a := assert.New(t, false)
+
+	params := map[string]string{
+		"transaction_id":          "1008450740201411110005820873",
+		"out_trade_no":            "1415757673",
+		"total_fee":               "100",
+		"refund_count":            "2",
+		"out_refund_no_0":         "r0",
+		"refund_id_0":             "2008450740201411110000174436",
+		"refund_fee_0":            "30",
+		"refund_status_0":         pay.RefundStatusSuccess,
+		"refund_success_time_0":   "2016-07-25 15:26:26",
+		"refund_recv_accout_0":    "招商银行信用卡0403",
+		"coupon_refund_fee_0":     "10",
+		"coupon_refund_count_0":   "2",
+		"coupon_refund_id_0_0":    "1",
+		"coupon_type_0_0":         pay.CouponTypeCash,
+		"coupon_refund_fee_0_0":   "4",
+		"coupon_refund_id_0_1":    "2",
+		"coupon_type_0_1":         pay.CouponTypeNoCash,
+		"coupon_refund_fee_0_1":   "6",
+		"out_refund_no_1":         "r1",
+		"refund_fee_1":            "20",
+		"refund_status_1":         pay.RefundStatusProcessing,
+		"settlement_refund_fee_1": "20",
+	}
+
+	ret, err := newQueryReturn(params)
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.TotalFee, 100).Length(ret.Refunds, 2)
+
+	r0 := ret.Refunds[0]
+	a.Equal(r0.OutRefundNO, "r0").
+		Equal(r0.RefundFee, 30).
+		Equal(r0.RefundRecvAccout, "招商银行信用卡0403").
+		Equal(r0.Success(), time.Date(2016, 7, 25, 15, 26, 26, 0, pay.Location)).
+		Length(r0.Coupons, 2).
+		Equal(r0.Coupons[1], &pay.Coupon{ID: 2, Type: pay.CouponTypeNoCash, Fee: 6})
+
+	r1 := ret.Refunds[1]
+	a.Equal(r1.OutRefundNO, "r1").
+		Equal(r1.RefundStatus, pay.RefundStatusProcessing).
+		True(r1.Success().IsZero()).
+		Empty(r1.Coupons)
+
+	// 退款笔数不相符
+	params["refund_count"] = "3"
+	ret, err = newQueryReturn(params)
+	a.Error(err).Nil(ret)
+}
@@ -13,6 +13,8 @@
 //
 //	// 执行退款操作
 //	r.OutTradeNO(...)
+//
+// 服务商模式下，将 Pay 指定为 p.Sub(...) 返回的实例即可。
 package refund
 
 import (
@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package transfers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// BankTransfer 付款到银行卡的数据
+type BankTransfer struct {
+	PartnerTradeNO string // 商户订单号
+	BankNO         string // 收款方银行卡号，会被加密之后传递
+	TrueName       string // 收款方用户名，会被加密之后传递
+	BankCode       string // 收款方开户行，具体值可参考微信支付的文档
+	Amount         int    // 金额，单位为分
+	Desc           string // 付款说明
+}
+
+// BankReturn 付款到银行卡的返回值
+type BankReturn struct {
+	PartnerTradeNO string `xml:"partner_trade_no"` // 商户订单号
+	PaymentNO      string `xml:"payment_no"`       // 微信企业付款单号
+	Amount         int    `xml:"amount"`           // 代付金额
+	CmmsAmt        int    `xml:"cmms_amt"`         // 手续费金额
+}
+
+// BankInfo 付款到银行卡的查询结果
+type BankInfo struct {
+	PartnerTradeNO string `xml:"partner_trade_no"` // 商户订单号
+	PaymentNO      string `xml:"payment_no"`       // 微信企业付款单号
+	BankNOMD5      string `xml:"bank_no_md5"`      // 收款用户银行卡号的 MD5
+	TrueNameMD5    string `xml:"true_name_md5"`    // 收款人真实姓名的 MD5
+	Amount         int    `xml:"amount"`           // 代付金额
+	Status         string `xml:"status"`           // 代付单状态，Status* 系列常量
+	CmmsAmt        int    `xml:"cmms_amt"`         // 手续费金额
+	CreateTime     string `xml:"create_time"`      // 商户下单时间
+	PaySuccTime    string `xml:"pay_succ_time"`    // 成功付款时间
+	Reason         string `xml:"reason"`           // 失败原因
+
+	created time.Time
+	success time.Time
+}
+
+// Created 返回 CreateTime 的 time.Time 格式数据
+func (info *BankInfo) Created() time.Time {
+	return info.created
+}
+
+// Success 返回 PaySuccTime 的 time.Time 格式数据
+//
+// 尚未付款成功时，返回零值。
+func (info *BankInfo) Success() time.Time {
+	return info.success
+}
+
+// PayBank 付款到银行卡
+//
+// key 为通过 [Transfers.PublicKey] 获取的 RSA 公钥，用于加密银行卡号和用户名。
+func (t *Transfers) PayBank(key *rsa.PublicKey, b *BankTransfer) (*BankReturn, error) {
+	bankNO, err := encrypt(key, b.BankNO)
+	if err != nil {
+		return nil, err
+	}
+
+	trueName, err := encrypt(key, b.TrueName)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := t.post(pay.PayBankURL, map[string]string{
+		"partner_trade_no": b.PartnerTradeNO,
+		"enc_bank_no":      bankNO,
+		"enc_true_name":    trueName,
+		"bank_code":        b.BankCode,
+		"amount":           strconv.Itoa(b.Amount),
+		"desc":             b.Desc,
+	}, "appid") // 该接口不需要 appid
+	if err != nil {
+		return nil, err
+	}
+
+	if params["mch_id"] != t.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+
+	ret := &BankReturn{}
+	if err = xxml.Map2XMLObj(params, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryBank 查询付款到银行卡的结果
+func (t *Transfers) QueryBank(partnerTradeNO string) (*BankInfo, error) {
+	params, err := t.post(pay.QueryBankURL, map[string]string{
+		"partner_trade_no": partnerTradeNO,
+	}, "appid") // 该接口不需要 appid
+	if err != nil {
+		return nil, err
+	}
+
+	if params["mch_id"] != t.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+
+	info := &BankInfo{}
+	if err = xxml.Map2XMLObj(params, info); err != nil {
+		return nil, err
+	}
+	if info.created, err = parseTime(info.CreateTime); err != nil {
+		return nil, err
+	}
+	if info.success, err = parseTime(info.PaySuccTime); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// PublicKey 获取用于加密银行卡号和用户名的 RSA 公钥
+//
+// 公钥一般不会改变，调用者可以自行缓存。
+func (t *Transfers) PublicKey() (*rsa.PublicKey, error) {
+	params, err := t.post(pay.GetPublicKeyURL, map[string]string{
+		"sign_type": pay.SignTypeMD5,
+	}, "appid") // 该接口不需要 appid
+	if err != nil {
+		return nil, err
+	}
+
+	if params["mch_id"] != t.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+
+	return internal.ParsePublicKey([]byte(params["pub_key"]))
+}
+
+// 采用 RSA-OAEP 加密，返回 base64 编码的内容
+func encrypt(key *rsa.PublicKey, data string) (string, error) {
+	bs, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, []byte(data), nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bs), nil
+}
@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package transfers 企业付款
+//
+// 包括付款到零钱和付款到银行卡，均需要使用证书，即 Pay 需要由 [pay.NewTLSPay] 创建。
+//
+//	p, err := pay.NewTLSPay(...)
+//	t := transfers.Transfers{
+//	    Pay: p,
+//	    SpbillCreateIP: "127.0.0.1",
+//	}
+//
+//	ret, err := t.Transfer(&transfers.Transfer{
+//	    PartnerTradeNO: "10000098201411111234567890",
+//	    OpenID: "oxTWIuGaIt6gTKsQRLau2M0yL16E",
+//	    CheckName: transfers.CheckNameForce,
+//	    ReUserName: "张三",
+//	    Amount: 100,
+//	    Desc: "奖励",
+//	})
+package transfers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// 校验用户姓名的选项
+const (
+	CheckNameNo    = "NO_CHECK"    // 不校验真实姓名
+	CheckNameForce = "FORCE_CHECK" // 强校验真实姓名
+)
+
+// 付款的状态
+const (
+	StatusSuccess    = "SUCCESS"    // 转账成功
+	StatusFailed     = "FAILED"     // 转账失败
+	StatusProcessing = "PROCESSING" // 处理中
+	StatusBankFail   = "BANK_FAIL"  // 银行退票，仅付款到银行卡
+)
+
+// 返回内容中的时间格式
+const timeFormat = "2006-01-02 15:04:05"
+
+// 预定义的错误类型
+var (
+	ErrReUserNameNotSet = errors.New("强校验真实姓名时，必须指定 ReUserName")
+	ErrInvalidCheckName = errors.New("无效的 CheckName")
+)
+
+// Transfers 企业付款的配置
+type Transfers struct {
+	Pay            *pay.Pay
+	DeviceInfo     string // 设备号
+	SpbillCreateIP string // 调用接口的机器 IP
+}
+
+// Transfer 付款到零钱的数据
+type Transfer struct {
+	PartnerTradeNO string // 商户订单号
+	OpenID         string // 用户的 openid
+	CheckName      string // 校验用户姓名选项，CheckName* 系列常量，默认为 CheckNameNo
+	ReUserName     string // 收款用户姓名，CheckName 为 CheckNameForce 时必填
+	Amount         int    // 金额，单位为分
+	Desc           string // 付款备注
+}
+
+// Return 付款到零钱的返回值
+type Return struct {
+	PartnerTradeNO string `xml:"partner_trade_no"` // 商户订单号
+	PaymentNO      string `xml:"payment_no"`       // 微信付款单号
+	PaymentTime    string `xml:"payment_time"`     // 付款成功时间
+
+	payment time.Time
+}
+
+// Info 付款到零钱的查询结果
+type Info struct {
+	PartnerTradeNO string `xml:"partner_trade_no"` // 商户订单号
+	DetailID       string `xml:"detail_id"`        // 微信付款单号
+	Status         string `xml:"status"`           // 转账状态，Status* 系列常量
+	Reason         string `xml:"reason"`           // 失败原因
+	OpenID         string `xml:"openid"`           // 收款用户 openid
+	TransferName   string `xml:"transfer_name"`    // 收款用户姓名
+	PaymentAmount  int    `xml:"payment_amount"`   // 付款金额
+	TransferTime   string `xml:"transfer_time"`    // 转账时间
+	PaymentTime    string `xml:"payment_time"`     // 付款成功时间
+	Desc           string `xml:"desc"`             // 付款备注
+
+	payment time.Time
+}
+
+// Payment 返回 PaymentTime 的 time.Time 格式数据
+func (ret *Return) Payment() time.Time {
+	return ret.payment
+}
+
+// Payment 返回 PaymentTime 的 time.Time 格式数据
+//
+// 尚未付款成功时，返回零值。
+func (info *Info) Payment() time.Time {
+	return info.payment
+}
+
+// Transfer 付款到零钱
+func (t *Transfers) Transfer(tr *Transfer) (*Return, error) {
+	checkName := tr.CheckName
+	switch checkName {
+	case "":
+		checkName = CheckNameNo
+	case CheckNameNo:
+	case CheckNameForce:
+		if tr.ReUserName == "" {
+			return nil, ErrReUserNameNotSet
+		}
+	default:
+		return nil, ErrInvalidCheckName
+	}
+
+	params, err := t.post(pay.TransfersURL, map[string]string{
+		"mch_appid":        t.Pay.AppID(),
+		"mchid":            t.Pay.MchID(),
+		"device_info":      t.DeviceInfo,
+		"partner_trade_no": tr.PartnerTradeNO,
+		"openid":           tr.OpenID,
+		"check_name":       checkName,
+		"re_user_name":     tr.ReUserName,
+		"amount":           strconv.Itoa(tr.Amount),
+		"desc":             tr.Desc,
+		"spbill_create_ip": t.SpbillCreateIP,
+	}, "appid", "mch_id") // 以 mch_appid 和 mchid 代替
+	if err != nil {
+		return nil, err
+	}
+
+	if params["mchid"] != t.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+	if params["mch_appid"] != t.Pay.AppID() {
+		return nil, pay.ErrInvalidAppid
+	}
+
+	ret := &Return{}
+	if err = xxml.Map2XMLObj(params, ret); err != nil {
+		return nil, err
+	}
+	if ret.payment, err = parseTime(ret.PaymentTime); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Query 查询付款到零钱的结果
+func (t *Transfers) Query(partnerTradeNO string) (*Info, error) {
+	params, err := t.post(pay.GetTransferInfoURL, map[string]string{
+		"partner_trade_no": partnerTradeNO,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if params["mch_id"] != t.Pay.MchID() {
+		return nil, pay.ErrInvalidMchid
+	}
+	if params["appid"] != t.Pay.AppID() {
+		return nil, pay.ErrInvalidAppid
+	}
+
+	info := &Info{}
+	if err = xxml.Map2XMLObj(params, info); err != nil {
+		return nil, err
+	}
+	if info.payment, err = parseTime(info.PaymentTime); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// 发送请求并验证返回的结果
+//
+// 企业付款的返回内容并不包含签名，所以仅验证 return_code 和 result_code。
+// omit 为不需要自动添加的字段，参考 [pay.Pay.PostOmit]。
+func (t *Transfers) post(url string, params map[string]string, omit ...string) (map[string]string, error) {
+	ret, err := t.Pay.PostOmit(url, params, omit...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = t.Pay.ValidateResult(ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(timeFormat, v, pay.Location)
+}
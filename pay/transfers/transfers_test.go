@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package transfers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// 声明一个由 h 处理所有请求的 Transfers 实例
+//
+// h 的参数为请求的地址和参数，返回值为响应的 XML 内容。
+func newTestTransfers(a *assert.Assertion, h func(url string, params map[string]string) string) *Transfers {
+	client := &http.Client{
+		Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			params, err := xxml.MapFromXMLReader(r.Body)
+			a.NotError(err)
+
+			sign, err := pay.Sign("apikey", params["sign_type"], params)
+			a.NotError(err).Equal(sign, params["sign"])
+
+			w := httptest.NewRecorder()
+			w.WriteString(h(r.URL.String(), params))
+			return w.Result(), nil
+		}),
+	}
+
+	return &Transfers{
+		Pay:            pay.New("mchid", "appid", "apikey", client),
+		SpbillCreateIP: "127.0.0.1",
+	}
+}
+
+func TestTransfers_Transfer(t *testing.T) {
+	a := assert.New(t, false)
+
+	tr := newTestTransfers(a, func(url string, params map[string]string) string {
+		a.Equal(url, pay.TransfersURL)
+		a.Equal(params["mch_appid"], "appid").
+			Equal(params["mchid"], "mchid").
+			Empty(params["appid"]).
+			Empty(params["mch_id"]).
+			Equal(params["check_name"], CheckNameForce).
+			Equal(params["re_user_name"], "张三").
+			Equal(params["amount"], "100")
+
+		return `<xml>
+<return_code><![CDATA[SUCCESS]]></return_code>
+<mch_appid><![CDATA[appid]]></mch_appid>
+<mchid><![CDATA[mchid]]></mchid>
+<result_code><![CDATA[SUCCESS]]></result_code>
+<partner_trade_no><![CDATA[10013574201505191526582441]]></partner_trade_no>
+<payment_no><![CDATA[1000018301201505190181489473]]></payment_no>
+<payment_time><![CDATA[2015-05-19 15:26:59]]></payment_time>
+</xml>`
+	})
+
+	_, err := tr.Transfer(&Transfer{CheckName: CheckNameForce})
+	a.ErrorIs(err, ErrReUserNameNotSet)
+
+	_, err = tr.Transfer(&Transfer{CheckName: "invalid"})
+	a.ErrorIs(err, ErrInvalidCheckName)
+
+	ret, err := tr.Transfer(&Transfer{
+		PartnerTradeNO: "10013574201505191526582441",
+		OpenID:         "openid",
+		CheckName:      CheckNameForce,
+		ReUserName:     "张三",
+		Amount:         100,
+		Desc:           "desc",
+	})
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.PaymentNO, "1000018301201505190181489473").
+		Equal(ret.Payment(), time.Date(2015, 5, 19, 15, 26, 59, 0, pay.Location))
+
+	// 返回错误
+	tr = newTestTransfers(a, func(url string, params map[string]string) string {
+		return `<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>NOTENOUGH</err_code><err_code_des>余额不足</err_code_des></xml>`
+	})
+	ret, err = tr.Transfer(&Transfer{OpenID: "openid", Amount: 100})
+	a.Error(err).Nil(ret)
+}
+
+func TestTransfers_Query(t *testing.T) {
+	a := assert.New(t, false)
+
+	tr := newTestTransfers(a, func(url string, params map[string]string) string {
+		a.Equal(url, pay.GetTransferInfoURL)
+		a.Equal(params["appid"], "appid").Equal(params["mch_id"], "mchid")
+
+		return `<xml>
+<return_code><![CDATA[SUCCESS]]></return_code>
+<result_code><![CDATA[SUCCESS]]></result_code>
+<appid><![CDATA[appid]]></appid>
+<mch_id><![CDATA[mchid]]></mch_id>
+<partner_trade_no><![CDATA[1000005901201407261446939628]]></partner_trade_no>
+<detail_id><![CDATA[1000000000201503283103439304]]></detail_id>
+<status><![CDATA[SUCCESS]]></status>
+<openid><![CDATA[oxTWIuGaIt6gTKsQRLau2M0yL16E]]></openid>
+<payment_amount>650</payment_amount>
+<transfer_time><![CDATA[2015-04-21 20:00:00]]></transfer_time>
+<payment_time><![CDATA[2015-04-21 20:00:05]]></payment_time>
+<desc><![CDATA[福利测试]]></desc>
+</xml>`
+	})
+
+	info, err := tr.Query("1000005901201407261446939628")
+	a.NotError(err).NotNil(info)
+	a.Equal(info.Status, StatusSuccess).
+		Equal(info.PaymentAmount, 650).
+		Equal(info.Payment(), time.Date(2015, 4, 21, 20, 0, 5, 0, pay.Location))
+}
+
+func TestTransfers_PayBank(t *testing.T) {
+	a := assert.New(t, false)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NotError(err)
+	pubKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	decrypt := func(v string) string {
+		data, err := base64.StdEncoding.DecodeString(v)
+		a.NotError(err)
+		data, err = rsa.DecryptOAEP(sha1.New(), rand.Reader, key, data, nil)
+		a.NotError(err)
+		return string(data)
+	}
+
+	tr := newTestTransfers(a, func(url string, params map[string]string) string {
+		a.Empty(params["appid"]).Equal(params["mch_id"], "mchid")
+
+		switch url {
+		case pay.GetPublicKeyURL:
+			return `<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><mch_id>mchid</mch_id><pub_key><![CDATA[` + string(pubKey) + `]]></pub_key></xml>`
+		case pay.PayBankURL:
+			a.Equal(decrypt(params["enc_bank_no"]), "6222020000000000").
+				Equal(decrypt(params["enc_true_name"]), "张三").
+				Equal(params["bank_code"], "1002")
+			return `<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><mch_id>mchid</mch_id><partner_trade_no>no1</partner_trade_no><payment_no>10000600500852017030900000020006012</payment_no><amount>500</amount><cmms_amt>0</cmms_amt></xml>`
+		case pay.QueryBankURL:
+			return `<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><mch_id>mchid</mch_id><partner_trade_no>no1</partner_trade_no><status>PROCESSING</status><amount>500</amount><create_time>2017-03-09 15:04:04</create_time><pay_succ_time></pay_succ_time></xml>`
+		default:
+			a.TB().Fatal("无效的地址", url)
+			return ""
+		}
+	})
+
+	k, err := tr.PublicKey()
+	a.NotError(err).NotNil(k)
+	a.True(k.Equal(&key.PublicKey))
+
+	ret, err := tr.PayBank(k, &BankTransfer{
+		PartnerTradeNO: "no1",
+		BankNO:         "6222020000000000",
+		TrueName:       "张三",
+		BankCode:       "1002",
+		Amount:         500,
+	})
+	a.NotError(err).NotNil(ret)
+	a.Equal(ret.Amount, 500).Equal(ret.PaymentNO, "10000600500852017030900000020006012")
+
+	info, err := tr.QueryBank("no1")
+	a.NotError(err).NotNil(info)
+	a.Equal(info.Status, StatusProcessing).
+		Equal(info.Created(), time.Date(2017, 3, 9, 15, 4, 4, 0, pay.Location)).
+		True(info.Success().IsZero())
+}
@@ -14,6 +14,7 @@ import (
 // Return 表示统一下单功能的返回值类型。
 type Return struct {
 	pay       *pay.Pay
+	sub       bool // 是否以子商户的 appid 调起支付
 	TradeType string
 	PrepayID  string
 	CodeURL   string // 二维码链接
@@ -30,10 +31,17 @@ type BrandWCPayRequest struct {
 }
 
 // GetBrandWCPayRequest 获取 BrandWCPayRequest 数据
+//
+// 服务商模式下，若下单时指定的是 SubOpenID，则 appId 为子商户的 appid。
 func (r *Return) GetBrandWCPayRequest(signType string) (*BrandWCPayRequest, error) {
+	appid := r.pay.AppID()
+	if r.sub {
+		appid = r.pay.SubAppID()
+	}
+
 	now := time.Now().Unix()
 	ret := &BrandWCPayRequest{
-		AppID:       r.pay.AppID(),
+		AppID:       appid,
 		TimeStamp:   strconv.FormatInt(now, 10),
 		NonceString: pay.NonceString(),
 		Package:     "prepay_id=" + r.PrepayID,
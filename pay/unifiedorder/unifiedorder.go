@@ -20,6 +20,8 @@
 //	o.Body = "..."
 //	o.Goods(...)
 //	o.Pay(...)
+//
+// 服务商模式下，只需要将 p 替换成 p.Sub(...) 返回的实例即可。
 package unifiedorder
 
 import (
@@ -68,6 +70,7 @@ type Order struct {
 	Tag        string    // 商品标记
 	ProductID  string    // 商品 ID
 	OpenID     string    // 用户标识
+	SubOpenID  string    // 用户在子商户 appid 下的标识，仅服务商模式下有效
 	goods      []*Good
 }
 
@@ -111,6 +114,7 @@ func (o *Order) NewOrder() *Order {
 	ret.Tag = ""
 	ret.ProductID = ""
 	ret.OpenID = ""
+	ret.SubOpenID = ""
 	ret.goods = ret.goods[:0]
 
 	return ret
@@ -178,6 +182,7 @@ func (o *Order) params() (map[string]string, error) {
 		"product_id":       o.ProductID,
 		"limit_pay":        o.limitPay(),
 		"openid":           o.OpenID,
+		"sub_openid":       o.SubOpenID,
 	}, nil
 }
 
@@ -206,6 +211,7 @@ func (o *Order) Pay() (*Return, error) {
 
 	return &Return{
 		pay:       o.pay,
+		sub:       o.SubOpenID != "" && o.pay.SubAppID() != "",
 		TradeType: m["trade_type"],
 		PrepayID:  m["prepay_id"],
 		CodeURL:   m["code_url"],
@@ -5,7 +5,9 @@
 package pay
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -58,7 +60,7 @@ func Sign(apikey, signType string, params map[string]string) (string, error) {
 	case "", SignTypeMD5:
 		h = md5.New()
 	case SignTypeHmacSha256:
-		//h = hmac.New(sha256.New, []byte("123"))
+		h = hmac.New(sha256.New, []byte(apikey))
 	default:
 		return "", fmt.Errorf("无效的签名类型：%v", signType)
 	}
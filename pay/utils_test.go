@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package pay
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestSign(t *testing.T) {
+	a := assert.New(t, false)
+
+	// 微信支付文档中的示例
+	params := map[string]string{
+		"appid":       "wxd930ea5d5a258f4f",
+		"mch_id":      "10000100",
+		"device_info": "1000",
+		"body":        "test",
+		"nonce_str":   "ibuaiVcKdpRxkhJA",
+		"sign":        "ignored",
+	}
+	apikey := "192006250b4c09247ec02edce69f6a2d"
+
+	sign, err := Sign(apikey, "", params)
+	a.NotError(err).Equal(sign, "9A0A8659F005D6984697E2CA0A9CF3B7")
+
+	sign, err = Sign(apikey, SignTypeMD5, params)
+	a.NotError(err).Equal(sign, "9A0A8659F005D6984697E2CA0A9CF3B7")
+
+	sign, err = Sign(apikey, SignTypeHmacSha256, params)
+	a.NotError(err).Equal(sign, "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6")
+
+	sign, err = Sign(apikey, "invalid", params)
+	a.Error(err).Empty(sign)
+}
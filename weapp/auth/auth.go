@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"time"
 
 	"github.com/issue9/wechat/common"
 )
@@ -26,8 +25,6 @@ type Response struct {
 	Openid     string `json:"openid"`
 	SessionKey string `json:"session_key"`
 	UnionID    string `json:"unionid,omitempty"` // 某些情况下存在
-
-	created time.Time
 }
 
 // Authorization 执行登录验证，并获取相应的数据
@@ -45,6 +42,9 @@ func Authorization(conf *common.Config, jscode string) (*Response, error) {
 	}
 	bs, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
 
 	data := &Response{}
 	if err := json.Unmarshal(bs, data); err != nil {
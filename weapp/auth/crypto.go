@@ -18,6 +18,7 @@ import (
 var (
 	ErrInvalidSessionKey = errors.New("无效的 sessionkey")
 	ErrInvalidInitVector = errors.New("无效的 Initization vector")
+	ErrInvalidData       = errors.New("无效的加密数据")
 )
 
 // 加密数据解密之后的部分内容
@@ -50,11 +51,17 @@ func Decode(appid, sessionkey, data, iv string) ([]byte, *Watermark, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	if len(aesiv) != aes.BlockSize {
+		return nil, nil, ErrInvalidInitVector
+	}
 
 	cipherText, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
 		return nil, nil, err
 	}
+	if len(cipherText) == 0 || len(cipherText)%aes.BlockSize != 0 {
+		return nil, nil, ErrInvalidData
+	}
 
 	block, err := aes.NewCipher(aeskey)
 	if err != nil {
@@ -64,7 +71,10 @@ func Decode(appid, sessionkey, data, iv string) ([]byte, *Watermark, error) {
 	mode := cipher.NewCBCDecrypter(block, aesiv)
 	mode.CryptBlocks(cipherText, cipherText)
 
-	cipherText = internal.PKCS7UnPadding(cipherText)
+	if cipherText, err = internal.PKCS7UnPadding(cipherText); err != nil {
+		return nil, nil, err
+	}
+
 	obj := &encyData{}
 	if err = json.Unmarshal(cipherText, obj); err != nil {
 		return nil, nil, err
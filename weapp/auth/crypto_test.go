@@ -5,18 +5,16 @@
 package auth
 
 import (
+	"encoding/base64"
 	"testing"
 
 	"github.com/issue9/assert/v4"
 )
 
-func TestDecode(t *testing.T) {
-	a := assert.New(t, false)
-
-	appid := "wx4f4bc4dec97d474b"
-	sessionKey := "tiihtNczf5v6AKRyjwEUhQ=="
-	iv := "r7BXXKkLb8qrSNn05n0qiA=="
-	encryptedData := `CiyLU1Aw2KjvrjMdj8YKliAjtP4gsMZM
+const (
+	sessionKey    = "tiihtNczf5v6AKRyjwEUhQ=="
+	iv            = "r7BXXKkLb8qrSNn05n0qiA=="
+	encryptedData = `CiyLU1Aw2KjvrjMdj8YKliAjtP4gsMZM
 QmRzooG2xrDcvSnxIMXFufNstNGTyaGS
 9uT5geRa0W4oTOb1WT7fJlAC+oNPdbB+
 3hVbJSRgv+4lGOETKUQz6OYStslQ142d
@@ -33,9 +31,36 @@ lqYTopNHR1Vf7XjfhQlVsAJdNiKdYmYV
 oKlaRv85IfVunYzO0IKXsyl7JCUjCpoG
 20f0a04COwfneQAGGwd5oa+T8yO5hzuy
 Db/XcxxmK01EpqOyuxINew==`
+)
+
+func TestDecode(t *testing.T) {
+	a := assert.New(t, false)
+
+	appid := "wx4f4bc4dec97d474b"
 
 	data, watermark, err := Decode(appid, sessionKey, encryptedData, iv)
 	a.NotError(err).NotNil(data).NotNil(watermark)
 	a.Equal(appid, watermark.Appid)
 	a.TB().Log(string(data))
 }
+
+func TestDecode_invalid(t *testing.T) {
+	a := assert.New(t, false)
+	appid := "wx4f4bc4dec97d474b"
+
+	// 长度不是块大小的整数倍
+	data, watermark, err := Decode(appid, sessionKey, base64.StdEncoding.EncodeToString([]byte("12345")), iv)
+	a.ErrorIs(err, ErrInvalidData).Nil(data).Nil(watermark)
+
+	// 空内容
+	data, watermark, err = Decode(appid, sessionKey, "", iv)
+	a.ErrorIs(err, ErrInvalidData).Nil(data).Nil(watermark)
+
+	// 解码之后长度不为 16 的 iv
+	data, watermark, err = Decode(appid, sessionKey, encryptedData, "r7BXXKkLb8qrSNn05n0qiAAA")
+	a.ErrorIs(err, ErrInvalidInitVector).Nil(data).Nil(watermark)
+
+	// 错误的 sessionkey 会导致无效的填充
+	data, watermark, err = Decode(appid, "AAAAAAAAAAAAAAAAAAAAAA==", encryptedData, iv)
+	a.Error(err).Nil(data).Nil(watermark)
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+)
+
+// 水印验证的错误信息
+var (
+	ErrInvalidWatermarkAppid = errors.New("水印中的 appid 与当前的不匹配")
+	ErrWatermarkExpired      = errors.New("水印已经过期")
+)
+
+// PhoneInfo 用户绑定的手机号
+type PhoneInfo struct {
+	PhoneNumber     string     `json:"phoneNumber"`     // 用户绑定的手机号，国外手机号会有区号
+	PurePhoneNumber string     `json:"purePhoneNumber"` // 没有区号的手机号
+	CountryCode     string     `json:"countryCode"`     // 区号
+	Watermark       *Watermark `json:"watermark"`
+}
+
+// UserInfo 用户的基本信息
+type UserInfo struct {
+	OpenID    string     `json:"openId"`
+	NickName  string     `json:"nickName"`
+	Gender    int        `json:"gender"` // 性别，0 未知，1 男，2 女
+	Language  string     `json:"language"`
+	City      string     `json:"city"`
+	Province  string     `json:"province"`
+	Country   string     `json:"country"`
+	AvatarURL string     `json:"avatarUrl"`
+	UnionID   string     `json:"unionId,omitempty"`
+	Watermark *Watermark `json:"watermark"`
+}
+
+// ShareInfo 转发到群聊之后获取的群信息
+type ShareInfo struct {
+	OpenGID   string     `json:"openGId"` // 群对当前小程序的唯一 ID
+	Watermark *Watermark `json:"watermark"`
+}
+
+// RunData 微信运动步数
+type RunData struct {
+	StepInfoList []*StepInfo `json:"stepInfoList"` // 最近 30 天的步数
+	Watermark    *Watermark  `json:"watermark"`
+}
+
+// StepInfo 单日的微信运动步数
+type StepInfo struct {
+	Timestamp int64 `json:"timestamp"` // 当天零点的时间戳
+	Step      int   `json:"step"`
+}
+
+// Time 返回水印的生成时间
+func (w *Watermark) Time() time.Time {
+	return time.Unix(w.Timestamp, 0)
+}
+
+// Validate 验证水印
+//
+// appid 必须与水印中的一致；maxAge 表示水印的有效时长，小于等于 0 表示不验证时效。
+func (w *Watermark) Validate(appid string, maxAge time.Duration) error {
+	if w.Appid != appid {
+		return ErrInvalidWatermarkAppid
+	}
+
+	if maxAge > 0 && time.Since(w.Time()) > maxAge {
+		return ErrWatermarkExpired
+	}
+
+	return nil
+}
+
+// DecodeObject 解析加密数据至 v，同时验证其水印
+//
+// maxAge 的说明可参考 [Watermark.Validate]。
+func DecodeObject(appid, sessionkey, data, iv string, maxAge time.Duration, v interface{}) error {
+	text, watermark, err := Decode(appid, sessionkey, data, iv)
+	if err != nil {
+		return err
+	}
+
+	if watermark == nil {
+		return ErrInvalidWatermarkAppid
+	}
+	if err = watermark.Validate(appid, maxAge); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(text, v)
+}
+
+// DecodePhone 解析旧版的 getPhoneNumber 按钮返回的加密数据
+//
+// 新版的按钮仅返回 code，需要调用 [GetPhoneNumber]。
+func DecodePhone(appid, sessionkey, data, iv string, maxAge time.Duration) (*PhoneInfo, error) {
+	info := &PhoneInfo{}
+	if err := DecodeObject(appid, sessionkey, data, iv, maxAge, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecodeUserInfo 解析 wx.getUserInfo 返回的加密数据
+func DecodeUserInfo(appid, sessionkey, data, iv string, maxAge time.Duration) (*UserInfo, error) {
+	info := &UserInfo{}
+	if err := DecodeObject(appid, sessionkey, data, iv, maxAge, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecodeShareInfo 解析 wx.getShareInfo 返回的加密数据
+func DecodeShareInfo(appid, sessionkey, data, iv string, maxAge time.Duration) (*ShareInfo, error) {
+	info := &ShareInfo{}
+	if err := DecodeObject(appid, sessionkey, data, iv, maxAge, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// DecodeRunData 解析 wx.getWeRunData 返回的加密数据
+func DecodeRunData(appid, sessionkey, data, iv string, maxAge time.Duration) (*RunData, error) {
+	info := &RunData{}
+	if err := DecodeObject(appid, sessionkey, data, iv, maxAge, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetPhoneNumber 通过新版 getPhoneNumber 按钮返回的 code 获取用户手机号
+//
+// 该 code 与 wx.login 返回的 code 不同，且只能使用一次。
+func GetPhoneNumber(srv token.Server, code string) (*PhoneInfo, error) {
+	req := &struct {
+		Code string `json:"code"`
+	}{
+		Code: code,
+	}
+
+	resp := &struct {
+		PhoneInfo *PhoneInfo `json:"phone_info"`
+	}{}
+
+	url := token.URL(srv, "wxa/business/getuserphonenumber", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return nil, err
+	}
+	return resp.PhoneInfo, nil
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/wechattest"
+)
+
+// 采用与微信相同的方式加密 text
+func encrypt(a *assert.Assertion, sessionkey, iv, text string) string {
+	key, err := base64.StdEncoding.DecodeString(sessionkey)
+	a.NotError(err)
+	aesiv, err := base64.StdEncoding.DecodeString(iv)
+	a.NotError(err)
+
+	block, err := aes.NewCipher(key)
+	a.NotError(err)
+
+	data := internal.PKCS7Padding([]byte(text), aes.BlockSize)
+	cipher.NewCBCEncrypter(block, aesiv).CryptBlocks(data, data)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestWatermark_Validate(t *testing.T) {
+	a := assert.New(t, false)
+
+	w := &Watermark{Appid: "appid", Timestamp: time.Now().Unix()}
+	a.NotError(w.Validate("appid", time.Minute)).
+		ErrorIs(w.Validate("other", time.Minute), ErrInvalidWatermarkAppid)
+
+	w.Timestamp = time.Now().Add(-time.Hour).Unix()
+	a.ErrorIs(w.Validate("appid", time.Minute), ErrWatermarkExpired).
+		NotError(w.Validate("appid", 0))
+}
+
+func TestDecodePhone(t *testing.T) {
+	a := assert.New(t, false)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	data := encrypt(a, sessionKey, iv, `{"phoneNumber":"+86 13580006666","purePhoneNumber":"13580006666","countryCode":"86","watermark":{"appid":"appid","timestamp":`+ts+`}}`)
+
+	info, err := DecodePhone("appid", sessionKey, data, iv, time.Minute)
+	a.NotError(err).NotNil(info).
+		Equal(info.PurePhoneNumber, "13580006666").
+		Equal(info.CountryCode, "86").
+		Equal(info.Watermark.Appid, "appid")
+
+	info, err = DecodePhone("other", sessionKey, data, iv, time.Minute)
+	a.ErrorIs(err, ErrInvalidWatermarkAppid).Nil(info)
+}
+
+func TestDecodeUserInfo(t *testing.T) {
+	a := assert.New(t, false)
+
+	// 示例数据的时间较早，不验证时效
+	info, err := DecodeUserInfo("wx4f4bc4dec97d474b", sessionKey, encryptedData, iv, 0)
+	a.NotError(err).NotNil(info).
+		Equal(info.OpenID, "oGZUI0egBJY1zhBYw2KhdUfwVJJE").
+		Equal(info.Gender, 1)
+
+	info, err = DecodeUserInfo("wx4f4bc4dec97d474b", sessionKey, encryptedData, iv, time.Hour)
+	a.ErrorIs(err, ErrWatermarkExpired).Nil(info)
+}
+
+func TestDecodeShareInfo_RunData(t *testing.T) {
+	a := assert.New(t, false)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	data := encrypt(a, sessionKey, iv, `{"openGId":"OPENGID","watermark":{"appid":"appid","timestamp":`+ts+`}}`)
+	share, err := DecodeShareInfo("appid", sessionKey, data, iv, time.Minute)
+	a.NotError(err).Equal(share.OpenGID, "OPENGID")
+
+	data = encrypt(a, sessionKey, iv, `{"stepInfoList":[{"timestamp":1445866601,"step":100},{"timestamp":1445876601,"step":120}],"watermark":{"appid":"appid","timestamp":`+ts+`}}`)
+	run, err := DecodeRunData("appid", sessionKey, data, iv, time.Minute)
+	a.NotError(err).Length(run.StepInfoList, 2).Equal(run.StepInfoList[1].Step, 120)
+}
+
+func TestGetPhoneNumber(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxa/business/getuserphonenumber", func(r *http.Request) interface{} {
+		req := map[string]string{}
+		a.NotError(json.NewDecoder(r.Body).Decode(&req))
+		if req["code"] != "phone-code" {
+			return &common.Result{Code: 40029, Message: "invalid code"}
+		}
+
+		return map[string]interface{}{
+			"errcode": 0,
+			"errmsg":  "ok",
+			"phone_info": map[string]interface{}{
+				"phoneNumber":     "+86 13580006666",
+				"purePhoneNumber": "13580006666",
+				"countryCode":     "86",
+				"watermark":       map[string]interface{}{"appid": wechattest.AppID, "timestamp": 1637744274},
+			},
+		}
+	})
+
+	info, err := GetPhoneNumber(tksrv, "phone-code")
+	a.NotError(err).NotNil(info).
+		Equal(info.PhoneNumber, "+86 13580006666").
+		Equal(info.PurePhoneNumber, "13580006666").
+		Equal(info.CountryCode, "86").
+		Equal(info.Watermark.Appid, wechattest.AppID)
+
+	info, err = GetPhoneNumber(tksrv, "other")
+	a.Equal(err.(*common.Result).Code, 40029).Nil(info)
+}
@@ -5,8 +5,12 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"sync"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/issue9/wechat/common"
@@ -15,72 +19,167 @@ import (
 // ErrWeappUnauthorization 表示微信未登录，或是登录已经过期
 var ErrWeappUnauthorization = errors.New("微信未登录")
 
+// DefaultExpired 会话的默认有效时间
+const DefaultExpired = 24 * time.Hour
+
 // Server 小程序状态管理服务
+//
+// 登录之后会生成一个不透明的会话令牌返回给小程序，
+// 之后小程序通过该令牌与服务端交互，openid 和 session_key 不会离开服务端。
 type Server struct {
-	conf *common.Config
+	conf    *common.Config
+	store   Store
+	expired time.Duration
+}
 
-	// 保存从服务端返回的内容，键名为 openid，键值为整个返回值
-	tokens       map[string]*Response
-	tokensLocker sync.RWMutex
-	expired      time.Duration
+// LoginResponse [Server.ServeHTTP] 返回给小程序的内容
+type LoginResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"` // 有效时间，单位为秒
 }
 
 // NewServer 声明一个新的 Server
 //
-// cap 表示初始容量。
-// gctick 表示 GC 的启动频率，根据业务量自定义一个合理的值。
-func NewServer(conf *common.Config, cap int, gctick, expired time.Duration) *Server {
-	srv := &Server{
+// store 为会话的存储方式，如果为空，则采用 [NewMemoryStore]；
+// expired 表示会话的有效时间，如果小于等于 0，则采用 [DefaultExpired]。
+func NewServer(conf *common.Config, store Store, expired time.Duration) *Server {
+	if store == nil {
+		store = NewMemoryStore(100)
+	}
+
+	if expired <= 0 {
+		expired = DefaultExpired
+	}
+
+	return &Server{
 		conf:    conf,
-		tokens:  make(map[string]*Response, cap),
+		store:   store,
 		expired: expired,
 	}
-
-	go srv.gc(gctick)
-	return srv
 }
 
-// New 申请一个新的登录 token
-func (srv *Server) New(jscode string) (*Response, error) {
+// Login 根据 wx.login 返回的 jscode 执行登录
+//
+// 返回新的会话令牌及对应的会话信息。
+func (srv *Server) Login(jscode string) (string, *Session, error) {
 	resp, err := Authorization(srv.conf, jscode)
 	if err != nil {
-		return nil, err
+		return "", nil, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	s := &Session{
+		OpenID:     resp.Openid,
+		SessionKey: resp.SessionKey,
+		UnionID:    resp.UnionID,
+		Expires:    time.Now().Add(srv.expired),
+	}
+	if err = srv.store.Set(token, s); err != nil {
+		return "", nil, err
+	}
+
+	return token, s, nil
+}
+
+// Session 获取令牌对应的会话信息
+func (srv *Server) Session(token string) (*Session, error) {
+	return srv.store.Get(token)
+}
+
+// Logout 注销令牌
+func (srv *Server) Logout(token string) error {
+	return srv.store.Delete(token)
+}
+
+// Decode 解码令牌对应用户的加密数据
+func (srv *Server) Decode(token, data, iv string) ([]byte, *Watermark, error) {
+	s, err := srv.store.Get(token)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	resp.created = time.Now()
-	srv.tokensLocker.Lock()
-	srv.tokens[resp.Openid] = resp // 已经存在则替换，否则添加
-	srv.tokensLocker.Unlock()
+	return Decode(srv.conf.AppID, s.SessionKey, data, iv)
+}
+
+// DecodeObject 解析令牌对应用户的加密数据至 v，同时验证其水印
+//
+// v 可以是 [PhoneInfo]、[UserInfo]、[ShareInfo] 或 [RunData] 等对象，
+// maxAge 的说明可参考 [Watermark.Validate]。
+func (srv *Server) DecodeObject(token, data, iv string, maxAge time.Duration, v interface{}) error {
+	s, err := srv.store.Get(token)
+	if err != nil {
+		return err
+	}
 
-	return resp, nil
+	return DecodeObject(srv.conf.AppID, s.SessionKey, data, iv, maxAge, v)
 }
 
-// Decode 解码
-func (srv *Server) Decode(openid, data, iv string) ([]byte, *Watermark, error) {
-	srv.tokensLocker.RLock()
-	defer srv.tokensLocker.RUnlock()
+// ServeHTTP 处理小程序的登录请求
+//
+// 小程序将 wx.login 获得的 code 以查询参数、表单或是 JSON 对象 {"code":"..."}
+// 的形式提交，成功之后以 JSON 格式返回 [LoginResponse]，
+// 失败则返回 [common.Result] 格式的错误信息。
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code, err := readCode(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &common.Result{Code: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, &common.Result{Code: http.StatusBadRequest, Message: "缺少参数 code"})
+		return
+	}
 
-	resp, found := srv.tokens[openid]
-	if !found {
-		return nil, nil, ErrWeappUnauthorization
+	token, _, err := srv.Login(code)
+	var rslt *common.Result
+	switch {
+	case errors.As(err, &rslt):
+		writeJSON(w, http.StatusUnauthorized, rslt)
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, common.NewResult(http.StatusInternalServerError))
+		return
 	}
 
-	return Decode(srv.conf.AppID, resp.SessionKey, data, iv)
+	writeJSON(w, http.StatusOK, &LoginResponse{
+		Token:     token,
+		ExpiresIn: int64(srv.expired / time.Second),
+	})
 }
 
-func (srv *Server) gc(tick time.Duration) {
-	c := time.Tick(tick)
+func readCode(r *http.Request) (string, error) {
+	if code := r.FormValue("code"); code != "" {
+		return code, nil
+	}
+
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return "", nil
+	}
 
-	for t := range c {
-		func() { // 包装在函数中，才能保证 tokensLocker 不死锁
-			srv.tokensLocker.Lock()
-			defer srv.tokensLocker.Unlock()
+	obj := &struct {
+		Code string `json:"code"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(obj); err != nil {
+		return "", err
+	}
+	return obj.Code, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
 
-			for _, token := range srv.tokens {
-				if token.created.Add(srv.expired).Before(t) {
-					delete(srv.tokens, token.Openid)
-				}
-			}
-		}()
+// 生成会话令牌，由 URL 安全的 base64 字符组成。
+func newToken() (string, error) {
+	bs := make([]byte, 24)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
 }
@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestNewServer(t *testing.T) {
+	a := assert.New(t, false)
+
+	srv := NewServer(&common.Config{AppID: "appid"}, nil, 0)
+	a.Equal(srv.expired, DefaultExpired).NotNil(srv.store)
+
+	srv = NewServer(&common.Config{AppID: "appid"}, nil, -time.Hour)
+	a.Equal(srv.expired, DefaultExpired)
+
+	srv = NewServer(&common.Config{AppID: "appid"}, nil, time.Hour)
+	a.Equal(srv.expired, time.Hour)
+}
+
+func TestServer_Decode(t *testing.T) {
+	a := assert.New(t, false)
+
+	srv := NewServer(&common.Config{AppID: "wx4f4bc4dec97d474b"}, nil, time.Hour)
+	data, watermark, err := srv.Decode("token", encryptedData, iv)
+	a.ErrorIs(err, ErrWeappUnauthorization).Nil(data).Nil(watermark)
+
+	a.NotError(srv.store.Set("token", &Session{SessionKey: sessionKey, Expires: time.Now().Add(time.Hour)}))
+	data, watermark, err = srv.Decode("token", encryptedData, iv)
+	a.NotError(err).NotNil(data).Equal(watermark.Appid, "wx4f4bc4dec97d474b")
+
+	a.NotError(srv.Logout("token"))
+	_, err = srv.Session("token")
+	a.ErrorIs(err, ErrWeappUnauthorization)
+}
+
+func TestServer_Login(t *testing.T) {
+	a := assert.New(t, false)
+	wx := wechattest.NewServer()
+	defer wx.Close()
+	wx.SetSession("jscode", &wechattest.Session{OpenID: "openid", SessionKey: sessionKey, UnionID: "unionid"})
+
+	srv := NewServer(wx.Config(), nil, time.Hour)
+	token, sess, err := srv.Login("jscode")
+	a.NotError(err).NotEmpty(token).
+		Equal(sess.OpenID, "openid").
+		Equal(sess.SessionKey, sessionKey).
+		Equal(sess.UnionID, "unionid").
+		True(sess.Expires.After(time.Now().Add(59 * time.Minute)))
+
+	s, err := srv.Session(token)
+	a.NotError(err).Equal(s, sess)
+
+	token, sess, err = srv.Login("invalid")
+	a.Equal(err.(*common.Result).Code, 40029).Empty(token).Nil(sess)
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	a := assert.New(t, false)
+	wx := wechattest.NewServer()
+	defer wx.Close()
+	wx.SetSession("jscode", &wechattest.Session{OpenID: "openid", SessionKey: sessionKey})
+	srv := NewServer(wx.Config(), nil, time.Hour)
+
+	// 查询参数
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/login?code=jscode", nil)
+	srv.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusOK)
+	resp := &LoginResponse{}
+	a.NotError(json.Unmarshal(w.Body.Bytes(), resp)).
+		NotEmpty(resp.Token).
+		Equal(resp.ExpiresIn, 3600)
+	sess, err := srv.Session(resp.Token)
+	a.NotError(err).Equal(sess.OpenID, "openid")
+
+	// JSON
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"code":"jscode"}`))
+	r.Header.Set("Content-Type", "application/json")
+	srv.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusOK)
+	resp2 := &LoginResponse{}
+	a.NotError(json.Unmarshal(w.Body.Bytes(), resp2)).
+		NotEmpty(resp2.Token).
+		NotEqual(resp2.Token, resp.Token)
+
+	// 无效的 code
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/login?code=invalid", nil)
+	srv.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusUnauthorized)
+	rslt := &common.Result{}
+	a.NotError(json.Unmarshal(w.Body.Bytes(), rslt)).Equal(rslt.Code, 40029)
+}
+
+func TestServer_ServeHTTP_badRequest(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer(&common.Config{AppID: "appid"}, nil, time.Hour)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	srv.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusBadRequest)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"code":`))
+	r.Header.Set("Content-Type", "application/json")
+	srv.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusBadRequest)
+}
+
+func TestNewToken(t *testing.T) {
+	a := assert.New(t, false)
+
+	t1, err := newToken()
+	a.NotError(err).Length(t1, 32)
+	t2, err := newToken()
+	a.NotError(err).NotEqual(t1, t2)
+}
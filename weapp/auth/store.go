@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session 登录之后保存在服务端的会话信息
+type Session struct {
+	OpenID     string    `json:"openid"`
+	SessionKey string    `json:"session_key"`
+	UnionID    string    `json:"unionid,omitempty"`
+	Expires    time.Time `json:"expires"` // 过期时间
+}
+
+// Store 会话的存储接口
+//
+// 键名为 [Server] 生成的会话令牌，而不是 openid。
+type Store interface {
+	// 获取令牌对应的会话
+	//
+	// 不存在或是已经过期，返回 [ErrWeappUnauthorization]。
+	Get(token string) (*Session, error)
+
+	// 保存会话，已经存在则替换
+	Set(token string, s *Session) error
+
+	// 删除会话，不存在时不返回错误
+	Delete(token string) error
+}
+
+// IsExpired 会话是否已经过期
+func (s *Session) IsExpired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+// MemoryStore 基于内存的 [Store] 实现
+//
+// 仅适合单个实例的部署，重启之后会话会丢失。
+type MemoryStore struct {
+	sessions map[string]*Session
+	locker   sync.RWMutex
+}
+
+// NewMemoryStore 声明 [MemoryStore] 实例
+//
+// cap 表示初始容量。
+func NewMemoryStore(cap int) *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]*Session, cap),
+	}
+}
+
+// Get 实现 [Store].Get
+func (m *MemoryStore) Get(token string) (*Session, error) {
+	m.locker.RLock()
+	s, found := m.sessions[token]
+	m.locker.RUnlock()
+
+	if !found {
+		return nil, ErrWeappUnauthorization
+	}
+
+	if s.IsExpired() {
+		m.Delete(token)
+		return nil, ErrWeappUnauthorization
+	}
+	return s, nil
+}
+
+// Set 实现 [Store].Set
+func (m *MemoryStore) Set(token string, s *Session) error {
+	m.locker.Lock()
+	m.sessions[token] = s
+	m.locker.Unlock()
+	return nil
+}
+
+// Delete 实现 [Store].Delete
+func (m *MemoryStore) Delete(token string) error {
+	m.locker.Lock()
+	delete(m.sessions, token)
+	m.locker.Unlock()
+	return nil
+}
+
+// GC 清除已经过期的会话
+//
+// 过期的会话在 Get 时也会被清除，GC 仅用于回收那些不再被访问的会话，
+// 可根据业务量自行决定调用的频率。
+func (m *MemoryStore) GC() {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	for token, s := range m.sessions {
+		if s.IsExpired() {
+			delete(m.sessions, token)
+		}
+	}
+}
+
+// FileStore 基于文件的 [Store] 实现
+//
+// 每个会话保存为目录下的一个 JSON 文件，多个实例共享同一目录时可以共享会话。
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 声明 [FileStore] 实例
+//
+// dir 为保存会话的目录，不存在时会自动创建。
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Get 实现 [Store].Get
+func (f *FileStore) Get(token string) (*Session, error) {
+	path, err := f.path(token)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrWeappUnauthorization
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := &Session{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+
+	if s.IsExpired() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, ErrWeappUnauthorization
+	}
+	return s, nil
+}
+
+// Set 实现 [Store].Set
+func (f *FileStore) Set(token string, s *Session) error {
+	path, err := f.path(token)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件再重命名，防止其它实例读取到不完整的内容。
+	// 每次写入都采用不同的临时文件，防止并发写入时相互覆盖。
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Delete 实现 [Store].Delete
+func (f *FileStore) Delete(token string) error {
+	path, err := f.path(token)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GC 清除已经过期的会话文件
+func (f *FileStore) GC() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		token := strings.TrimSuffix(e.Name(), ".json")
+		if _, err := f.Get(token); err != nil && !errors.Is(err, ErrWeappUnauthorization) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 令牌只能由字母、数字、- 和 _ 组成，防止访问到目录之外的文件。
+func (f *FileStore) path(token string) (string, error) {
+	if token == "" {
+		return "", ErrWeappUnauthorization
+	}
+
+	for _, c := range token {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+			return "", ErrWeappUnauthorization
+		}
+	}
+
+	return filepath.Join(f.dir, token+".json"), nil
+}
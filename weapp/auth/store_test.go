@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+)
+
+var (
+	_ Store = &MemoryStore{}
+	_ Store = &FileStore{}
+)
+
+func testStore(a *assert.Assertion, s Store) {
+	sess, err := s.Get("not-exists")
+	a.ErrorIs(err, ErrWeappUnauthorization).Nil(sess)
+
+	a.NotError(s.Set("token1", &Session{OpenID: "openid1", SessionKey: "key1", Expires: time.Now().Add(time.Hour)}))
+	sess, err = s.Get("token1")
+	a.NotError(err).NotNil(sess).Equal(sess.OpenID, "openid1").Equal(sess.SessionKey, "key1")
+
+	// 替换
+	a.NotError(s.Set("token1", &Session{OpenID: "openid2", Expires: time.Now().Add(time.Hour)}))
+	sess, err = s.Get("token1")
+	a.NotError(err).Equal(sess.OpenID, "openid2")
+
+	// 过期
+	a.NotError(s.Set("token2", &Session{OpenID: "openid3", Expires: time.Now().Add(-time.Second)}))
+	sess, err = s.Get("token2")
+	a.ErrorIs(err, ErrWeappUnauthorization).Nil(sess)
+
+	a.NotError(s.Delete("token1"))
+	a.NotError(s.Delete("token1")) // 多次删除
+	sess, err = s.Get("token1")
+	a.ErrorIs(err, ErrWeappUnauthorization).Nil(sess)
+}
+
+func TestMemoryStore(t *testing.T) {
+	a := assert.New(t, false)
+
+	s := NewMemoryStore(10)
+	testStore(a, s)
+
+	a.NotError(s.Set("token", &Session{Expires: time.Now().Add(-time.Second)}))
+	a.Length(s.sessions, 1)
+	s.GC()
+	a.Length(s.sessions, 0)
+}
+
+func TestFileStore(t *testing.T) {
+	a := assert.New(t, false)
+
+	s, err := NewFileStore(t.TempDir())
+	a.NotError(err).NotNil(s)
+	testStore(a, s)
+
+	// 无效的令牌
+	_, err = s.Get("../token")
+	a.ErrorIs(err, ErrWeappUnauthorization)
+	a.Error(s.Set("", &Session{}))
+
+	a.NotError(s.Set("token", &Session{Expires: time.Now().Add(-time.Second)}))
+	a.FileExists(s.dir + "/token.json")
+	a.NotError(s.GC())
+	a.FileNotExists(s.dir + "/token.json")
+}
+
+func TestFileStore_concurrent(t *testing.T) {
+	a := assert.New(t, false)
+
+	s, err := NewFileStore(t.TempDir())
+	a.NotError(err).NotNil(s)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a.NotError(s.Set("token", &Session{OpenID: strconv.Itoa(i), Expires: time.Now().Add(time.Hour)}))
+		}(i)
+	}
+	wg.Wait()
+
+	sess, err := s.Get("token")
+	a.NotError(err).NotEmpty(sess.OpenID)
+
+	entries, err := os.ReadDir(s.dir)
+	a.NotError(err).Length(entries, 1) // 不会残留临时文件
+}
@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package link 生成从微信外部打开小程序的链接
+//
+// 包括 URL Scheme、URL Link 以及短链接，适用于短信、邮件等场景。
+package link
+
+import (
+	"errors"
+	"time"
+
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+)
+
+// 要打开的小程序版本
+const (
+	EnvRelease = "release" // 正式版，默认值
+	EnvTrial   = "trial"   // 体验版
+	EnvDevelop = "develop" // 开发版
+)
+
+// 到期失效的类型
+const (
+	expireTypeTime     = 0 // 指定失效时间
+	expireTypeInterval = 1 // 指定失效天数
+)
+
+// MaxExpireIn 失效间隔的最大值
+const MaxExpireIn = 30 * 24 * time.Hour
+
+// 错误信息
+var (
+	ErrExpireConflict = errors.New("不能同时指定 ExpireAt 和 ExpireIn")
+	ErrInvalidExpire  = errors.New("无效的失效时间")
+)
+
+// Scheme 生成 URL Scheme 的参数
+//
+// ExpireAt 和 ExpireIn 最多只能指定一个，都为空表示采用微信的默认值。
+type Scheme struct {
+	Path       string        // 小程序页面路径，必须是已经发布的页面，不能带参数
+	Query      string        // 页面的查询参数
+	EnvVersion string        // 小程序的版本，Env* 系列常量
+	ExpireAt   time.Time     // 到期失效的时间
+	ExpireIn   time.Duration // 多久之后失效，以天为单位向上取整，最长 30 天
+}
+
+// URLLink 生成 URL Link 的参数
+//
+// ExpireAt 和 ExpireIn 最多只能指定一个，都为空表示采用微信的默认值。
+type URLLink struct {
+	Path       string
+	Query      string
+	EnvVersion string
+	ExpireAt   time.Time
+	ExpireIn   time.Duration
+}
+
+// Info 查询 URL Scheme 或 URL Link 返回的信息
+type Info struct {
+	AppID      string
+	Path       string
+	Query      string
+	EnvVersion string
+	Created    time.Time
+	Expires    time.Time // 为零值表示永久有效
+}
+
+// Quota 长期有效的 URL Scheme 或 URL Link 的配额
+type Quota struct {
+	LongTimeUsed  int `json:"long_time_used"`  // 已使用数量
+	LongTimeLimit int `json:"long_time_limit"` // 数量上限
+}
+
+type expire struct {
+	IsExpire       bool  `json:"is_expire,omitempty"`
+	ExpireType     int   `json:"expire_type,omitempty"`
+	ExpireTime     int64 `json:"expire_time,omitempty"`
+	ExpireInterval int   `json:"expire_interval,omitempty"`
+}
+
+type info struct {
+	AppID      string `json:"appid"`
+	Path       string `json:"path"`
+	Query      string `json:"query"`
+	EnvVersion string `json:"env_version"`
+	CreateTime int64  `json:"create_time"`
+	ExpireTime int64  `json:"expire_time"`
+}
+
+// 将 at 和 in 转换成微信的失效参数
+func newExpire(at time.Time, in time.Duration) (*expire, error) {
+	switch {
+	case !at.IsZero() && in != 0:
+		return nil, ErrExpireConflict
+	case !at.IsZero():
+		if !at.After(time.Now()) {
+			return nil, ErrInvalidExpire
+		}
+		return &expire{IsExpire: true, ExpireType: expireTypeTime, ExpireTime: at.Unix()}, nil
+	case in != 0:
+		if in < 0 || in > MaxExpireIn {
+			return nil, ErrInvalidExpire
+		}
+		days := int((in + 24*time.Hour - 1) / (24 * time.Hour))
+		return &expire{IsExpire: true, ExpireType: expireTypeInterval, ExpireInterval: days}, nil
+	default:
+		return &expire{}, nil
+	}
+}
+
+func (i *info) info() *Info {
+	ret := &Info{
+		AppID:      i.AppID,
+		Path:       i.Path,
+		Query:      i.Query,
+		EnvVersion: i.EnvVersion,
+		Created:    time.Unix(i.CreateTime, 0),
+	}
+	if i.ExpireTime > 0 {
+		ret.Expires = time.Unix(i.ExpireTime, 0)
+	}
+	return ret
+}
+
+// GenerateScheme 生成 URL Scheme
+//
+// 返回值为 weixin://dl/business/?t=xxx 格式的链接。
+func GenerateScheme(srv token.Server, s *Scheme) (string, error) {
+	e, err := newExpire(s.ExpireAt, s.ExpireIn)
+	if err != nil {
+		return "", err
+	}
+
+	type jumpWxa struct {
+		Path       string `json:"path"`
+		Query      string `json:"query"`
+		EnvVersion string `json:"env_version,omitempty"`
+	}
+	req := &struct {
+		JumpWxa *jumpWxa `json:"jump_wxa"`
+		*expire
+	}{
+		JumpWxa: &jumpWxa{Path: s.Path, Query: s.Query, EnvVersion: s.EnvVersion},
+		expire:  e,
+	}
+
+	resp := &struct {
+		OpenLink string `json:"openlink"`
+	}{}
+
+	url := token.URL(srv, "wxa/generatescheme", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return "", err
+	}
+	return resp.OpenLink, nil
+}
+
+// QueryScheme 查询 URL Scheme 的信息及配额
+func QueryScheme(srv token.Server, scheme string) (*Info, *Quota, error) {
+	req := &struct {
+		Scheme string `json:"scheme"`
+	}{
+		Scheme: scheme,
+	}
+
+	resp := &struct {
+		Info  *info  `json:"scheme_info"`
+		Quota *Quota `json:"scheme_quota"`
+	}{}
+
+	url := token.URL(srv, "wxa/queryscheme", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return nil, nil, err
+	}
+
+	if resp.Info == nil {
+		return nil, resp.Quota, nil
+	}
+	return resp.Info.info(), resp.Quota, nil
+}
+
+// GenerateURLLink 生成 URL Link
+//
+// 返回值为 https://wxaurl.cn/xxx 格式的链接。
+func GenerateURLLink(srv token.Server, l *URLLink) (string, error) {
+	e, err := newExpire(l.ExpireAt, l.ExpireIn)
+	if err != nil {
+		return "", err
+	}
+
+	req := &struct {
+		Path       string `json:"path"`
+		Query      string `json:"query"`
+		EnvVersion string `json:"env_version,omitempty"`
+		*expire
+	}{
+		Path:       l.Path,
+		Query:      l.Query,
+		EnvVersion: l.EnvVersion,
+		expire:     e,
+	}
+
+	resp := &struct {
+		URLLink string `json:"url_link"`
+	}{}
+
+	url := token.URL(srv, "wxa/generate_urllink", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return "", err
+	}
+	return resp.URLLink, nil
+}
+
+// QueryURLLink 查询 URL Link 的信息及配额
+func QueryURLLink(srv token.Server, urlLink string) (*Info, *Quota, error) {
+	req := &struct {
+		URLLink string `json:"url_link"`
+	}{
+		URLLink: urlLink,
+	}
+
+	resp := &struct {
+		Info  *info  `json:"url_link_info"`
+		Quota *Quota `json:"url_link_quota"`
+	}{}
+
+	url := token.URL(srv, "wxa/query_urllink", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return nil, nil, err
+	}
+
+	if resp.Info == nil {
+		return nil, resp.Quota, nil
+	}
+	return resp.Info.info(), resp.Quota, nil
+}
+
+// GenerateShortLink 生成短链接
+//
+// pageURL 为页面路径，可带参数；title 为页面标题；
+// permanent 表示是否为永久有效的链接，永久链接有数量上限。
+// 返回值为 #小程序://名称/xxx 格式的链接，仅能在微信内打开。
+func GenerateShortLink(srv token.Server, pageURL, title string, permanent bool) (string, error) {
+	req := &struct {
+		PageURL     string `json:"page_url"`
+		PageTitle   string `json:"page_title,omitempty"`
+		IsPermanent bool   `json:"is_permanent"`
+	}{
+		PageURL:     pageURL,
+		PageTitle:   title,
+		IsPermanent: permanent,
+	}
+
+	resp := &struct {
+		Link string `json:"link"`
+	}{}
+
+	url := token.URL(srv, "wxa/genwxashortlink", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return "", err
+	}
+	return resp.Link, nil
+}
@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package link
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestNewExpire(t *testing.T) {
+	a := assert.New(t, false)
+
+	e, err := newExpire(time.Time{}, 0)
+	a.NotError(err).False(e.IsExpire)
+
+	at := time.Now().Add(time.Hour)
+	e, err = newExpire(at, 0)
+	a.NotError(err).
+		True(e.IsExpire).
+		Equal(e.ExpireType, expireTypeTime).
+		Equal(e.ExpireTime, at.Unix())
+
+	e, err = newExpire(time.Time{}, 36*time.Hour)
+	a.NotError(err).
+		True(e.IsExpire).
+		Equal(e.ExpireType, expireTypeInterval).
+		Equal(e.ExpireInterval, 2)
+
+	e, err = newExpire(time.Time{}, MaxExpireIn)
+	a.NotError(err).Equal(e.ExpireInterval, 30)
+
+	e, err = newExpire(at, time.Hour)
+	a.ErrorIs(err, ErrExpireConflict).Nil(e)
+
+	e, err = newExpire(time.Now().Add(-time.Hour), 0)
+	a.ErrorIs(err, ErrInvalidExpire).Nil(e)
+
+	e, err = newExpire(time.Time{}, MaxExpireIn+time.Hour)
+	a.ErrorIs(err, ErrInvalidExpire).Nil(e)
+}
+
+func TestInfo_info(t *testing.T) {
+	a := assert.New(t, false)
+
+	i := &info{AppID: "appid", CreateTime: 1611243484, ExpireTime: 0}
+	ret := i.info()
+	a.Equal(ret.AppID, "appid").
+		Equal(ret.Created.Unix(), 1611243484).
+		True(ret.Expires.IsZero())
+
+	i.ExpireTime = 1611333484
+	a.Equal(i.info().Expires.Unix(), 1611333484)
+}
+
+func TestGenerateScheme(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxa/generatescheme", func(*http.Request) interface{} {
+		return map[string]interface{}{"errcode": 0, "openlink": "weixin://dl/business/?t=XTSkBZlzqmn"}
+	})
+
+	link, err := GenerateScheme(tksrv, &Scheme{Path: "pages/index", Query: "a=1", EnvVersion: EnvTrial, ExpireIn: 36 * time.Hour})
+	a.NotError(err).Equal(link, "weixin://dl/business/?t=XTSkBZlzqmn")
+
+	at := time.Now().Add(time.Hour)
+	link, err = GenerateScheme(tksrv, &Scheme{Path: "pages/index", ExpireAt: at})
+	a.NotError(err).NotEmpty(link)
+
+	link, err = GenerateScheme(tksrv, &Scheme{Path: "pages/index"})
+	a.NotError(err).NotEmpty(link)
+
+	msgs := srv.Messages()
+	a.Length(msgs, 3).
+		Equal(string(msgs[0].Body), `{"jump_wxa":{"path":"pages/index","query":"a=1","env_version":"trial"},"is_expire":true,"expire_type":1,"expire_interval":2}`).
+		Equal(string(msgs[1].Body), `{"jump_wxa":{"path":"pages/index","query":""},"is_expire":true,"expire_time":`+strconv.FormatInt(at.Unix(), 10)+`}`).
+		Equal(string(msgs[2].Body), `{"jump_wxa":{"path":"pages/index","query":""}}`)
+
+	// 参数错误，不会发送请求
+	link, err = GenerateScheme(tksrv, &Scheme{ExpireAt: at, ExpireIn: time.Hour})
+	a.ErrorIs(err, ErrExpireConflict).Empty(link)
+	a.Length(srv.Messages(), 3)
+
+	srv.InjectError(85401)
+	link, err = GenerateScheme(tksrv, &Scheme{Path: "pages/index"})
+	a.Equal(err.(*common.Result).Code, 85401).Empty(link)
+}
+
+func TestQueryScheme(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxa/queryscheme", func(*http.Request) interface{} {
+		return map[string]interface{}{
+			"errcode": 0,
+			"scheme_info": map[string]interface{}{
+				"appid":       "appid",
+				"path":        "pages/index",
+				"query":       "a=1",
+				"create_time": 1611243484,
+				"expire_time": 0,
+				"env_version": "release",
+			},
+			"scheme_quota": map[string]interface{}{"long_time_used": 100, "long_time_limit": 100000},
+		}
+	})
+
+	info, quota, err := QueryScheme(tksrv, "weixin://dl/business/?t=XTSkBZlzqmn")
+	a.NotError(err).
+		Equal(info.AppID, "appid").
+		Equal(info.Query, "a=1").
+		Equal(info.EnvVersion, EnvRelease).
+		Equal(info.Created.Unix(), 1611243484).
+		True(info.Expires.IsZero()).
+		Equal(quota, &Quota{LongTimeUsed: 100, LongTimeLimit: 100000})
+	a.Equal(string(srv.Messages()[0].Body), `{"scheme":"weixin://dl/business/?t=XTSkBZlzqmn"}`)
+}
+
+func TestGenerateURLLink(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxa/generate_urllink", func(*http.Request) interface{} {
+		return map[string]interface{}{"errcode": 0, "url_link": "https://wxaurl.cn/BQZRrcFCPvg"}
+	})
+
+	link, err := GenerateURLLink(tksrv, &URLLink{Path: "pages/index", Query: "a=1", ExpireIn: 24 * time.Hour})
+	a.NotError(err).Equal(link, "https://wxaurl.cn/BQZRrcFCPvg")
+	msgs := srv.Messages()
+	a.Length(msgs, 1).
+		Equal(string(msgs[0].Body), `{"path":"pages/index","query":"a=1","is_expire":true,"expire_type":1,"expire_interval":1}`)
+
+	link, err = GenerateURLLink(tksrv, &URLLink{ExpireIn: MaxExpireIn + time.Hour})
+	a.ErrorIs(err, ErrInvalidExpire).Empty(link)
+}
+
+func TestQueryURLLink(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxa/query_urllink", func(*http.Request) interface{} {
+		return map[string]interface{}{
+			"errcode": 0,
+			"url_link_info": map[string]interface{}{
+				"appid":       "appid",
+				"path":        "pages/index",
+				"create_time": 1611243484,
+				"expire_time": 1611333484,
+				"env_version": "trial",
+			},
+			"url_link_quota": map[string]interface{}{"long_time_used": 1, "long_time_limit": 100000},
+		}
+	})
+
+	info, quota, err := QueryURLLink(tksrv, "https://wxaurl.cn/BQZRrcFCPvg")
+	a.NotError(err).
+		Equal(info.Path, "pages/index").
+		Equal(info.EnvVersion, EnvTrial).
+		Equal(info.Expires.Unix(), 1611333484).
+		Equal(quota.LongTimeUsed, 1)
+	a.Equal(string(srv.Messages()[0].Body), `{"url_link":"https://wxaurl.cn/BQZRrcFCPvg"}`)
+
+	// 不存在 url_link_info
+	srv2 := wechattest.NewServer()
+	defer srv2.Close()
+	tksrv2 := token.NewDefaultServer(srv2.Config(), nil)
+	srv2.Handle("/wxa/query_urllink", func(*http.Request) interface{} {
+		return map[string]interface{}{"errcode": 0, "url_link_quota": map[string]interface{}{"long_time_used": 1}}
+	})
+	info, quota, err = QueryURLLink(tksrv2, "https://wxaurl.cn/BQZRrcFCPvg")
+	a.NotError(err).Nil(info).Equal(quota.LongTimeUsed, 1)
+}
+
+func TestGenerateShortLink(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxa/genwxashortlink", func(*http.Request) interface{} {
+		return map[string]interface{}{"errcode": 0, "link": "#小程序://示例/XNy3R3nF2mWgaUi"}
+	})
+
+	link, err := GenerateShortLink(tksrv, "pages/index?a=1", "title", true)
+	a.NotError(err).Equal(link, "#小程序://示例/XNy3R3nF2mWgaUi")
+
+	link, err = GenerateShortLink(tksrv, "pages/index", "", false)
+	a.NotError(err).NotEmpty(link)
+
+	msgs := srv.Messages()
+	a.Length(msgs, 2).
+		Equal(string(msgs[0].Body), `{"page_url":"pages/index?a=1","page_title":"title","is_permanent":true}`).
+		Equal(string(msgs[1].Body), `{"page_url":"pages/index","is_permanent":false}`)
+}
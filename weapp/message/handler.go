@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+// ReplySuccess 成功返回的内容
+var ReplySuccess = []byte("success")
+
+// Handler 消息处理函数
+//
+// 返回值会原样输出给微信，若返回 nil，则输出 [ReplySuccess]。
+// 小程序不支持被动回复消息，需要回复用户时，应该调用 [SendText] 等客服消息接口。
+//
+// NOTE: 所有的 Handler 必须在 5 秒内有返回数据，否则微信端会再次发起同样的请求。
+type Handler func(Messager) ([]byte, error)
+
+// HandlerBus 为 Handler 的管理器，方便用户按类别来注册消息处理。
+//
+//	h := NewHandlerBus()
+//	h.RegisterMessage(TypeText, h1)
+//	h.RegisterEvent(EventTypeUserEnterTempSession, h2)
+//	srv := NewServer("token", nil, h.Handler, nil)
+type HandlerBus struct {
+	messageHandlers map[string]Handler
+	eventHandlers   map[string]Handler
+}
+
+// NewHandlerBus 声明一个新的 HandlerBus
+func NewHandlerBus() *HandlerBus {
+	return &HandlerBus{
+		messageHandlers: make(map[string]Handler, 3),
+		eventHandlers:   make(map[string]Handler, 5),
+	}
+}
+
+// RegisterMessage 注册消息处理函数
+//
+// typ 的值若为 event，可以注册，但不会实际有作用。
+func (b *HandlerBus) RegisterMessage(typ string, h Handler) {
+	b.messageHandlers[typ] = h
+}
+
+// RegisterEvent 注册事件处理函数
+func (b *HandlerBus) RegisterEvent(event string, h Handler) {
+	b.eventHandlers[event] = h
+}
+
+// Handler 实现 [Handler]
+//
+// 未注册处理函数的消息和事件，直接返回 [ReplySuccess]。
+func (b *HandlerBus) Handler(m Messager) ([]byte, error) {
+	var h Handler
+	var found bool
+
+	if e, ok := m.(Eventer); ok && m.Type() == TypeEvent {
+		h, found = b.eventHandlers[e.EventType()]
+	} else {
+		h, found = b.messageHandlers[m.Type()]
+	}
+
+	if !found {
+		return ReplySuccess, nil
+	}
+	return h(m)
+}
@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestHandlerBus(t *testing.T) {
+	a := assert.New(t, false)
+
+	b := NewHandlerBus()
+	b.RegisterMessage(TypeText, func(Messager) ([]byte, error) { return []byte("text"), nil })
+	b.RegisterEvent(EventTypeUserEnterTempSession, func(Messager) ([]byte, error) { return []byte("enter"), nil })
+
+	data, err := b.Handler(&Text{message: message{base: base{MsgType: TypeText}}})
+	a.NotError(err).Equal(string(data), "text")
+
+	e := &EventUserEnterTempSession{Event: Event{base: base{MsgType: TypeEvent}, Event: EventTypeUserEnterTempSession}}
+	data, err = b.Handler(e)
+	a.NotError(err).Equal(string(data), "enter")
+
+	// 未注册
+	data, err = b.Handler(&Image{message: message{base: base{MsgType: TypeImage}}})
+	a.NotError(err).Equal(data, ReplySuccess)
+}
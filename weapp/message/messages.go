@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package message 小程序的消息推送服务
+//
+// 与公众号的 [mp/message] 不同，小程序推送的内容可以是 XML 或是 JSON 格式，
+// 且回复只能通过客服消息接口发送。
+//
+// [mp/message]: https://pkg.go.dev/github.com/issue9/wechat/mp/message
+package message
+
+import (
+	"github.com/issue9/wechat/internal"
+)
+
+// 消息类型
+const (
+	TypeText            = "text"
+	TypeImage           = "image"
+	TypeMiniprogramPage = "miniprogrampage"
+	TypeEvent           = "event"
+)
+
+// EventTypeUserEnterTempSession 用户进入客服会话的事件
+const EventTypeUserEnterTempSession = "user_enter_tempsession"
+
+// Messager 表示消息和事件的基本结构
+type Messager interface {
+	// 消息类型，对应 MsgType 字段
+	Type() string
+
+	// 小程序的原始 ID，对应 ToUserName 字段
+	To() string
+
+	// 发送方的 openid，对应 FromUserName 字段
+	From() string
+
+	// 创建时间，对应 CreateTime 字段
+	Created() int64
+}
+
+// Message 表示消息的基本结构，不包含事件
+type Message interface {
+	Messager
+
+	// 表示消息的 ID
+	ID() int64
+}
+
+// Eventer 事件接口
+type Eventer interface {
+	Messager
+
+	// 事件类型，对应 Event 字段
+	EventType() string
+
+	// 解密之后的原始内容，格式为 XML 或是 JSON
+	//
+	// 对于未定义具体类型的事件，比如订阅消息的 subscribe_msg_popup_event，
+	// 可以将该值传递给 subscribe.Parse 等函数作进一步的解析。
+	Data() []byte
+}
+
+type base struct {
+	ToUserName   string `xml:"ToUserName" json:"ToUserName"`
+	FromUserName string `xml:"FromUserName" json:"FromUserName"`
+	CreateTime   int64  `xml:"CreateTime" json:"CreateTime"`
+	MsgType      string `xml:"MsgType" json:"MsgType"`
+}
+
+type message struct {
+	base
+	MsgID int64 `xml:"MsgId" json:"MsgId"`
+}
+
+// Text 文本消息
+type Text struct {
+	message
+	Content string `xml:"Content" json:"Content"`
+}
+
+// Image 图片消息
+type Image struct {
+	message
+	PicURL  string `xml:"PicUrl" json:"PicUrl"`
+	MediaID string `xml:"MediaId" json:"MediaId"`
+}
+
+// MiniprogramPage 小程序卡片消息
+type MiniprogramPage struct {
+	message
+	Title        string `xml:"Title" json:"Title"`
+	AppID        string `xml:"AppId" json:"AppId"`
+	PagePath     string `xml:"PagePath" json:"PagePath"`
+	ThumbURL     string `xml:"ThumbUrl" json:"ThumbUrl"`
+	ThumbMediaID string `xml:"ThumbMediaId" json:"ThumbMediaId"`
+}
+
+// Event 事件的基本结构
+//
+// 未定义具体类型的事件都以此类型表示。
+type Event struct {
+	base
+	Event string `xml:"Event" json:"Event"`
+	data  []byte
+}
+
+// EventUserEnterTempSession 用户进入客服会话的事件
+type EventUserEnterTempSession struct {
+	Event
+	SessionFrom string `xml:"SessionFrom" json:"SessionFrom"` // 客服按钮的 session-from 属性
+}
+
+func (b *base) To() string { return b.ToUserName }
+
+func (b *base) From() string { return b.FromUserName }
+
+func (b *base) Created() int64 { return b.CreateTime }
+
+func (b *base) Type() string { return b.MsgType }
+
+func (m *message) ID() int64 { return m.MsgID }
+
+func (e *Event) EventType() string { return e.Event }
+
+func (e *Event) Data() []byte { return e.data }
+
+// 将 data 转换成相应的消息或事件对象
+func getMessageObj(data []byte) (Messager, error) {
+	obj := &Event{}
+	if err := internal.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+
+	var m Messager
+	switch obj.MsgType {
+	case TypeText:
+		m = &Text{}
+	case TypeImage:
+		m = &Image{}
+	case TypeMiniprogramPage:
+		m = &MiniprogramPage{}
+	case TypeEvent:
+		return getEventObj(data, obj)
+	default:
+		return obj, nil
+	}
+
+	if err := internal.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func getEventObj(data []byte, e *Event) (Eventer, error) {
+	e.data = data
+
+	switch e.Event {
+	case EventTypeUserEnterTempSession:
+		obj := &EventUserEnterTempSession{}
+		if err := internal.Unmarshal(data, obj); err != nil {
+			return nil, err
+		}
+		obj.data = data
+		return obj, nil
+	default:
+		return e, nil
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+var (
+	_ Message = &Text{}
+	_ Message = &Image{}
+	_ Message = &MiniprogramPage{}
+	_ Eventer = &Event{}
+	_ Eventer = &EventUserEnterTempSession{}
+)
+
+func TestGetMessageObj(t *testing.T) {
+	a := assert.New(t, false)
+
+	// XML
+	data := []byte(`<xml>
+	<ToUserName><![CDATA[toUser]]></ToUserName>
+	<FromUserName><![CDATA[fromUser]]></FromUserName>
+	<CreateTime>1482048670</CreateTime>
+	<MsgType><![CDATA[text]]></MsgType>
+	<Content><![CDATA[this is a test]]></Content>
+	<MsgId>1234567890123456</MsgId>
+	</xml>`)
+	msg, err := getMessageObj(data)
+	a.NotError(err)
+	text, ok := msg.(*Text)
+	a.True(ok).
+		Equal(text.Content, "this is a test").
+		Equal(text.ID(), 1234567890123456).
+		Equal(text.From(), "fromUser")
+
+	// JSON
+	data = []byte(`{
+	"ToUserName": "toUser",
+	"FromUserName": "fromUser",
+	"CreateTime": 1482048670,
+	"MsgType": "miniprogrampage",
+	"MsgId": 1234567890123456,
+	"Title": "title",
+	"AppId": "appid",
+	"PagePath": "path",
+	"ThumbUrl": "",
+	"ThumbMediaId": ""
+	}`)
+	msg, err = getMessageObj(data)
+	a.NotError(err)
+	page, ok := msg.(*MiniprogramPage)
+	a.True(ok).Equal(page.AppID, "appid").Equal(page.PagePath, "path")
+
+	// 进入会话事件
+	data = []byte(`{
+	"ToUserName": "toUser",
+	"FromUserName": "fromUser",
+	"CreateTime": 1482048670,
+	"MsgType": "event",
+	"Event": "user_enter_tempsession",
+	"SessionFrom": "sessionFrom"
+	}`)
+	msg, err = getMessageObj(data)
+	a.NotError(err)
+	enter, ok := msg.(*EventUserEnterTempSession)
+	a.True(ok).
+		Equal(enter.SessionFrom, "sessionFrom").
+		Equal(enter.EventType(), EventTypeUserEnterTempSession).
+		Equal(string(enter.Data()), string(data))
+
+	// 未定义的事件
+	data = []byte(`<xml>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[subscribe_msg_popup_event]]></Event>
+	</xml>`)
+	msg, err = getMessageObj(data)
+	a.NotError(err)
+	e, ok := msg.(*Event)
+	a.True(ok).
+		Equal(e.EventType(), "subscribe_msg_popup_event").
+		Equal(string(e.Data()), string(data))
+
+	// 格式错误
+	msg, err = getMessageObj([]byte(`{"MsgType":`))
+	a.Error(err).Nil(msg)
+}
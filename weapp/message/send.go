@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+)
+
+// 客服输入状态的命令
+const (
+	commandTyping       = "Typing"
+	commandCancelTyping = "CancelTyping"
+)
+
+// CustomLink 客服消息中的图文链接
+type CustomLink struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	ThumbURL    string `json:"thumb_url"`
+}
+
+// CustomPage 客服消息中的小程序卡片
+type CustomPage struct {
+	Title        string `json:"title"`
+	PagePath     string `json:"pagepath"`
+	ThumbMediaID string `json:"thumb_media_id"`
+}
+
+// Send 发送客服消息
+//
+// typ 为消息类型，content 为对应类型的消息内容，
+// 一般情况下直接使用 [SendText] 等函数即可。
+func Send(srv token.Server, to, typ string, content interface{}) error {
+	obj := map[string]interface{}{
+		"touser":  to,
+		"msgtype": typ,
+		typ:       content,
+	}
+
+	url := token.URL(srv, "cgi-bin/message/custom/send", nil)
+	return internal.PostJSON(url, obj, nil)
+}
+
+// SendText 发送文本客服消息
+func SendText(srv token.Server, to, content string) error {
+	return Send(srv, to, TypeText, map[string]string{"content": content})
+}
+
+// SendImage 发送图片客服消息
+func SendImage(srv token.Server, to, mediaID string) error {
+	return Send(srv, to, TypeImage, map[string]string{"media_id": mediaID})
+}
+
+// SendLink 发送图文链接客服消息
+func SendLink(srv token.Server, to string, l *CustomLink) error {
+	return Send(srv, to, "link", l)
+}
+
+// SendMiniprogramPage 发送小程序卡片客服消息
+func SendMiniprogramPage(srv token.Server, to string, p *CustomPage) error {
+	return Send(srv, to, TypeMiniprogramPage, p)
+}
+
+// SetTyping 设置客服的输入状态
+//
+// typing 为 true 表示正在输入，false 表示取消输入状态。
+func SetTyping(srv token.Server, to string, typing bool) error {
+	cmd := commandCancelTyping
+	if typing {
+		cmd = commandTyping
+	}
+
+	obj := map[string]string{
+		"touser":  to,
+		"command": cmd,
+	}
+
+	url := token.URL(srv, "cgi-bin/message/custom/typing", nil)
+	return internal.PostJSON(url, obj, nil)
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/open/crypto"
+)
+
+const encryptTypeAES = "aes"
+
+var (
+	errCryptoNotSet        = errors.New("未指定 Crypto，无法处理安全模式的消息")
+	errInvalidMsgSignature = errors.New("无效的 msg_signature")
+)
+
+// Server 小程序的消息推送服务
+type Server struct {
+	token   string
+	crypto  *crypto.Crypto
+	handler Handler
+	errlog  *log.Logger
+}
+
+// NewServer 声明一个新的消息推送服务
+//
+// token 为后台配置的令牌；
+// c 用于安全模式下的解密，若为空，则只能处理明文模式的消息；
+// 若将 h 参数指定为 nil，则所有的消息都仅返回 [ReplySuccess]；
+// 若将 errlog 指定为 nil，则会将错误信息输出到 stderr 中。
+func NewServer(token string, c *crypto.Crypto, h Handler, errlog *log.Logger) *Server {
+	if errlog == nil {
+		errlog = log.New(os.Stderr, "", log.Lshortfile|log.Ltime)
+	}
+
+	if h == nil {
+		h = func(Messager) ([]byte, error) { return ReplySuccess, nil }
+	}
+
+	return &Server{
+		token:   token,
+		crypto:  c,
+		handler: h,
+		errlog:  errlog,
+	}
+}
+
+// ServeHTTP 根据请求方法调用 [Server.Signature] 或是 [Server.Message]
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.Signature(w, r)
+	case http.MethodPost:
+		s.Message(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Signature 验证签名，GET 方法
+func (s *Server) Signature(w http.ResponseWriter, r *http.Request) {
+	if !s.verify(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	w.Write([]byte(r.FormValue("echostr")))
+}
+
+// Message 消息处理，POST 方法
+func (s *Server) Message(w http.ResponseWriter, r *http.Request) {
+	if !s.verify(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	data, err := s.read(r)
+	if err != nil {
+		s.errlog.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	obj, err := getMessageObj(data)
+	if err != nil {
+		s.errlog.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bs, err := s.handler(obj)
+	if err != nil {
+		s.errlog.Println(err)
+		return
+	}
+
+	if bs == nil {
+		bs = ReplySuccess
+	}
+	w.Write(bs)
+}
+
+func (s *Server) verify(r *http.Request) bool {
+	return r.FormValue("signature") == sign(s.token, r.FormValue("timestamp"), r.FormValue("nonce"))
+}
+
+// 读取内容，如果是安全模式，返回的是解密之后的内容。
+func (s *Server) read(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.FormValue("encrypt_type") != encryptTypeAES {
+		return data, nil
+	}
+
+	if s.crypto == nil {
+		return nil, errCryptoNotSet
+	}
+
+	// msg_signature 包含了 Encrypt 的内容，用于验证消息体的完整性。
+	env := &struct {
+		Encrypt string `xml:"Encrypt" json:"Encrypt"`
+	}{}
+	if err = internal.Unmarshal(data, env); err != nil {
+		return nil, err
+	}
+	timestamp := r.FormValue("timestamp")
+	nonce := r.FormValue("nonce")
+	if r.FormValue("msg_signature") != sign(s.token, timestamp, nonce, env.Encrypt) {
+		return nil, errInvalidMsgSignature
+	}
+
+	sign := r.FormValue("signature")
+	if internal.IsXML(data) {
+		return s.crypto.Decrypt(data, sign, timestamp, nonce)
+	}
+	return s.crypto.DecryptJSON(data, sign, timestamp, nonce)
+}
+
+// sign 微信接口地址验证方法
+//
+// 安全模式下的 msg_signature 还需要加上 Encrypt 的内容。
+func sign(strs ...string) string {
+	sort.Strings(strs)
+
+	hash := sha1.Sum([]byte(strings.Join(strs, "")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package message
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert/v4"
+	"github.com/issue9/rands/v2"
+
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/wechattest"
+)
+
+const (
+	testToken = "token"
+	testAppID = "wx123458de9ae3rdew"
+)
+
+func newRequest(method, encryptType string, body []byte) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "nonce"
+
+	q := url.Values{}
+	q.Set("signature", sign(testToken, timestamp, nonce))
+	q.Set("timestamp", timestamp)
+	q.Set("nonce", nonce)
+	q.Set("echostr", "echo")
+	if encryptType != "" {
+		q.Set("encrypt_type", encryptType)
+	}
+
+	return httptest.NewRequest(method, "/message?"+q.Encode(), bytes.NewReader(body))
+}
+
+func TestServer_Signature(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer(testToken, nil, nil, nil)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodGet, "", nil))
+	a.Equal(w.Code, http.StatusOK).Equal(w.Body.String(), "echo")
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/message?signature=1&echostr=echo", nil))
+	a.Equal(w.Code, http.StatusForbidden).Empty(w.Body.String())
+}
+
+func TestServer_Message(t *testing.T) {
+	a := assert.New(t, false)
+
+	var content string
+	b := NewHandlerBus()
+	b.RegisterMessage(TypeText, func(m Messager) ([]byte, error) {
+		content = m.(*Text).Content
+		return nil, nil
+	})
+
+	c, err := crypto.New(testAppID, testToken, rands.String(43, 44, rands.AlphaNumber()))
+	a.NotError(err)
+	srv := NewServer(testToken, c, b.Handler, nil)
+
+	// 明文 JSON
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodPost, "", []byte(`{"MsgType":"text","Content":"json"}`)))
+	a.Equal(w.Code, http.StatusOK).
+		Equal(w.Body.Bytes(), ReplySuccess).
+		Equal(content, "json")
+
+	// 安全模式 XML
+	cb := wechattest.NewCallback(srv, testToken, c)
+	reply, err := cb.Post([]byte(`<xml><MsgType>text</MsgType><Content>xml</Content></xml>`))
+	a.NotError(err).
+		Equal(reply.Status, http.StatusOK).
+		Equal(reply.Body, ReplySuccess).
+		Equal(content, "xml")
+
+	// 安全模式 JSON
+	reply, err = cb.Post([]byte(`{"MsgType":"text","Content":"aes json"}`))
+	a.NotError(err).Equal(reply.Status, http.StatusOK).Equal(content, "aes json")
+
+	// msg_signature 与 Encrypt 不匹配
+	content = ""
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body, _, err := c.Encrypt([]byte(`<xml><MsgType>text</MsgType><Content>forged</Content></xml>`), timestamp, "nonce")
+	a.NotError(err)
+	r := newRequest(http.MethodPost, "aes", body)
+	q := r.URL.Query()
+	q.Set("msg_signature", sign(testToken, q.Get("timestamp"), q.Get("nonce"), "other"))
+	r.URL.RawQuery = q.Encode()
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	a.Equal(w.Code, http.StatusBadRequest).Empty(content)
+
+	// 缺少 msg_signature
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodPost, "aes", body))
+	a.Equal(w.Code, http.StatusBadRequest).Empty(content)
+
+	// 未指定 Crypto
+	srv = NewServer(testToken, nil, b.Handler, nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, newRequest(http.MethodPost, "aes", body))
+	a.Equal(w.Code, http.StatusBadRequest)
+}
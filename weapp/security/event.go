@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package security
+
+import (
+	"fmt"
+
+	"github.com/issue9/wechat/internal"
+)
+
+// EventTypeMediaCheck 异步检测结果的事件类型
+const EventTypeMediaCheck = "wxa_media_check"
+
+// MediaCheckEvent 异步检测结果的事件
+type MediaCheckEvent struct {
+	ToUserName   string    `xml:"ToUserName" json:"ToUserName"`
+	FromUserName string    `xml:"FromUserName" json:"FromUserName"`
+	CreateTime   int64     `xml:"CreateTime" json:"CreateTime"`
+	MsgType      string    `xml:"MsgType" json:"MsgType"`
+	Event        string    `xml:"Event" json:"Event"`
+	AppID        string    `xml:"appid" json:"appid"`
+	TraceID      string    `xml:"trace_id" json:"trace_id"`
+	Version      int       `xml:"version" json:"version"`
+	Detail       []*Detail `xml:"detail" json:"detail"`
+	ErrCode      int       `xml:"errcode" json:"errcode"`
+	ErrMsg       string    `xml:"errmsg" json:"errmsg"`
+	Result       *Result   `xml:"result" json:"result"`
+}
+
+// ParseMediaCheckEvent 解析 wxa_media_check 事件
+//
+// data 可以是 XML 或是 JSON 格式的内容，如果是安全模式，需要先解密。
+func ParseMediaCheckEvent(data []byte) (*MediaCheckEvent, error) {
+	e := &MediaCheckEvent{}
+	if err := internal.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+
+	if e.Event != EventTypeMediaCheck {
+		return nil, fmt.Errorf("无效的事件类型 %s", e.Event)
+	}
+	return e, nil
+}
+
+// EventType 事件类型
+func (e *MediaCheckEvent) EventType() string { return e.Event }
+
+// IsOK 检测是否正常完成
+//
+// 仅表示检测过程是否正常，内容是否违规需要查看 Result 字段。
+func (e *MediaCheckEvent) IsOK() bool { return e.ErrCode == 0 }
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package security
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+func TestParseMediaCheckEvent(t *testing.T) {
+	a := assert.New(t, false)
+
+	data := []byte(`{
+	"ToUserName": "gh_38cc49f9733b",
+	"FromUserName": "oH1fu0FdHqpToe2T6gBj0WyB8iS1",
+	"CreateTime": 1626959646,
+	"MsgType": "event",
+	"Event": "wxa_media_check",
+	"appid": "wx8f16a5e2ffcf1d71",
+	"trace_id": "60f96f1d-3845297a-1976a3ae",
+	"version": 2,
+	"detail": [{
+		"strategy": "content_model",
+		"errcode": 0,
+		"suggest": "risky",
+		"label": 20002,
+		"prob": 90
+	}],
+	"errcode": 0,
+	"errmsg": "ok",
+	"result": {
+		"suggest": "risky",
+		"label": 20002
+	}
+	}`)
+	e, err := ParseMediaCheckEvent(data)
+	a.NotError(err).NotNil(e).
+		True(e.IsOK()).
+		True(e.Result.IsRisky()).
+		Equal(e.Result.Label, LabelPorn).
+		Length(e.Detail, 1).
+		Equal(e.Detail[0].Prob, 90.0).
+		Equal(e.TraceID, "60f96f1d-3845297a-1976a3ae")
+
+	data = []byte(`<xml>
+	<ToUserName><![CDATA[gh_38cc49f9733b]]></ToUserName>
+	<FromUserName><![CDATA[oH1fu0FdHqpToe2T6gBj0WyB8iS1]]></FromUserName>
+	<CreateTime>1626959646</CreateTime>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[wxa_media_check]]></Event>
+	<appid><![CDATA[wx8f16a5e2ffcf1d71]]></appid>
+	<trace_id><![CDATA[60f96f1d-3845297a-1976a3ae]]></trace_id>
+	<version>2</version>
+	<detail>
+		<strategy><![CDATA[content_model]]></strategy>
+		<errcode>0</errcode>
+		<suggest><![CDATA[pass]]></suggest>
+		<label>100</label>
+		<prob>90</prob>
+	</detail>
+	<errcode>0</errcode>
+	<errmsg><![CDATA[ok]]></errmsg>
+	<result>
+		<suggest><![CDATA[pass]]></suggest>
+		<label>100</label>
+	</result>
+	</xml>`)
+	e, err = ParseMediaCheckEvent(data)
+	a.NotError(err).NotNil(e).
+		True(e.Result.IsPass()).
+		Length(e.Detail, 1).
+		Equal(e.Detail[0].Strategy, "content_model")
+
+	// 其它事件
+	e, err = ParseMediaCheckEvent([]byte(`{"MsgType":"event","Event":"subscribe_msg_sent_event"}`))
+	a.Error(err).Nil(e)
+}
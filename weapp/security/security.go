@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package security 小程序的内容安全与风险控制
+package security
+
+import (
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+)
+
+// 检测建议，即 suggest 字段的值
+const (
+	SuggestPass   = "pass"   // 通过
+	SuggestReview = "review" // 建议人工审核
+	SuggestRisky  = "risky"  // 违规
+)
+
+// 命中的标签，即 label 字段的值
+const (
+	LabelNormal    = 100   // 正常
+	LabelAd        = 10001 // 广告
+	LabelPolitics  = 20001 // 时政
+	LabelPorn      = 20002 // 色情
+	LabelAbuse     = 20003 // 辱骂
+	LabelIllegal   = 20006 // 违法犯罪
+	LabelFraud     = 20008 // 欺诈
+	LabelVulgar    = 20012 // 低俗
+	LabelCopyright = 20013 // 版权
+	LabelOther     = 21000 // 其它
+)
+
+// 检测的场景值
+const (
+	SceneProfile = 1 // 资料
+	SceneComment = 2 // 评论
+	SceneForum   = 3 // 论坛
+	SceneSocial  = 4 // 社交日志
+)
+
+// 媒体的类型
+const (
+	MediaTypeAudio = 1
+	MediaTypeImage = 2
+)
+
+const version = 2
+
+// Result 综合的检测结果
+type Result struct {
+	Suggest string `json:"suggest" xml:"suggest"` // Suggest* 系列常量
+	Label   int    `json:"label" xml:"label"`     // Label* 系列常量
+}
+
+// Detail 单个检测策略的结果
+type Detail struct {
+	Strategy string  `json:"strategy" xml:"strategy"` // 策略类型
+	ErrCode  int     `json:"errcode" xml:"errcode"`   // 错误码，仅当其值为 0 时，该项结果有效
+	Suggest  string  `json:"suggest" xml:"suggest"`
+	Label    int     `json:"label" xml:"label"`
+	Keyword  string  `json:"keyword,omitempty" xml:"keyword,omitempty"` // 命中的自定义关键词
+	Prob     float64 `json:"prob,omitempty" xml:"prob,omitempty"`       // 置信度，0-100
+	Level    int     `json:"level,omitempty" xml:"level,omitempty"`
+}
+
+// Text 待检测的文本内容
+type Text struct {
+	Content   string `json:"content"`
+	Scene     int    `json:"scene"`  // Scene* 系列常量
+	OpenID    string `json:"openid"` // 用户需在近两小时访问过小程序
+	Title     string `json:"title,omitempty"`
+	Nickname  string `json:"nickname,omitempty"`
+	Signature string `json:"signature,omitempty"` // 个性签名，仅在资料类场景有效
+}
+
+// TextResult 文本的检测结果
+type TextResult struct {
+	TraceID string    `json:"trace_id"`
+	Result  *Result   `json:"result"`
+	Detail  []*Detail `json:"detail"`
+}
+
+// RiskRank 用户风险等级的查询参数
+type RiskRank struct {
+	AppID        string `json:"appid"` // 为空表示采用 token.Server.Config 中的 AppID
+	OpenID       string `json:"openid"`
+	Scene        int    `json:"scene"` // 0 表示注册，1 表示营销作弊
+	MobileNo     string `json:"mobile_no,omitempty"`
+	ClientIP     string `json:"client_ip"`
+	EmailAddress string `json:"email_address,omitempty"`
+	ExtendedInfo string `json:"extended_info,omitempty"`
+	IsTest       bool   `json:"is_test,omitempty"` // 为 true 时不会记录数据，仅用于测试
+}
+
+// IsPass 是否通过检测
+func (r *Result) IsPass() bool { return r.Suggest == SuggestPass }
+
+// IsRisky 是否违规
+func (r *Result) IsRisky() bool { return r.Suggest == SuggestRisky }
+
+// CheckText 检测文本内容是否违规
+func CheckText(srv token.Server, text *Text) (*TextResult, error) {
+	req := &struct {
+		*Text
+		Version int `json:"version"`
+	}{
+		Text:    text,
+		Version: version,
+	}
+
+	ret := &TextResult{}
+	url := token.URL(srv, "wxa/msg_sec_check", nil)
+	if err := internal.PostJSON(url, req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CheckMediaAsync 异步检测图片或音频是否违规
+//
+// 检测结果会以 wxa_media_check 事件推送至消息推送地址，
+// 可通过 [ParseMediaCheckEvent] 解析，返回值 trace_id 可用于关联检测结果。
+func CheckMediaAsync(srv token.Server, mediaURL string, mediaType, scene int, openid string) (string, error) {
+	req := &struct {
+		MediaURL  string `json:"media_url"`
+		MediaType int    `json:"media_type"`
+		Version   int    `json:"version"`
+		Scene     int    `json:"scene"`
+		OpenID    string `json:"openid"`
+	}{
+		MediaURL:  mediaURL,
+		MediaType: mediaType,
+		Version:   version,
+		Scene:     scene,
+		OpenID:    openid,
+	}
+
+	resp := &struct {
+		TraceID string `json:"trace_id"`
+	}{}
+
+	url := token.URL(srv, "wxa/media_check_async", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return "", err
+	}
+	return resp.TraceID, nil
+}
+
+// GetUserRiskRank 获取用户的风险等级
+//
+// 返回值为风险等级，0 至 4，值越大风险越高。
+func GetUserRiskRank(srv token.Server, r *RiskRank) (int, error) {
+	if r.AppID == "" {
+		rr := *r
+		rr.AppID = srv.Config().AppID
+		r = &rr
+	}
+
+	resp := &struct {
+		RiskRank int `json:"risk_rank"`
+	}{}
+
+	url := token.URL(srv, "wxa/getuserriskrank", nil)
+	if err := internal.PostJSON(url, r, resp); err != nil {
+		return 0, err
+	}
+	return resp.RiskRank, nil
+}
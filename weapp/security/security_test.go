@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package security
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/wechattest"
+)
+
+// 声明 wechattest.Server，并在 path 上添加以 JSON 对象为请求内容的接口
+func newServer(a *assert.Assertion, path string, h func(req map[string]interface{}) interface{}) (*wechattest.Server, token.Server) {
+	srv := wechattest.NewServer()
+	srv.Handle(path, func(r *http.Request) interface{} {
+		req := map[string]interface{}{}
+		a.NotError(json.NewDecoder(r.Body).Decode(&req))
+		return h(req)
+	})
+
+	return srv, token.NewDefaultServer(srv.Config(), nil)
+}
+
+func TestCheckText(t *testing.T) {
+	a := assert.New(t, false)
+
+	srv, tksrv := newServer(a, "/wxa/msg_sec_check", func(req map[string]interface{}) interface{} {
+		a.Equal(req["content"], "content").
+			Equal(req["openid"], "openid").
+			Equal(req["version"], 2.0).
+			Equal(req["scene"], 2.0)
+
+		return map[string]interface{}{
+			"errcode":  0,
+			"trace_id": "trace",
+			"result":   map[string]interface{}{"suggest": "risky", "label": 20001},
+			"detail":   []interface{}{map[string]interface{}{"strategy": "keyword", "keyword": "kw"}},
+		}
+	})
+	defer srv.Close()
+
+	ret, err := CheckText(tksrv, &Text{Content: "content", Scene: SceneComment, OpenID: "openid"})
+	a.NotError(err).
+		Equal(ret.TraceID, "trace").
+		True(ret.Result.IsRisky()).
+		Equal(ret.Result.Label, LabelPolitics).
+		Length(ret.Detail, 1).
+		Equal(ret.Detail[0].Keyword, "kw")
+
+	srv.InjectError(40001)
+	ret, err = CheckText(tksrv, &Text{Content: "content"})
+	a.Equal(err.(*common.Result).Code, 40001).Nil(ret)
+}
+
+func TestCheckMediaAsync(t *testing.T) {
+	a := assert.New(t, false)
+
+	srv, tksrv := newServer(a, "/wxa/media_check_async", func(req map[string]interface{}) interface{} {
+		a.Equal(req["media_url"], "https://example.com/1.png").
+			Equal(req["media_type"], 2.0).
+			Equal(req["version"], 2.0).
+			Equal(req["openid"], "openid")
+
+		return map[string]interface{}{"errcode": 0, "trace_id": "trace"}
+	})
+	defer srv.Close()
+
+	id, err := CheckMediaAsync(tksrv, "https://example.com/1.png", MediaTypeImage, SceneProfile, "openid")
+	a.NotError(err).Equal(id, "trace")
+}
+
+func TestGetUserRiskRank(t *testing.T) {
+	a := assert.New(t, false)
+
+	var appid string
+	srv, tksrv := newServer(a, "/wxa/getuserriskrank", func(req map[string]interface{}) interface{} {
+		appid = req["appid"].(string)
+		a.Equal(req["openid"], "openid").Equal(req["client_ip"], "127.0.0.1")
+		return map[string]interface{}{"errcode": 0, "risk_rank": 3}
+	})
+	defer srv.Close()
+
+	// 默认采用 Config 中的 AppID，且不会修改参数。
+	r := &RiskRank{OpenID: "openid", ClientIP: "127.0.0.1"}
+	rank, err := GetUserRiskRank(tksrv, r)
+	a.NotError(err).Equal(rank, 3).Equal(appid, wechattest.AppID).Empty(r.AppID)
+
+	r.AppID = "other"
+	rank, err = GetUserRiskRank(tksrv, r)
+	a.NotError(err).Equal(rank, 3).Equal(appid, "other")
+}
@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package subscribe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/issue9/wechat/internal"
+)
+
+// 订阅消息相关的事件类型
+const (
+	EventTypePopup  = "subscribe_msg_popup_event"  // 用户操作订阅通知弹窗
+	EventTypeChange = "subscribe_msg_change_event" // 用户管理订阅消息
+	EventTypeSent   = "subscribe_msg_sent_event"   // 发送订阅消息的结果
+)
+
+// 用户的订阅状态
+const (
+	StatusAccept = "accept" // 同意订阅
+	StatusReject = "reject" // 拒绝订阅
+	StatusBan    = "ban"    // 已被后台封禁
+)
+
+// Event 订阅消息相关的事件
+//
+// 这些事件会推送至小程序的消息推送地址，格式可以是 XML 或是 JSON，
+// 可以通过 [Parse] 将其转换成具体的事件对象。
+type Event interface {
+	// 事件类型，对应 Event 字段
+	EventType() string
+
+	// 接收事件的小程序原始 ID，对应 ToUserName 字段
+	To() string
+
+	// 触发事件的用户 openid，对应 FromUserName 字段
+	From() string
+
+	// 事件的创建时间，对应 CreateTime 字段
+	Created() int64
+}
+
+type base struct {
+	ToUserName   string `xml:"ToUserName" json:"ToUserName"`
+	FromUserName string `xml:"FromUserName" json:"FromUserName"`
+	CreateTime   int64  `xml:"CreateTime" json:"CreateTime"`
+	MsgType      string `xml:"MsgType" json:"MsgType"`
+	Event        string `xml:"Event" json:"Event"`
+}
+
+// PopupEvent 用户操作订阅通知弹窗的事件
+type PopupEvent struct {
+	base
+	List []*PopupItem `xml:"SubscribeMsgPopupEvent>List" json:"-"`
+}
+
+// PopupItem 弹窗中单个模板的订阅结果
+type PopupItem struct {
+	TemplateID string `xml:"TemplateId" json:"TemplateId"`
+	Status     string `xml:"SubscribeStatusString" json:"SubscribeStatusString"` // Status* 系列常量
+	PopupScene string `xml:"PopupScene" json:"PopupScene"`                       // 弹窗场景，0 表示 wx.requestSubscribeMessage，1 表示支付之后，2 表示其它
+}
+
+// ChangeEvent 用户在设置中管理订阅消息的事件
+type ChangeEvent struct {
+	base
+	List []*ChangeItem `xml:"SubscribeMsgChangeEvent>List" json:"-"`
+}
+
+// ChangeItem 单个模板的订阅状态变化
+type ChangeItem struct {
+	TemplateID string `xml:"TemplateId" json:"TemplateId"`
+	Status     string `xml:"SubscribeStatusString" json:"SubscribeStatusString"` // Status* 系列常量
+}
+
+// SentEvent 订阅消息发送结果的事件
+type SentEvent struct {
+	base
+	List []*SentItem `xml:"SubscribeMsgSentEvent>List" json:"-"`
+}
+
+// SentItem 单条订阅消息的发送结果
+type SentItem struct {
+	TemplateID  string `xml:"TemplateId" json:"TemplateId"`
+	MsgID       string `xml:"MsgID" json:"MsgID"`
+	ErrorCode   string `xml:"ErrorCode" json:"ErrorCode"` // 0 表示成功
+	ErrorStatus string `xml:"ErrorStatus" json:"ErrorStatus"`
+}
+
+func (b *base) EventType() string { return b.Event }
+
+func (b *base) To() string { return b.ToUserName }
+
+func (b *base) From() string { return b.FromUserName }
+
+func (b *base) Created() int64 { return b.CreateTime }
+
+// IsOK 是否发送成功
+func (i *SentItem) IsOK() bool { return i.ErrorCode == "0" }
+
+// UnmarshalJSON 实现 [json.Unmarshaler] 接口
+//
+// 兼容 SubscribeMsgPopupEvent.List 为单个对象或是数组的情况。
+func (e *PopupEvent) UnmarshalJSON(data []byte) error {
+	return unmarshalEvent(data, "SubscribeMsgPopupEvent", &e.base, &e.List)
+}
+
+// UnmarshalJSON 实现 [json.Unmarshaler] 接口
+//
+// 兼容 SubscribeMsgChangeEvent.List 为单个对象或是数组的情况。
+func (e *ChangeEvent) UnmarshalJSON(data []byte) error {
+	return unmarshalEvent(data, "SubscribeMsgChangeEvent", &e.base, &e.List)
+}
+
+// UnmarshalJSON 实现 [json.Unmarshaler] 接口
+//
+// 兼容 SubscribeMsgSentEvent.List 为单个对象或是数组的情况。
+func (e *SentEvent) UnmarshalJSON(data []byte) error {
+	return unmarshalEvent(data, "SubscribeMsgSentEvent", &e.base, &e.List)
+}
+
+// JSON 格式中，name 下的 List 在只有一个元素时为对象，多个元素时为数组。
+func unmarshalEvent(data []byte, name string, b *base, list interface{}) error {
+	if err := json.Unmarshal(data, b); err != nil {
+		return err
+	}
+
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	raw, found := obj[name]
+	if !found {
+		return nil
+	}
+
+	items := &struct {
+		List json.RawMessage `json:"List"`
+	}{}
+	if err := json.Unmarshal(raw, items); err != nil {
+		return err
+	}
+
+	l := bytes.TrimSpace(items.List)
+	switch {
+	case len(l) == 0:
+		return nil
+	case l[0] == '{':
+		l = append(append([]byte{'['}, l...), ']')
+	}
+	return json.Unmarshal(l, list)
+}
+
+// Parse 将订阅消息相关的事件内容转换成 [Event] 对象
+//
+// data 可以是 XML 或是 JSON 格式的内容，如果是安全模式，需要先解密。
+func Parse(data []byte) (Event, error) {
+	b := &base{}
+	if err := internal.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+
+	var e Event
+	switch b.Event {
+	case EventTypePopup:
+		e = &PopupEvent{}
+	case EventTypeChange:
+		e = &ChangeEvent{}
+	case EventTypeSent:
+		e = &SentEvent{}
+	default:
+		return nil, fmt.Errorf("不支持的事件类型 %s", b.Event)
+	}
+
+	if err := internal.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package subscribe
+
+import (
+	"testing"
+
+	"github.com/issue9/assert/v4"
+)
+
+var (
+	_ Event = &PopupEvent{}
+	_ Event = &ChangeEvent{}
+	_ Event = &SentEvent{}
+)
+
+func TestParse(t *testing.T) {
+	a := assert.New(t, false)
+
+	// XML
+	data := []byte(`<xml>
+	<ToUserName><![CDATA[gh_123456789abc]]></ToUserName>
+	<FromUserName><![CDATA[otFpruAK8D-E6EfStSYonYSBZ8_4]]></FromUserName>
+	<CreateTime>1610969440</CreateTime>
+	<MsgType><![CDATA[event]]></MsgType>
+	<Event><![CDATA[subscribe_msg_popup_event]]></Event>
+	<SubscribeMsgPopupEvent>
+		<List>
+			<TemplateId><![CDATA[VRR0UEO9VJOLs0MHlU0OilqX6MVFDwH3_3gz3Oc0NIc]]></TemplateId>
+			<SubscribeStatusString><![CDATA[accept]]></SubscribeStatusString>
+			<PopupScene>2</PopupScene>
+		</List>
+		<List>
+			<TemplateId><![CDATA[9nLIlbOQZC5Y89AZteFEux3WCXRRRG5Wfzkpssu4bLI]]></TemplateId>
+			<SubscribeStatusString><![CDATA[reject]]></SubscribeStatusString>
+			<PopupScene>2</PopupScene>
+		</List>
+	</SubscribeMsgPopupEvent>
+	</xml>`)
+	e, err := Parse(data)
+	a.NotError(err).NotNil(e).Equal(e.EventType(), EventTypePopup)
+	popup, ok := e.(*PopupEvent)
+	a.True(ok).
+		Length(popup.List, 2).
+		Equal(popup.List[0].Status, StatusAccept).
+		Equal(popup.List[1].Status, StatusReject).
+		Equal(popup.Created(), 1610969440)
+
+	// JSON，List 为对象
+	data = []byte(`{
+	"ToUserName": "gh_123456789abc",
+	"FromUserName": "o7esq5OI1Uej6Xixw1lA2H7XDVbc",
+	"CreateTime": 1620963428,
+	"MsgType": "event",
+	"Event": "subscribe_msg_sent_event",
+	"SubscribeMsgSentEvent": {
+		"List": {
+			"TemplateId": "VRR0UEO9VJOLs0MHlU0OilqX6MVFDwH3_3gz3Oc0NIc",
+			"MsgID": "1864323726461255680",
+			"ErrorCode": "0",
+			"ErrorStatus": "success"
+		}
+	}
+	}`)
+	e, err = Parse(data)
+	a.NotError(err).NotNil(e)
+	sent, ok := e.(*SentEvent)
+	a.True(ok).
+		Length(sent.List, 1).
+		True(sent.List[0].IsOK()).
+		Equal(sent.From(), "o7esq5OI1Uej6Xixw1lA2H7XDVbc")
+
+	// JSON，List 为数组
+	data = []byte(`{
+	"ToUserName": "gh_123456789abc",
+	"FromUserName": "o7esq5OI1Uej6Xixw1lA2H7XDVbc",
+	"CreateTime": 1620963428,
+	"MsgType": "event",
+	"Event": "subscribe_msg_change_event",
+	"SubscribeMsgChangeEvent": {
+		"List": [
+			{"TemplateId": "t1", "SubscribeStatusString": "reject"},
+			{"TemplateId": "t2", "SubscribeStatusString": "accept"}
+		]
+	}
+	}`)
+	e, err = Parse(data)
+	a.NotError(err).NotNil(e)
+	change, ok := e.(*ChangeEvent)
+	a.True(ok).
+		Length(change.List, 2).
+		Equal(change.List[1].TemplateID, "t2")
+
+	// 不支持的事件
+	e, err = Parse([]byte(`{"MsgType":"event","Event":"user_enter_tempsession"}`))
+	a.Error(err).Nil(e)
+}
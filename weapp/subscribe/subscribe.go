@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package subscribe 小程序的订阅消息
+//
+// 用于替代已经被微信废弃的模板消息 [template.Send]。
+//
+// [template.Send]: https://pkg.go.dev/github.com/issue9/wechat/weapp/template#Send
+package subscribe
+
+import (
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+)
+
+// 跳转小程序的类型，即 miniprogram_state 的值
+const (
+	StateDeveloper = "developer" // 开发版
+	StateTrial     = "trial"     // 体验版
+	StateFormal    = "formal"    // 正式版，默认值
+)
+
+// 进入小程序查看的语言类型
+const (
+	LangZhCN = "zh_CN" // 简体中文，默认值
+	LangEnUS = "en_US" // 英文
+	LangZhHK = "zh_HK" // 繁体中文
+	LangZhTW = "zh_TW" // 繁体中文
+)
+
+// Message 订阅消息的内容
+type Message struct {
+	To               string `json:"touser"`
+	TemplateID       string `json:"template_id"`
+	Page             string `json:"page,omitempty"`              // 点击消息之后跳转的页面，可带参数
+	Data             Data   `json:"data"`                        // 模板内容
+	MiniprogramState string `json:"miniprogram_state,omitempty"` // 跳转小程序类型，可以是 State* 系列常量
+	Lang             string `json:"lang,omitempty"`              // 语言类型，可以是 Lang* 系列常量
+}
+
+// Data 订阅消息的数据内容
+//
+// 键名为模板中的关键字名称，比如 thing1、time2 等。
+type Data map[string]Value
+
+// Value 订阅消息中单个关键字的值
+type Value struct {
+	Value string `json:"value"`
+}
+
+// Send 发送订阅消息
+func Send(srv token.Server, msg *Message) error {
+	url := token.URL(srv, "cgi-bin/message/subscribe/send", nil)
+	return internal.PostJSON(url, msg, nil)
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package subscribe
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestSend(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	msg := &Message{
+		To:               "openid",
+		TemplateID:       "tplid",
+		Page:             "index?foo=1",
+		Data:             Data{"thing1": {Value: "v1"}, "time2": {Value: "2021-01-01"}},
+		MiniprogramState: StateTrial,
+	}
+	a.NotError(Send(tksrv, msg))
+
+	msgs := srv.Messages()
+	a.Length(msgs, 1).Equal(msgs[0].Path, "/cgi-bin/message/subscribe/send")
+	body := map[string]interface{}{}
+	a.NotError(json.Unmarshal(msgs[0].Body, &body))
+	a.Equal(body["touser"], "openid").
+		Equal(body["template_id"], "tplid").
+		Equal(body["page"], "index?foo=1").
+		Equal(body["miniprogram_state"], StateTrial).
+		Equal(body["data"], map[string]interface{}{
+			"thing1": map[string]interface{}{"value": "v1"},
+			"time2":  map[string]interface{}{"value": "2021-01-01"},
+		})
+	_, found := body["lang"]
+	a.False(found)
+
+	srv.InjectError(43101) // 用户拒绝接受消息
+	err := Send(tksrv, msg)
+	a.Equal(err.(*common.Result).Code, 43101)
+	a.Length(srv.Messages(), 1)
+}
@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package subscribe
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+)
+
+// 模板的类型
+const (
+	TemplateTypeOnce     = 2 // 一次性订阅
+	TemplateTypeLongTerm = 3 // 长期订阅
+)
+
+// Category 小程序账号的类目
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Title 公共模板库中的模板标题
+type Title struct {
+	TID        int    `json:"tid"`
+	Title      string `json:"title"`
+	Type       int    `json:"type"` // 模板类型，TemplateType* 系列常量
+	CategoryID string `json:"categoryId"`
+}
+
+// Titles 公共模板库中的模板标题列表
+type Titles struct {
+	Count int      `json:"count"` // 模板标题的总数
+	Data  []*Title `json:"data"`
+}
+
+// Keyword 公共模板中的关键词
+type Keyword struct {
+	KID     int    `json:"kid"`
+	Name    string `json:"name"`
+	Example string `json:"example"`
+	Rule    string `json:"rule"` // 参数类型，比如 thing、time 等
+}
+
+// Template 个人模板
+type Template struct {
+	ID      string `json:"priTmplId"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Example string `json:"example"`
+	Type    int    `json:"type"` // 模板类型，TemplateType* 系列常量
+}
+
+// Categories 获取小程序账号的类目
+func Categories(srv token.Server) ([]*Category, error) {
+	obj := &struct {
+		Data []*Category `json:"data"`
+	}{}
+
+	url := token.URL(srv, "wxaapi/newtmpl/getcategory", nil)
+	if err := internal.GetJSON(url, obj); err != nil {
+		return nil, err
+	}
+	return obj.Data, nil
+}
+
+// PubTemplateTitles 获取类目下的公共模板标题
+//
+// ids 为类目的 ID 列表，可由 [Categories] 获取；
+// start 和 limit 用于分页，limit 最大为 30。
+func PubTemplateTitles(srv token.Server, ids []int, start, limit int) (*Titles, error) {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.Itoa(id))
+	}
+
+	url := token.URL(srv, "wxaapi/newtmpl/getpubtemplatetitles", map[string]string{
+		"ids":   strings.Join(strs, ","),
+		"start": strconv.Itoa(start),
+		"limit": strconv.Itoa(limit),
+	})
+
+	titles := &Titles{}
+	if err := internal.GetJSON(url, titles); err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+// PubTemplateKeywords 获取公共模板下的关键词列表
+func PubTemplateKeywords(srv token.Server, tid int) ([]*Keyword, error) {
+	obj := &struct {
+		Data []*Keyword `json:"data"`
+	}{}
+
+	url := token.URL(srv, "wxaapi/newtmpl/getpubtemplatekeywords", map[string]string{"tid": strconv.Itoa(tid)})
+	if err := internal.GetJSON(url, obj); err != nil {
+		return nil, err
+	}
+	return obj.Data, nil
+}
+
+// Templates 获取当前帐号下的个人模板列表
+func Templates(srv token.Server) ([]*Template, error) {
+	obj := &struct {
+		Data []*Template `json:"data"`
+	}{}
+
+	url := token.URL(srv, "wxaapi/newtmpl/gettemplate", nil)
+	if err := internal.GetJSON(url, obj); err != nil {
+		return nil, err
+	}
+	return obj.Data, nil
+}
+
+// AddTemplate 从公共模板中选用模板到个人模板库
+//
+// tid 为公共模板的 ID；kids 为选用的关键词 ID 列表，顺序即为模板中的顺序；
+// sceneDesc 为服务场景描述。返回添加之后的个人模板 ID。
+func AddTemplate(srv token.Server, tid int, kids []int, sceneDesc string) (string, error) {
+	req := &struct {
+		TID       string `json:"tid"`
+		KIDList   []int  `json:"kidList"`
+		SceneDesc string `json:"sceneDesc,omitempty"`
+	}{
+		TID:       strconv.Itoa(tid),
+		KIDList:   kids,
+		SceneDesc: sceneDesc,
+	}
+
+	resp := &struct {
+		ID string `json:"priTmplId"`
+	}{}
+
+	url := token.URL(srv, "wxaapi/newtmpl/addtemplate", nil)
+	if err := internal.PostJSON(url, req, resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// DelTemplate 删除个人模板
+func DelTemplate(srv token.Server, id string) error {
+	req := &struct {
+		ID string `json:"priTmplId"`
+	}{
+		ID: id,
+	}
+
+	url := token.URL(srv, "wxaapi/newtmpl/deltemplate", nil)
+	return internal.PostJSON(url, req, nil)
+}
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package subscribe
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/wechattest"
+)
+
+func TestCategories(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxaapi/newtmpl/getcategory", func(r *http.Request) interface{} {
+		a.Equal(r.Method, http.MethodGet)
+		return map[string]interface{}{
+			"errcode": 0,
+			"data": []interface{}{
+				map[string]interface{}{"id": 616, "name": "公交"},
+				map[string]interface{}{"id": 627, "name": "旅游服务"},
+			},
+		}
+	})
+
+	list, err := Categories(tksrv)
+	a.NotError(err).
+		Length(list, 2).
+		Equal(list[1], &Category{ID: 627, Name: "旅游服务"})
+
+	srv.InjectError(40001)
+	list, err = Categories(tksrv)
+	a.Equal(err.(*common.Result).Code, 40001).Nil(list)
+}
+
+func TestPubTemplateTitles(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxaapi/newtmpl/getpubtemplatetitles", func(r *http.Request) interface{} {
+		q := r.URL.Query()
+		a.Equal(q.Get("ids"), "2,616").
+			Equal(q.Get("start"), "0").
+			Equal(q.Get("limit"), "1")
+		return map[string]interface{}{
+			"errcode": 0,
+			"count":   55,
+			"data": []interface{}{
+				map[string]interface{}{"tid": 99, "title": "付款成功通知", "type": 2, "categoryId": "616"},
+			},
+		}
+	})
+
+	titles, err := PubTemplateTitles(tksrv, []int{2, 616}, 0, 1)
+	a.NotError(err).
+		Equal(titles.Count, 55).
+		Length(titles.Data, 1).
+		Equal(titles.Data[0], &Title{TID: 99, Title: "付款成功通知", Type: TemplateTypeOnce, CategoryID: "616"})
+}
+
+func TestPubTemplateKeywords(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxaapi/newtmpl/getpubtemplatekeywords", func(r *http.Request) interface{} {
+		a.Equal(r.URL.Query().Get("tid"), "99")
+		return map[string]interface{}{
+			"errcode": 0,
+			"count":   1,
+			"data": []interface{}{
+				map[string]interface{}{"kid": 1, "name": "物品名称", "example": "名称", "rule": "thing"},
+			},
+		}
+	})
+
+	list, err := PubTemplateKeywords(tksrv, 99)
+	a.NotError(err).
+		Length(list, 1).
+		Equal(list[0], &Keyword{KID: 1, Name: "物品名称", Example: "名称", Rule: "thing"})
+}
+
+func TestTemplates(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxaapi/newtmpl/gettemplate", func(*http.Request) interface{} {
+		return map[string]interface{}{
+			"errcode": 0,
+			"data": []interface{}{
+				map[string]interface{}{
+					"priTmplId": "9Aw5ZV1j9xdWTFEkqCpZ7mIBbSC34khK55OtzUPl0rU",
+					"title":     "报名结果通知",
+					"content":   "会议时间:{{date2.DATA}}\n会议地点:{{thing1.DATA}}\n",
+					"example":   "会议时间:2016年8月8日\n会议地点:TIT会议室\n",
+					"type":      2,
+				},
+			},
+		}
+	})
+
+	list, err := Templates(tksrv)
+	a.NotError(err).
+		Length(list, 1).
+		Equal(list[0].ID, "9Aw5ZV1j9xdWTFEkqCpZ7mIBbSC34khK55OtzUPl0rU").
+		Equal(list[0].Content, "会议时间:{{date2.DATA}}\n会议地点:{{thing1.DATA}}\n").
+		Equal(list[0].Type, TemplateTypeOnce)
+}
+
+func TestAddTemplate(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxaapi/newtmpl/addtemplate", func(r *http.Request) interface{} {
+		a.Equal(r.Method, http.MethodPost)
+		return map[string]interface{}{"errcode": 0, "priTmplId": "new-id"}
+	})
+
+	id, err := AddTemplate(tksrv, 401, []int{1, 2}, "desc")
+	a.NotError(err).Equal(id, "new-id")
+	msgs := srv.Messages()
+	a.Length(msgs, 1).
+		Equal(string(msgs[0].Body), `{"tid":"401","kidList":[1,2],"sceneDesc":"desc"}`)
+
+	id, err = AddTemplate(tksrv, 401, []int{1}, "")
+	a.NotError(err).Equal(id, "new-id")
+	a.Equal(string(srv.Messages()[1].Body), `{"tid":"401","kidList":[1]}`)
+
+	srv.InjectError(200014) // 模板 tid 参数错误
+	id, err = AddTemplate(tksrv, 401, []int{1}, "")
+	a.Equal(err.(*common.Result).Code, 200014).Empty(id)
+}
+
+func TestDelTemplate(t *testing.T) {
+	a := assert.New(t, false)
+	srv := wechattest.NewServer()
+	defer srv.Close()
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+
+	srv.Handle("/wxaapi/newtmpl/deltemplate", func(*http.Request) interface{} {
+		return map[string]interface{}{"errcode": 0, "errmsg": "ok"}
+	})
+
+	a.NotError(DelTemplate(tksrv, "id"))
+	msgs := srv.Messages()
+	a.Length(msgs, 1).
+		Equal(msgs[0].Path, "/wxaapi/newtmpl/deltemplate").
+		Equal(string(msgs[0].Body), `{"priTmplId":"id"}`)
+}
@@ -15,6 +15,10 @@ import (
 )
 
 // Send 发送模板信息
+//
+// Deprecated: 微信已经下线了小程序的模板消息，请使用 [subscribe.Send] 代替。
+//
+// [subscribe.Send]: https://pkg.go.dev/github.com/issue9/wechat/weapp/subscribe#Send
 func Send(srv token.Server, to, tplid, page, formid string, data Data) error {
 	obj := &struct {
 		To   string        `json:"touser"`
@@ -25,7 +29,7 @@ func Send(srv token.Server, to, tplid, page, formid string, data Data) error {
 	}{
 		To:   to,
 		ID:   tplid,
-		Page: to,
+		Page: page,
 		Form: formid,
 		Data: data,
 	}
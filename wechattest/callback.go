@@ -0,0 +1,274 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package wechattest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/pay"
+)
+
+// Callback 模拟微信向开发者服务器发起的回调请求
+//
+// 会像微信一样对请求进行签名，在安全模式下还会对内容进行加密，
+// 并将 handler 的返回内容解密之后以 [Reply] 的形式返回：
+//
+//	srv := message.NewServer(token, ...)
+//	cb := wechattest.NewCallback(srv, token, nil)
+//	reply, err := cb.Post([]byte(`<xml><MsgType>text</MsgType>...</xml>`))
+type Callback struct {
+	handler http.Handler
+	token   string
+	crypto  *crypto.Crypto
+}
+
+// Reply 开发者服务器的返回内容
+type Reply struct {
+	Status    int
+	Body      []byte // 如果是加密的内容，则为解密之后的内容
+	Encrypted bool   // 返回的内容是否为加密的
+}
+
+// 加密后的返回内容
+type encryptedReply struct {
+	Encrypt      string `xml:"Encrypt"`
+	MsgSignature string `xml:"MsgSignature"`
+	TimeStamp    string `xml:"TimeStamp"`
+	Nonce        string `xml:"Nonce"`
+}
+
+// NewCallback 声明 [Callback] 实例
+//
+// h 为处理回调的开发者服务；token 为后台配置的令牌；
+// c 为安全模式下的加解密对象，为空表示采用明文模式。
+func NewCallback(h http.Handler, token string, c *crypto.Crypto) *Callback {
+	return &Callback{
+		handler: h,
+		token:   token,
+		crypto:  c,
+	}
+}
+
+// Verify 模拟接入时的 GET 验证请求
+func (c *Callback) Verify(echostr string) (*Reply, error) {
+	q := c.query()
+	q.Set("echostr", echostr)
+	r := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+	return c.do(r)
+}
+
+// Post 模拟推送消息或是事件
+//
+// body 为明文的 XML 或是 JSON 内容，在安全模式下会自动加密。
+func (c *Callback) Post(body []byte) (*Reply, error) {
+	q := c.query()
+
+	if c.crypto != nil {
+		enc, err := c.encrypt(body, q)
+		if err != nil {
+			return nil, err
+		}
+
+		if internal.IsXML(body) {
+			body, err = xml.Marshal(&struct {
+				XMLName xml.Name   `xml:"xml"`
+				Encrypt xxml.CData `xml:"Encrypt"`
+			}{Encrypt: xxml.CData{Text: enc}})
+		} else {
+			body, err = json.Marshal(map[string]string{"Encrypt": enc})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/?"+q.Encode(), bytes.NewReader(body))
+	return c.do(r)
+}
+
+// PostXML 将 v 转换成 XML 之后调用 [Callback.Post]
+func (c *Callback) PostXML(v interface{}) (*Reply, error) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.Post(body)
+}
+
+// VerifyTicket 模拟第三方平台的 component_verify_ticket 推送
+//
+// 该推送只能是安全模式。
+func (c *Callback) VerifyTicket(componentAppID, ticket string) (*Reply, error) {
+	if c.crypto == nil {
+		return nil, errors.New("component_verify_ticket 只能以安全模式推送")
+	}
+
+	type verifyTicket struct {
+		XMLName               xml.Name   `xml:"xml"`
+		AppID                 xxml.CData `xml:"AppId"`
+		CreateTime            int64      `xml:"CreateTime"`
+		InfoType              xxml.CData `xml:"InfoType"`
+		ComponentVerifyTicket xxml.CData `xml:"ComponentVerifyTicket"`
+	}
+	return c.PostXML(&verifyTicket{
+		AppID:                 xxml.CData{Text: componentAppID},
+		CreateTime:            time.Now().Unix(),
+		InfoType:              xxml.CData{Text: "component_verify_ticket"},
+		ComponentVerifyTicket: xxml.CData{Text: ticket},
+	})
+}
+
+// 生成带签名的查询参数
+func (c *Callback) query() url.Values {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := pay.NonceString()
+
+	q := url.Values{}
+	q.Set("signature", signature(c.token, timestamp, nonce))
+	q.Set("timestamp", timestamp)
+	q.Set("nonce", nonce)
+	return q
+}
+
+// 加密 body，并在 q 中添加安全模式下的参数
+func (c *Callback) encrypt(body []byte, q url.Values) (string, error) {
+	data, _, err := c.crypto.Encrypt(body, q.Get("timestamp"), q.Get("nonce"))
+	if err != nil {
+		return "", err
+	}
+
+	ret := &encryptedReply{}
+	if err = xml.Unmarshal(data, ret); err != nil {
+		return "", err
+	}
+
+	q.Set("encrypt_type", "aes")
+	q.Set("msg_signature", signature(c.token, q.Get("timestamp"), q.Get("nonce"), ret.Encrypt))
+	return ret.Encrypt, nil
+}
+
+func (c *Callback) do(r *http.Request) (*Reply, error) {
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+
+	reply := &Reply{Status: w.Code, Body: w.Body.Bytes()}
+	if c.crypto == nil || !internal.IsXML(reply.Body) {
+		return reply, nil
+	}
+
+	enc := &encryptedReply{}
+	if err := xml.Unmarshal(reply.Body, enc); err != nil || enc.Encrypt == "" { // 非加密的内容
+		return reply, nil
+	}
+
+	data, err := c.crypto.Decrypt(reply.Body, enc.MsgSignature, enc.TimeStamp, enc.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	reply.Body = data
+	reply.Encrypted = true
+	return reply, nil
+}
+
+// Decode 将返回的内容解码到 v
+//
+// 根据内容自动判断是 XML 还是 JSON。
+func (r *Reply) Decode(v interface{}) error {
+	if internal.IsXML(r.Body) {
+		return xml.Unmarshal(r.Body, v)
+	}
+	return json.Unmarshal(r.Body, v)
+}
+
+// PaySuccess 支付通知的返回内容是否表示成功
+func (r *Reply) PaySuccess() bool {
+	params, err := xxml.MapFromXMLReader(bytes.NewReader(r.Body))
+	return err == nil && params["return_code"] == pay.Success
+}
+
+// NewPayNotify 生成已签名的支付结果通知
+//
+// params 为通知的内容，return_code、result_code、appid、mch_id 以及 nonce_str
+// 等未指定的公共字段会自动填充，服务商模式下还会填充 sub_mch_id 和 sub_appid；
+// coupons 会被转换成 coupon_count 和 coupon_id_$n 等字段。
+// 签名类型由 params 中的 sign_type 指定。
+func NewPayNotify(p *pay.Pay, params map[string]string, coupons ...*pay.Coupon) (*http.Request, error) {
+	m := map[string]string{
+		"return_code": pay.Success,
+		"result_code": pay.Success,
+		"appid":       p.AppID(),
+		"mch_id":      p.MchID(),
+		"sub_mch_id":  p.SubMchID(),
+		"sub_appid":   p.SubAppID(),
+		"nonce_str":   pay.NonceString(),
+	}
+	for k, v := range params {
+		m[k] = v
+	}
+
+	if len(coupons) > 0 {
+		fee := 0
+		for i, c := range coupons {
+			n := "_" + strconv.Itoa(i)
+			m["coupon_id"+n] = strconv.Itoa(c.ID)
+			m["coupon_type"+n] = c.Type
+			m["coupon_fee"+n] = strconv.Itoa(c.Fee)
+			fee += c.Fee
+		}
+		m["coupon_count"] = strconv.Itoa(len(coupons))
+		if _, found := m["coupon_fee"]; !found {
+			m["coupon_fee"] = strconv.Itoa(fee)
+		}
+	}
+
+	sign, err := p.Sign(m["sign_type"], m)
+	if err != nil {
+		return nil, err
+	}
+	m["sign"] = sign
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(marshalMap(m)))
+	r.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	return r, nil
+}
+
+// PayNotify 向 h 发送由 [NewPayNotify] 生成的支付结果通知
+func PayNotify(h http.Handler, p *pay.Pay, params map[string]string, coupons ...*pay.Coupon) (*Reply, error) {
+	r, err := NewPayNotify(p, params, coupons...)
+	if err != nil {
+		return nil, err
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return &Reply{Status: w.Code, Body: w.Body.Bytes()}, nil
+}
+
+// 微信回调的签名方法
+func signature(strs ...string) string {
+	sort.Strings(strs)
+
+	var buf bytes.Buffer
+	for _, s := range strs {
+		buf.WriteString(s)
+	}
+
+	hash := sha1.Sum(buf.Bytes())
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package wechattest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/open"
+	"github.com/issue9/wechat/open/crypto"
+	"github.com/issue9/wechat/pay"
+	"github.com/issue9/wechat/pay/notify"
+	"github.com/issue9/wechat/weapp/message"
+)
+
+const (
+	testToken  = "token"
+	testAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+)
+
+func TestCallback_weapp(t *testing.T) {
+	a := assert.New(t, false)
+
+	var content string
+	b := message.NewHandlerBus()
+	b.RegisterMessage(message.TypeText, func(m message.Messager) ([]byte, error) {
+		content = m.(*message.Text).Content
+		return nil, nil
+	})
+
+	c, err := crypto.New(AppID, testToken, testAESKey)
+	a.NotError(err)
+	cb := NewCallback(message.NewServer(testToken, c, b.Handler, nil), testToken, c)
+
+	reply, err := cb.Verify("echo")
+	a.NotError(err).
+		Equal(reply.Status, http.StatusOK).
+		Equal(string(reply.Body), "echo")
+
+	reply, err = cb.Post([]byte(`<xml><MsgType>text</MsgType><Content>xml</Content></xml>`))
+	a.NotError(err).
+		Equal(reply.Status, http.StatusOK).
+		Equal(reply.Body, message.ReplySuccess).
+		False(reply.Encrypted).
+		Equal(content, "xml")
+
+	reply, err = cb.Post([]byte(`{"MsgType":"text","Content":"json"}`))
+	a.NotError(err).
+		Equal(reply.Status, http.StatusOK).
+		Equal(content, "json")
+
+	// 明文
+	cb = NewCallback(message.NewServer(testToken, nil, b.Handler, nil), testToken, nil)
+	reply, err = cb.Post([]byte(`{"MsgType":"text","Content":"plain"}`))
+	a.NotError(err).
+		Equal(reply.Status, http.StatusOK).
+		Equal(content, "plain")
+
+	// token 错误
+	cb = NewCallback(message.NewServer(testToken, nil, b.Handler, nil), "other", nil)
+	reply, err = cb.Verify("echo")
+	a.NotError(err).Equal(reply.Status, http.StatusForbidden)
+}
+
+func TestCallback_encryptedReply(t *testing.T) {
+	a := assert.New(t, false)
+
+	c, err := crypto.New(AppID, testToken, testAESKey)
+	a.NotError(err)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _, err := c.Encrypt([]byte(`<xml><Content>reply</Content></xml>`), r.FormValue("timestamp"), r.FormValue("nonce"))
+		a.NotError(err)
+		w.Write(data)
+	})
+	reply, err := NewCallback(h, testToken, c).Post([]byte(`<xml></xml>`))
+	a.NotError(err).True(reply.Encrypted)
+
+	obj := &struct {
+		Content string `xml:"Content"`
+	}{}
+	a.NotError(reply.Decode(obj)).Equal(obj.Content, "reply")
+}
+
+func TestCallback_VerifyTicket(t *testing.T) {
+	a := assert.New(t, false)
+
+	c, err := crypto.New("component-appid", testToken, testAESKey)
+	a.NotError(err)
+
+	var ticket *open.VerifyTicket
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket, err = open.ParseVerifyTicket(c, w, r)
+		a.NotError(err)
+	})
+
+	reply, err := NewCallback(h, testToken, c).VerifyTicket("component-appid", "ticket")
+	a.NotError(err).Equal(string(reply.Body), "success")
+	a.Equal(ticket.AppID, "component-appid").
+		Equal(ticket.InfoType, "component_verify_ticket").
+		Equal(ticket.ComponentVerifyTicket, "ticket")
+
+	_, err = NewCallback(h, testToken, nil).VerifyTicket("component-appid", "ticket")
+	a.Error(err)
+}
+
+func TestPayNotify(t *testing.T) {
+	a := assert.New(t, false)
+	p := pay.New(MchID, AppID, APIKey, nil)
+
+	var ret *notify.Return
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if ret, err = notify.Read(p, r.Body); err != nil {
+			a.NotError(notify.Fail(err.Error()).Render(http.StatusOK, w))
+			return
+		}
+		a.NotError(notify.Success().Render(http.StatusOK, w))
+	})
+
+	params := map[string]string{
+		"sign_type":      pay.SignTypeHmacSha256,
+		"openid":         "openid",
+		"trade_type":     pay.TradeTypeJSAPI,
+		"total_fee":      "100",
+		"cash_fee":       "70",
+		"transaction_id": "transaction",
+		"out_trade_no":   "no1",
+		"time_end":       "20140903131540",
+	}
+	reply, err := PayNotify(h, p, params,
+		&pay.Coupon{ID: 1, Type: pay.CouponTypeCash, Fee: 10},
+		&pay.Coupon{ID: 2, Type: pay.CouponTypeNoCash, Fee: 20},
+	)
+	a.NotError(err).True(reply.PaySuccess())
+	a.Equal(ret.OutTradeNO, "no1").
+		Equal(ret.CouponCount, 2).
+		Equal(ret.CouponFee, 30).
+		Length(ret.Coupons, 2)
+
+	// apikey 不同
+	reply, err = PayNotify(h, pay.New(MchID, AppID, "other", nil), params)
+	a.NotError(err).False(reply.PaySuccess())
+}
@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package wechattest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 第三方平台的默认配置
+const (
+	ComponentAppID        = "wechattest-component-appid"
+	ComponentAppSecret    = "wechattest-component-appsecret"
+	ComponentVerifyTicket = "wechattest-verify-ticket"
+)
+
+func (s *Server) initComponent() {
+	s.mux.HandleFunc("/cgi-bin/component/api_component_token", s.componentToken)
+	s.mux.HandleFunc("/cgi-bin/component/api_create_preauthcode", s.componentFunc(s.preAuthCode))
+	s.mux.HandleFunc("/cgi-bin/component/api_query_auth", s.componentFunc(s.queryAuth))
+	s.mux.HandleFunc("/cgi-bin/component/api_authorizer_token", s.componentFunc(s.authorizerToken))
+	s.mux.HandleFunc("/cgi-bin/component/api_get_authorizer_info", s.componentFunc(s.authorizerInfo))
+}
+
+// Authorize 模拟 appid 对第三方平台的授权
+//
+// 返回授权码，可用于调用 api_query_auth 接口，且只能使用一次。
+func (s *Server) Authorize(appid string) string {
+	code := s.unique("auth-code-")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authCodes[code] = appid
+	return code
+}
+
+// Unauthorize 模拟 appid 取消对第三方平台的授权
+//
+// 之后该 appid 的 authorizer_refresh_token 都将失效。
+func (s *Server) Unauthorize(appid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.refreshes {
+		if v == appid {
+			delete(s.refreshes, k)
+		}
+	}
+}
+
+// 颁发一个新的 access_token
+func (s *Server) newToken(prefix string) string {
+	token := s.unique(prefix)
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+	return token
+}
+
+func (s *Server) componentToken(w http.ResponseWriter, r *http.Request) {
+	if code := s.nextError(); code != 0 {
+		writeResult(w, code)
+		return
+	}
+
+	req := &struct {
+		AppID  string `json:"component_appid"`
+		Secret string `json:"component_appsecret"`
+		Ticket string `json:"component_verify_ticket"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeResult(w, 47001)
+		return
+	}
+
+	switch {
+	case req.AppID != ComponentAppID:
+		writeResult(w, 40013)
+		return
+	case req.Secret != ComponentAppSecret:
+		writeResult(w, 40125)
+		return
+	case req.Ticket != ComponentVerifyTicket:
+		writeResult(w, 61006)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"component_access_token": s.newToken("component-access-token-"),
+		"expires_in":             7200,
+	})
+}
+
+// 第三方平台的接口
+//
+// 验证 component_access_token 和 component_appid，
+// 并将请求内容以 map 的形式传递给 f。
+func (s *Server) componentFunc(f func(w http.ResponseWriter, req map[string]string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !s.checkToken(w, r.URL.Query().Get("component_access_token")) {
+			return
+		}
+
+		req := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeResult(w, 47001)
+			return
+		}
+
+		if req["component_appid"] != ComponentAppID {
+			writeResult(w, 40013)
+			return
+		}
+
+		f(w, req)
+	}
+}
+
+func (s *Server) preAuthCode(w http.ResponseWriter, _ map[string]string) {
+	writeJSON(w, map[string]interface{}{
+		"pre_auth_code": s.unique("pre-auth-code-"),
+		"expires_in":    600,
+	})
+}
+
+func (s *Server) queryAuth(w http.ResponseWriter, req map[string]string) {
+	code := req["authorization_code"]
+
+	s.mu.Lock()
+	appid, found := s.authCodes[code]
+	delete(s.authCodes, code)
+	s.mu.Unlock()
+	if !found {
+		writeResult(w, 61010)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"authorization_info": s.authorizerTokenInfo(appid),
+	})
+}
+
+func (s *Server) authorizerToken(w http.ResponseWriter, req map[string]string) {
+	appid := req["authorizer_appid"]
+	refresh := req["authorizer_refresh_token"]
+
+	s.mu.Lock()
+	v, found := s.refreshes[refresh]
+	s.mu.Unlock()
+	if !found || v != appid {
+		writeResult(w, 61023)
+		return
+	}
+
+	// 每次刷新都颁发新的 authorizer_refresh_token，旧的随即失效。
+	s.mu.Lock()
+	delete(s.refreshes, refresh)
+	s.mu.Unlock()
+	writeJSON(w, s.authorizerTokenInfo(appid))
+}
+
+// 生成授权方的令牌信息，包括新的 authorizer_refresh_token。
+func (s *Server) authorizerTokenInfo(appid string) map[string]interface{} {
+	refresh := s.unique("authorizer-refresh-token-")
+	s.mu.Lock()
+	s.refreshes[refresh] = appid
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"authorizer_appid":         appid,
+		"authorizer_access_token":  s.newToken("authorizer-access-token-"),
+		"expires_in":               7200,
+		"authorizer_refresh_token": refresh,
+	}
+}
+
+func (s *Server) authorizerInfo(w http.ResponseWriter, req map[string]string) {
+	appid := req["authorizer_appid"]
+
+	s.mu.Lock()
+	authorized := false
+	for _, v := range s.refreshes {
+		if v == appid {
+			authorized = true
+			break
+		}
+	}
+	s.mu.Unlock()
+	if !authorized {
+		writeResult(w, 61003)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"authorizer_info": map[string]interface{}{
+			"nick_name": "nickname-" + appid,
+			"user_name": "gh_" + appid,
+		},
+	})
+}
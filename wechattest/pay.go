@@ -0,0 +1,369 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package wechattest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/issue9/wechat/internal/xxml"
+	"github.com/issue9/wechat/pay"
+)
+
+// Order 模拟服务中的订单
+type Order struct {
+	OutTradeNO    string
+	TransactionID string
+	PrepayID      string
+	TradeType     string
+	OpenID        string
+	TotalFee      int
+	TradeState    string // pay.TradeState* 系列常量
+	TimeEnd       time.Time
+	Refunds       []*Refund
+}
+
+// Refund 模拟服务中的退款记录
+type Refund struct {
+	OutRefundNO  string
+	RefundID     string
+	RefundFee    int
+	RefundStatus string // pay.RefundStatus* 系列常量
+}
+
+// 处理支付接口，返回需要输出的字段，若返回 nil 表示找不到相关的内容
+type payHandlerFunc func(params map[string]string) map[string]string
+
+func (s *Server) initPay() {
+	s.handlePay(pay.UnifiedOrderURL, s.unifiedOrder)
+	s.handlePay(pay.OrderQueryURL, s.orderQuery)
+	s.handlePay(pay.CloseOrderURL, s.closeOrder)
+	s.handlePay(pay.RefundURL, s.refund)
+	s.handlePay(pay.RefundQueryURL, s.refundQuery)
+
+	s.mux.HandleFunc(strings.TrimPrefix(pay.GetSignKeyURL, pay.BaseURL), s.payFunc(APIKey, func(map[string]string) map[string]string {
+		return map[string]string{"sandbox_signkey": SandboxSignKey}
+	}))
+}
+
+// Paid 将订单设置为已支付状态
+//
+// 返回 false 表示不存在该订单。
+func (s *Server) Paid(outTradeNO string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, found := s.orders[outTradeNO]
+	if !found {
+		return false
+	}
+	o.TradeState = pay.TradeStateSuccess
+	o.TimeEnd = time.Now()
+	return true
+}
+
+// Order 获取指定订单的副本，不存在时返回 nil
+func (s *Server) Order(outTradeNO string) *Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, found := s.orders[outTradeNO]
+	if !found {
+		return nil
+	}
+
+	ret := *o
+	ret.Refunds = make([]*Refund, 0, len(o.Refunds))
+	for _, rf := range o.Refunds {
+		r := *rf
+		ret.Refunds = append(ret.Refunds, &r)
+	}
+	return &ret
+}
+
+// 同时注册正式和仿真测试环境下的地址
+func (s *Server) handlePay(url string, f payHandlerFunc) {
+	path := strings.TrimPrefix(url, pay.BaseURL)
+	s.mux.HandleFunc(path, s.payFunc(APIKey, f))
+	s.mux.HandleFunc(pay.SandboxPath+strings.TrimPrefix(path, "/secapi"), s.payFunc(SandboxSignKey, f))
+}
+
+func (s *Server) payFunc(key string, f payHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := xxml.MapFromXMLReader(r.Body)
+		if err != nil {
+			writePayFail(w, "XML格式错误")
+			return
+		}
+
+		signType := params["sign_type"]
+		if sign, err := pay.Sign(key, signType, params); err != nil || sign != params["sign"] {
+			writePayFail(w, "签名错误")
+			return
+		}
+
+		if params["mch_id"] != MchID {
+			writePayFail(w, "mch_id参数错误")
+			return
+		}
+
+		if appid, found := params["appid"]; found && appid != AppID {
+			writePayFail(w, "appid参数错误")
+			return
+		}
+
+		ret := map[string]string{
+			"return_code": pay.Success,
+			"return_msg":  "OK",
+			"result_code": pay.Success,
+			"mch_id":      params["mch_id"],
+			"appid":       params["appid"],
+			"sub_mch_id":  params["sub_mch_id"],
+			"sub_appid":   params["sub_appid"],
+			"nonce_str":   pay.NonceString(),
+		}
+
+		if code := s.nextPayError(); code != "" {
+			ret["result_code"] = pay.Fail
+			ret["err_code"] = code
+			ret["err_code_des"] = "模拟的错误"
+		} else {
+			m := f(params)
+			if m == nil {
+				ret["result_code"] = pay.Fail
+				ret["err_code"] = "ORDERNOTEXIST"
+				ret["err_code_des"] = "订单不存在"
+			}
+			for k, v := range m {
+				ret[k] = v
+			}
+		}
+
+		if ret["sign"], err = pay.Sign(key, signType, ret); err != nil {
+			panic(err)
+		}
+		writeXML(w, ret)
+	}
+}
+
+func (s *Server) nextPayError() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.payErrors) == 0 {
+		return ""
+	}
+	code := s.payErrors[0]
+	s.payErrors = s.payErrors[1:]
+	return code
+}
+
+func (s *Server) unifiedOrder(params map[string]string) map[string]string {
+	totalFee, _ := strconv.Atoi(params["total_fee"])
+	o := &Order{
+		OutTradeNO:    params["out_trade_no"],
+		TransactionID: s.unique("transaction-"),
+		PrepayID:      s.unique("prepay-"),
+		TradeType:     params["trade_type"],
+		OpenID:        params["openid"],
+		TotalFee:      totalFee,
+		TradeState:    pay.TradeStateNotPay,
+	}
+
+	s.mu.Lock()
+	s.orders[o.OutTradeNO] = o
+	s.mu.Unlock()
+
+	ret := map[string]string{
+		"trade_type": o.TradeType,
+		"prepay_id":  o.PrepayID,
+	}
+	if o.TradeType == pay.TradeTypeNative {
+		ret["code_url"] = "weixin://wxpay/bizpayurl?pr=" + o.PrepayID
+	}
+	return ret
+}
+
+func (s *Server) findOrder(params map[string]string) *Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if no := params["out_trade_no"]; no != "" {
+		return s.orders[no]
+	}
+
+	for _, o := range s.orders {
+		if match(params["transaction_id"], o.TransactionID) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) orderQuery(params map[string]string) map[string]string {
+	o := s.findOrder(params)
+	if o == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := map[string]string{
+		"out_trade_no":   o.OutTradeNO,
+		"transaction_id": o.TransactionID,
+		"trade_type":     o.TradeType,
+		"trade_state":    o.TradeState,
+		"openid":         o.OpenID,
+		"total_fee":      strconv.Itoa(o.TotalFee),
+	}
+	if !o.TimeEnd.IsZero() {
+		ret["time_end"] = o.TimeEnd.Format(pay.DateFormat)
+		ret["cash_fee"] = ret["total_fee"]
+	}
+	return ret
+}
+
+func (s *Server) closeOrder(params map[string]string) map[string]string {
+	o := s.findOrder(params)
+	if o == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o.TradeState == pay.TradeStateSuccess {
+		return map[string]string{
+			"result_code":  pay.Fail,
+			"err_code":     "ORDERPAID",
+			"err_code_des": "订单已支付",
+		}
+	}
+	o.TradeState = pay.TradeStateClosed
+	return map[string]string{}
+}
+
+func (s *Server) refund(params map[string]string) map[string]string {
+	o := s.findOrder(params)
+	if o == nil {
+		return nil
+	}
+
+	refundFee, _ := strconv.Atoi(params["refund_fee"])
+	rf := &Refund{
+		OutRefundNO:  params["out_refund_no"],
+		RefundID:     s.unique("refund-"),
+		RefundFee:    refundFee,
+		RefundStatus: pay.RefundStatusSuccess,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o.TradeState != pay.TradeStateSuccess && o.TradeState != pay.TradeStateRefund {
+		return map[string]string{
+			"result_code":  pay.Fail,
+			"err_code":     "TRADE_STATE_ERROR",
+			"err_code_des": "订单状态错误",
+		}
+	}
+
+	o.TradeState = pay.TradeStateRefund
+	o.Refunds = append(o.Refunds, rf)
+
+	return map[string]string{
+		"out_trade_no":   o.OutTradeNO,
+		"transaction_id": o.TransactionID,
+		"out_refund_no":  rf.OutRefundNO,
+		"refund_id":      rf.RefundID,
+		"refund_fee":     strconv.Itoa(rf.RefundFee),
+		"total_fee":      strconv.Itoa(o.TotalFee),
+		"cash_fee":       strconv.Itoa(o.TotalFee),
+	}
+}
+
+func (s *Server) refundQuery(params map[string]string) map[string]string {
+	s.mu.Lock()
+	var order *Order
+	refunds := make([]*Refund, 0, 5)
+LOOP:
+	for _, o := range s.orders {
+		if match(params["out_trade_no"], o.OutTradeNO) || match(params["transaction_id"], o.TransactionID) {
+			order = o
+			refunds = append(refunds, o.Refunds...)
+			break
+		}
+
+		for _, rf := range o.Refunds {
+			if match(params["out_refund_no"], rf.OutRefundNO) || match(params["refund_id"], rf.RefundID) {
+				order = o
+				refunds = append(refunds, rf)
+				break LOOP
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if order == nil || len(refunds) == 0 {
+		return nil
+	}
+
+	ret := map[string]string{
+		"out_trade_no":       order.OutTradeNO,
+		"transaction_id":     order.TransactionID,
+		"total_fee":          strconv.Itoa(order.TotalFee),
+		"cash_fee":           strconv.Itoa(order.TotalFee),
+		"refund_count":       strconv.Itoa(len(refunds)),
+		"total_refund_count": strconv.Itoa(len(order.Refunds)),
+	}
+	for i, rf := range refunds {
+		n := "_" + strconv.Itoa(i)
+		ret["out_refund_no"+n] = rf.OutRefundNO
+		ret["refund_id"+n] = rf.RefundID
+		ret["refund_fee"+n] = strconv.Itoa(rf.RefundFee)
+		ret["refund_status"+n] = rf.RefundStatus
+	}
+	return ret
+}
+
+// 查询条件 cond 不为空且与 v 相同
+func match(cond, v string) bool {
+	return cond != "" && cond == v
+}
+
+func writePayFail(w http.ResponseWriter, msg string) {
+	writeXML(w, map[string]string{
+		"return_code": pay.Fail,
+		"return_msg":  msg,
+	})
+}
+
+func writeXML(w http.ResponseWriter, params map[string]string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write(marshalMap(params)); err != nil {
+		panic(err)
+	}
+}
+
+// 将 params 转换成 XML，忽略空值
+func marshalMap(params map[string]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<xml>")
+	for k, v := range params {
+		if v == "" {
+			continue
+		}
+		buf.WriteString("<" + k + ">")
+		xml.EscapeText(&buf, []byte(v)) // bytes.Buffer 的写入不会出错
+		buf.WriteString("</" + k + ">")
+	}
+	buf.WriteString("</xml>")
+	return buf.Bytes()
+}
@@ -0,0 +1,375 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+// Package wechattest 提供用于测试的微信接口模拟服务
+//
+// 模拟了 access_token、jscode2session、模板消息、jsapi_ticket、第三方平台以及支付的部分接口，
+// 可以在不访问网络的情况下测试依赖于微信接口的代码：
+//
+//	srv := wechattest.NewServer()
+//	defer srv.Close()
+//
+//	tksrv := token.NewDefaultServer(srv.Config(), nil)
+//	srv.InjectError(40001) // 下一次调用返回 40001 错误
+//	err := template.Send(tksrv, ...)
+//
+//	p := srv.Pay()
+//	o := unifiedorder.New(p)
+//
+// 同时也提供了 [Callback] 和 [PayNotify] 等模拟微信回调的功能，
+// 用于测试开发者服务器中处理消息推送和支付通知的代码。
+package wechattest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/pay"
+)
+
+// 模拟服务的默认配置
+const (
+	AppID     = "wechattest-appid"
+	AppSecret = "wechattest-appsecret"
+	MchID     = "wechattest-mchid"
+	APIKey    = "wechattest-apikey-0123456789abcd"
+
+	// 仿真测试环境下的签名密钥
+	SandboxSignKey = "wechattest-sandbox-signkey-01234"
+)
+
+// Server 微信接口的模拟服务
+//
+// 所有的方法都是协程安全的。
+type Server struct {
+	srv *httptest.Server
+	mux *http.ServeMux
+
+	mu        sync.Mutex
+	errors    []int    // 下几次 JSON 接口返回的错误代码
+	payErrors []string // 下几次支付接口返回的 err_code
+	tokens    map[string]bool
+	sessions  map[string]*Session // 以 js_code 为键名
+	orders    map[string]*Order   // 以 out_trade_no 为键名
+	authCodes map[string]string   // 第三方平台的授权码，键值为授权方的 appid
+	refreshes map[string]string   // authorizer_refresh_token，键值为授权方的 appid
+	messages  []*Message
+	count     int // 用于生成各类唯一值
+}
+
+// Session 调用 jscode2session 返回的会话信息
+type Session struct {
+	OpenID     string
+	SessionKey string
+	UnionID    string
+}
+
+// Message 通过模拟服务提交的消息或菜单等内容
+type Message struct {
+	Path string          // 接口地址，比如 /cgi-bin/message/template/send
+	Body json.RawMessage // 提交的内容
+}
+
+// NewServer 声明并启动 [Server]
+//
+// 调用者需要负责调用 [Server.Close] 关闭服务。
+func NewServer() *Server {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		tokens:   make(map[string]bool, 5),
+		sessions: make(map[string]*Session, 5),
+		orders:   make(map[string]*Order, 5),
+
+		authCodes: make(map[string]string, 5),
+		refreshes: make(map[string]string, 5),
+	}
+
+	s.mux.HandleFunc("/cgi-bin/token", s.token)
+	s.mux.HandleFunc("/sns/jscode2session", s.jscode2session)
+	s.mux.HandleFunc("/cgi-bin/message/template/send", s.send)
+	s.mux.HandleFunc("/cgi-bin/message/wxopen/template/send", s.send)
+	s.mux.HandleFunc("/cgi-bin/message/subscribe/send", s.send)
+	s.mux.HandleFunc("/cgi-bin/menu/create", s.send)
+	s.mux.HandleFunc("/cgi-bin/ticket/getticket", s.ticket)
+	s.initPay()
+	s.initComponent()
+
+	s.srv = httptest.NewServer(s.mux)
+	return s
+}
+
+// Close 关闭服务
+func (s *Server) Close() { s.srv.Close() }
+
+// URL 服务的地址，格式为 http://ip:port
+func (s *Server) URL() string { return s.srv.URL }
+
+// Config 返回指向当前服务的 [common.Config] 实例
+func (s *Server) Config() *common.Config {
+	u, err := url.Parse(s.srv.URL)
+	if err != nil { // httptest.Server 的地址不可能出错
+		panic(err)
+	}
+
+	return &common.Config{
+		AppID:     AppID,
+		AppSecret: AppSecret,
+		Host:      u.Host,
+		Scheme:    u.Scheme,
+	}
+}
+
+// Pay 返回指向当前服务的 [pay.Pay] 实例
+func (s *Server) Pay() *pay.Pay {
+	return pay.New(MchID, AppID, APIKey, s.srv.Client()).SetBaseURL(s.srv.URL)
+}
+
+// InjectError 指定接下来的 JSON 接口调用依次返回的错误代码
+//
+// 比如 InjectError(40001) 表示下一次调用将返回 40001 错误，之后恢复正常。
+func (s *Server) InjectError(code ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, code...)
+}
+
+// InjectPayError 指定接下来的支付接口调用依次返回的 err_code
+//
+// 比如 InjectPayError("SYSTEMERROR") 表示下一次调用将返回 result_code 为 FAIL，
+// 且 err_code 为 SYSTEMERROR 的内容。
+func (s *Server) InjectPayError(code ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payErrors = append(s.payErrors, code...)
+}
+
+// ExpireTokens 使已经颁发的所有 access_token 失效
+//
+// 包括第三方平台的 component_access_token 和 authorizer_access_token。
+//
+// 之后使用这些 access_token 调用接口，都将返回 42001 错误。
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.tokens {
+		s.tokens[k] = false
+	}
+}
+
+// SetSession 指定 js_code 对应的会话信息
+//
+// 未指定的 js_code 调用 jscode2session 时将返回 40029 错误。
+func (s *Server) SetSession(jscode string, sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[jscode] = sess
+}
+
+// Handle 在模拟服务上添加一个需要 access_token 的 JSON 接口
+//
+// 用于模拟服务未实现的接口。调用时会先验证 access_token 并处理由 [Server.InjectError]
+// 注入的错误，之后将 f 的返回值以 JSON 格式输出。POST 提交的内容同时会记录在
+// [Server.Messages] 中，且依然可以在 f 中读取。
+//
+// 与 [http.ServeMux] 相同，重复添加同一个 path 会 panic。
+func (s *Server) Handle(path string, f func(r *http.Request) interface{}) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if !s.validToken(w, r) {
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			s.mu.Lock()
+			s.messages = append(s.messages, &Message{Path: r.URL.Path, Body: body})
+			s.mu.Unlock()
+		}
+
+		writeJSON(w, f(r))
+	})
+}
+
+// Messages 返回通过模拟服务发送的所有消息
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message{}, s.messages...)
+}
+
+func (s *Server) nextError() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errors) == 0 {
+		return 0
+	}
+	code := s.errors[0]
+	s.errors = s.errors[1:]
+	return code
+}
+
+// 生成唯一值
+func (s *Server) unique(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	return prefix + strconv.Itoa(s.count)
+}
+
+// 验证 access_token，若无效则输出错误信息并返回 false
+func (s *Server) validToken(w http.ResponseWriter, r *http.Request) bool {
+	return s.checkToken(w, r.URL.Query().Get("access_token"))
+}
+
+func (s *Server) checkToken(w http.ResponseWriter, token string) bool {
+	if code := s.nextError(); code != 0 {
+		writeResult(w, code)
+		return false
+	}
+
+	s.mu.Lock()
+	valid, found := s.tokens[token]
+	s.mu.Unlock()
+
+	switch {
+	case !found:
+		writeResult(w, 40001)
+		return false
+	case !valid:
+		writeResult(w, 42001)
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if code := s.nextError(); code != 0 {
+		writeResult(w, code)
+		return
+	}
+
+	q := r.URL.Query()
+	switch {
+	case q.Get("grant_type") != "client_credential":
+		writeResult(w, 40002)
+		return
+	case q.Get("appid") != AppID:
+		writeResult(w, 40013)
+		return
+	case q.Get("secret") != AppSecret:
+		writeResult(w, 40125)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": s.newToken("access-token-"),
+		"expires_in":   7200,
+	})
+}
+
+func (s *Server) jscode2session(w http.ResponseWriter, r *http.Request) {
+	if code := s.nextError(); code != 0 {
+		writeResult(w, code)
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("appid") != AppID {
+		writeResult(w, 40013)
+		return
+	}
+	if q.Get("secret") != AppSecret {
+		writeResult(w, 40125)
+		return
+	}
+
+	s.mu.Lock()
+	sess, found := s.sessions[q.Get("js_code")]
+	s.mu.Unlock()
+	if !found {
+		writeResult(w, 40029)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"openid":      sess.OpenID,
+		"session_key": sess.SessionKey,
+		"unionid":     sess.UnionID,
+	})
+}
+
+func (s *Server) send(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.validToken(w, r) {
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeResult(w, 47001)
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, &Message{Path: r.URL.Path, Body: body})
+	msgid := len(s.messages)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"errcode": 0,
+		"errmsg":  "ok",
+		"msgid":   msgid,
+	})
+}
+
+func (s *Server) ticket(w http.ResponseWriter, r *http.Request) {
+	if !s.validToken(w, r) {
+		return
+	}
+
+	if r.URL.Query().Get("type") != "jsapi" {
+		writeResult(w, 40097)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errcode":    0,
+		"errmsg":     "ok",
+		"ticket":     s.unique("jsapi-ticket-"),
+		"expires_in": 7200,
+	})
+}
+
+func writeResult(w http.ResponseWriter, code int) {
+	rslt := common.NewResult(code)
+	if rslt.Code != code { // 不存在于 common 中的错误代码
+		rslt = &common.Result{Code: code, Message: "错误代码 " + strconv.Itoa(code)}
+	}
+	writeJSON(w, rslt)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: 2016-2024 caixw
+//
+// SPDX-License-Identifier: MIT
+
+package wechattest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/issue9/assert/v4"
+
+	"github.com/issue9/wechat/common"
+	"github.com/issue9/wechat/common/token"
+	"github.com/issue9/wechat/internal"
+	"github.com/issue9/wechat/mp/jssdk/ticket"
+	"github.com/issue9/wechat/mp/template"
+	"github.com/issue9/wechat/pay"
+	"github.com/issue9/wechat/pay/order"
+	"github.com/issue9/wechat/pay/refund"
+	"github.com/issue9/wechat/pay/unifiedorder"
+	"github.com/issue9/wechat/weapp/auth"
+)
+
+func TestServer_token(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+
+	at, err := token.Refresh(srv.Config())
+	a.NotError(err).NotEmpty(at.AccessToken)
+
+	conf := srv.Config()
+	conf.AppSecret = "other"
+	_, err = token.Refresh(conf)
+	a.Equal(err, common.NewResult(40125))
+
+	srv.InjectError(-1)
+	_, err = token.Refresh(srv.Config())
+	a.Equal(err, common.NewResult(-1))
+
+	at, err = token.Refresh(srv.Config())
+	a.NotError(err).NotEmpty(at.AccessToken)
+}
+
+func TestServer_jscode2session(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+
+	_, err := auth.Authorization(srv.Config(), "code")
+	a.Equal(err, common.NewResult(40029))
+
+	srv.SetSession("code", &Session{OpenID: "openid", SessionKey: "key"})
+	resp, err := auth.Authorization(srv.Config(), "code")
+	a.NotError(err).
+		Equal(resp.Openid, "openid").
+		Equal(resp.SessionKey, "key")
+}
+
+func TestServer_send(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+	data := template.Data{"first": template.KV{Value: "v"}}
+	a.NotError(template.Send(tksrv, "openid", "tplid", "", data))
+	msgs := srv.Messages()
+	a.Length(msgs, 1).
+		Equal(msgs[0].Path, "/cgi-bin/message/template/send")
+
+	srv.InjectError(40001)
+	err := template.Send(tksrv, "openid", "tplid", "", data)
+	a.Error(err)
+	a.Length(srv.Messages(), 1)
+
+	srv.ExpireTokens()
+	err = template.Send(tksrv, "openid", "tplid", "", data)
+	a.Error(err)
+
+	_, err = tksrv.Refresh()
+	a.NotError(err)
+	a.NotError(template.Send(tksrv, "openid", "tplid", "", data))
+	a.Length(srv.Messages(), 2)
+}
+
+func TestServer_ticket(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+	tk, err := ticket.Refresh(tksrv)
+	a.NotError(err).NotEmpty(tk.Ticket).Equal(tk.Code, 0)
+
+	srv.InjectError(40001)
+	tk, err = ticket.Refresh(tksrv)
+	a.NotError(err).Equal(tk.Code, 40001)
+}
+
+func TestServer_Handle(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+
+	srv.Handle("/custom", func(r *http.Request) interface{} {
+		req := map[string]string{}
+		a.NotError(json.NewDecoder(r.Body).Decode(&req))
+		return map[string]string{"echo": req["v"] + r.URL.Query().Get("q")}
+	})
+
+	tksrv := token.NewDefaultServer(srv.Config(), nil)
+	resp := map[string]string{}
+	url := token.URL(tksrv, "custom", map[string]string{"q": "2"})
+	a.NotError(internal.PostJSON(url, map[string]string{"v": "1"}, &resp)).
+		Equal(resp["echo"], "12")
+	msgs := srv.Messages()
+	a.Length(msgs, 1).
+		Equal(msgs[0].Path, "/custom").
+		Equal(string(msgs[0].Body), `{"v":"1"}`)
+
+	srv.InjectError(45009)
+	err := internal.PostJSON(url, map[string]string{"v": "1"}, &resp)
+	a.Equal(err.(*common.Result).Code, 45009)
+
+	srv.ExpireTokens()
+	err = internal.GetJSON(url, &resp)
+	a.Equal(err.(*common.Result).Code, 42001)
+}
+
+func TestServer_pay(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+	p := srv.Pay()
+
+	o := unifiedorder.New(p).NewOrder()
+	o.TradeType = pay.TradeTypeNative
+	o.OutTradeNO = "no1"
+	o.TotalFee = 100
+	o.Body = "body"
+	ret, err := o.Pay()
+	a.NotError(err).NotEmpty(ret.PrepayID).NotEmpty(ret.CodeURL)
+
+	q := &order.Order{Pay: p}
+	qret, err := q.OutTradeNO("no1")
+	a.NotError(err).Equal(qret.TradeState, pay.TradeStateNotPay)
+
+	// 未支付不能退款
+	rf := &refund.Refund{Pay: p}
+	_, err = rf.OutTradeNO("rf1", "no1", 100, 50)
+	a.Error(err)
+
+	a.True(srv.Paid("no1"))
+	qret, err = q.OutTradeNO("no1")
+	a.NotError(err).True(qret.Paid())
+
+	rret, err := rf.OutTradeNO("rf1", "no1", 100, 50)
+	a.NotError(err).Equal(rret.RefundFee, 50).NotEmpty(rret.RefundID)
+
+	rqret, err := rf.QueryOutRefundNO("rf1")
+	a.NotError(err).Length(rqret.Refunds, 1)
+	a.Equal(rqret.Refunds[0].RefundStatus, pay.RefundStatusSuccess)
+	a.Length(srv.Order("no1").Refunds, 1)
+
+	srv.InjectPayError("SYSTEMERROR")
+	_, err = q.OutTradeNO("no1")
+	a.Error(err)
+
+	_, err = q.OutTradeNO("not-exists")
+	a.Error(err)
+
+	// 签名错误
+	p2 := pay.New(MchID, AppID, "other", nil).SetBaseURL(srv.URL())
+	_, err = (&order.Order{Pay: p2}).OutTradeNO("no1")
+	a.Error(err)
+}
+
+func TestServer_sandbox(t *testing.T) {
+	a := assert.New(t, false)
+	srv := NewServer()
+	defer srv.Close()
+
+	p, err := srv.Pay().Sandbox()
+	a.NotError(err).Equal(p.APIKey(), SandboxSignKey)
+
+	o := unifiedorder.New(p).NewOrder()
+	o.TradeType = pay.TradeTypeJSAPI
+	o.OutTradeNO = "no1"
+	o.TotalFee = 100
+	ret, err := o.Pay()
+	a.NotError(err).NotEmpty(ret.PrepayID)
+	a.NotNil(srv.Order("no1"))
+}